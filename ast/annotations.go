@@ -0,0 +1,70 @@
+package ast
+
+import (
+	"strings"
+
+	"go-interpreter.com/m/token"
+)
+
+// TypeAnnotation is the type written after a let name or a parameter, or after the `->`
+// of a function. Annotations are only read by static checkers, the evaluator ignores them
+type TypeAnnotation interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type written as a name, like int or string
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType is written `[element]`
+type ArrayType struct {
+	Token   token.Token
+	Element TypeAnnotation
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+// HashType is written `{key: value}`
+type HashType struct {
+	Token token.Token
+	Key   TypeAnnotation
+	Value TypeAnnotation
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType is written `fn(parameters) -> result`, Result is nil when the arrow is omitted
+type FunctionType struct {
+	Token      token.Token
+	Parameters []TypeAnnotation
+	Result     TypeAnnotation
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	params := make([]string, 0, len(ft.Parameters))
+	for _, param := range ft.Parameters {
+		params = append(params, param.String())
+	}
+
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Result != nil {
+		out += " -> " + ft.Result.String()
+	}
+
+	return out
+}
@@ -43,18 +43,34 @@ func (p *Program) String() string {
 	return builder.String()
 }
 
+// LetStatement binds Value to Name, or to the names of Pattern when the value is
+// destructured, in which case Name is nil. Type is nil when the binding isn't annotated.
+// Exported bindings of a script are visible to the scripts importing it
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token
+	Name     *Identifier
+	Pattern  Pattern
+	Type     TypeAnnotation
+	Value    Expression
+	Exported bool
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
+	if ls.Exported {
+		out.WriteString("export ")
+	}
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -81,10 +97,27 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// ThrowStatement fails with a runtime error made from Value, which a try expression can catch
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string       { return "throw " + ts.Value.String() + ";" }
+
 // Identifier
 type Identifier struct {
 	Token token.Token
 	Value string
+
+	// Set by the resolver when the identifier refers to a local of a function, Slot is its index
+	// in the frame of the function Depth function scopes up from the use. Globals and
+	// identifiers that weren't resolved are looked up by name
+	Local bool
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -185,6 +218,33 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression evaluates to the value of Block, or to the value of CatchBlock when Block
+// fails with a catchable runtime error, which is bound to Catch. Finally runs after both
+// whether they fail or not. Either CatchBlock or Finally may be nil
+type TryExpression struct {
+	Token       token.Token
+	Block       *BlockStatement
+	Catch       *Identifier
+	CatchBlock  *BlockStatement
+	CatchLocals []string // names of the slots of the catch block's frame, nil until resolved
+	Finally     *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try { " + te.Block.String() + " }")
+	if te.CatchBlock != nil {
+		out.WriteString(" catch (" + te.Catch.String() + ") { " + te.CatchBlock.String() + " }")
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally { " + te.Finally.String() + " }")
+	}
+
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -202,8 +262,21 @@ func (bs *BlockStatement) String() string {
 
 type FunctionExpression struct {
 	Token      token.Token
+	Name       string // name of the binding when the function is defined in a let statement
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Locals     []string // names of the frame slots, parameters first, nil until resolved
+
+	// ParameterTypes has an annotation or nil for each parameter, ReturnType is nil when the
+	// result isn't annotated
+	ParameterTypes []TypeAnnotation
+	ReturnType     TypeAnnotation
+
+	// Defaults has the default value or nil for each parameter, it's nil when no parameter
+	// has one. The last parameter of a variadic function collects the remaining arguments
+	// in an array
+	Defaults []Expression
+	Variadic bool
 }
 
 func (fe *FunctionExpression) expressionNode()      {}
@@ -214,12 +287,25 @@ func (fe *FunctionExpression) String() string {
 	out.WriteString("(")
 
 	params := make([]string, 0)
-	for _, param := range fe.Parameters {
-		params = append(params, param.Value)
+	for i, param := range fe.Parameters {
+		text := param.Value
+		if fe.Variadic && i == len(fe.Parameters)-1 {
+			text = "..." + text
+		}
+		if i < len(fe.ParameterTypes) && fe.ParameterTypes[i] != nil {
+			text += ": " + fe.ParameterTypes[i].String()
+		}
+		if i < len(fe.Defaults) && fe.Defaults[i] != nil {
+			text += " = " + fe.Defaults[i].String()
+		}
+		params = append(params, text)
 	}
 
 	out.WriteString(strings.Join(params, ","))
 	out.WriteString(")")
+	if fe.ReturnType != nil {
+		out.WriteString(" -> " + fe.ReturnType.String())
+	}
 	out.WriteString("{\n")
 	for _, statement := range fe.Body.Statements {
 		out.WriteString(statement.String() + "\n")
@@ -229,6 +315,32 @@ func (fe *FunctionExpression) String() string {
 	return out.String()
 }
 
+// MacroLiteral defines a macro, its body runs during macro expansion with the arguments of
+// a call bound to the parameters as quotes and returns the quote that replaces the call
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := make([]string, 0, len(ml.Parameters))
+	for _, param := range ml.Parameters {
+		params = append(params, param.Value)
+	}
+
+	out.WriteString("macro(")
+	out.WriteString(strings.Join(params, ","))
+	out.WriteString(")")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
@@ -249,3 +361,115 @@ func (ce *CallExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// SpreadExpression passes the elements of an array as separate arguments, it's only
+// parsed in the arguments of a call
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// NamedArgument passes Value to the parameter called Name, it's only parsed in the arguments
+// of a call, after the positional ones
+type NamedArgument struct {
+	Token token.Token // the name
+	Name  string
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name + ": " + na.Value.String() }
+
+// SpawnExpression runs a function on a new task. Function is either a call expression, whose
+// function and arguments are evaluated before the task starts, or a function called without arguments
+type SpawnExpression struct {
+	Token    token.Token
+	Function Expression
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string       { return "spawn " + se.Function.String() }
+
+// ImportExpression evaluates to the module loaded from Path, relative paths are looked up
+// next to the importing script first and then in the search path
+type ImportExpression struct {
+	Token token.Token
+	Path  string
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) String() string       { return `import "` + ie.Path + `"` }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+// HashLiteral keeps the keys in source order so evaluation order and String are deterministic
+type HashLiteral struct {
+	Token  token.Token
+	Keys   []Expression
+	Values []Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for i, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Values[i].String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
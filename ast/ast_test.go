@@ -27,3 +27,107 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+
+		return &IntegerLiteral{Value: 2}
+	}
+
+	testCases := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&InfixExpression{Left: two(), Operator: "+", Right: one()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{Value: one()}, &ReturnStatement{Value: two()}},
+		{&LetStatement{Name: &Identifier{Value: "x"}, Value: one()}, &LetStatement{Name: &Identifier{Value: "x"}, Value: two()}},
+		{
+			&FunctionExpression{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&FunctionExpression{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), two(), one()}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two(), two()}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{&HashLiteral{Keys: []Expression{one()}, Values: []Expression{one()}}, &HashLiteral{Keys: []Expression{two()}, Values: []Expression{two()}}},
+		{&SpawnExpression{Function: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one()}}},
+			&SpawnExpression{Function: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two()}}}},
+		{
+			&MatchExpression{Value: one(), Arms: []*MatchArm{
+				{Pattern: &LiteralPattern{Value: one()}, Guard: one(), Body: one()},
+				{Pattern: &ArrayPattern{Elements: []Pattern{&LiteralPattern{Value: one()}}, Rest: &WildcardPattern{}}, Body: two()},
+				{Pattern: &HashPattern{Keys: []Expression{one()}, Values: []Pattern{&LiteralPattern{Value: one()}}}, Body: one()},
+			}},
+			&MatchExpression{Value: two(), Arms: []*MatchArm{
+				{Pattern: &LiteralPattern{Value: two()}, Guard: two(), Body: two()},
+				{Pattern: &ArrayPattern{Elements: []Pattern{&LiteralPattern{Value: two()}}, Rest: &WildcardPattern{}}, Body: two()},
+				{Pattern: &HashPattern{Keys: []Expression{two()}, Values: []Pattern{&LiteralPattern{Value: two()}}}, Body: two()},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		before := tc.input.String()
+		modified := Modify(tc.input, turnOneIntoTwo)
+
+		if modified.String() != tc.expected.String() {
+			t.Errorf("not modified. want=%q, got=%q", tc.expected.String(), modified.String())
+		}
+
+		if tc.input.String() != before {
+			t.Errorf("input was changed in place. want=%q, got=%q", before, tc.input.String())
+		}
+	}
+}
+
+func TestModifySharesUnchangedNodes(t *testing.T) {
+	unchanged := &InfixExpression{Left: &IntegerLiteral{Value: 3}, Operator: "*", Right: &IntegerLiteral{Value: 4}}
+	array := &ArrayLiteral{Elements: []Expression{unchanged, &IntegerLiteral{Value: 1}}}
+
+	modified := Modify(array, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+			return &IntegerLiteral{Value: 2}
+		}
+		return node
+	}).(*ArrayLiteral)
+
+	if modified == array {
+		t.Fatalf("modified array is the input")
+	}
+
+	if modified.Elements[0] != unchanged {
+		t.Errorf("unchanged element was copied")
+	}
+
+	if Modify(unchanged, func(node Node) Node { return node }) != unchanged {
+		t.Errorf("node without changes was copied")
+	}
+}
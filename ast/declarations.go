@@ -0,0 +1,104 @@
+package ast
+
+// DeclaredNames lists the names bound by let statements and patterns in statements,
+// including the ones nested in if blocks, which share the scope of the function, but not the
+// ones in function literals, match arms and catch blocks since those get a scope of their own
+func DeclaredNames(statements []Statement) []string {
+	names := []string{}
+	for _, s := range statements {
+		names = appendStatementNames(names, s)
+	}
+
+	return names
+}
+
+func appendStatementNames(names []string, s Statement) []string {
+	switch s := s.(type) {
+	case *LetStatement:
+		names = appendExpressionNames(names, s.Value)
+		if s.Pattern != nil {
+			for _, ident := range PatternIdentifiers(s.Pattern) {
+				names = append(names, ident.Value)
+			}
+			return names
+		}
+		return append(names, s.Name.Value)
+	case *ReturnStatement:
+		return appendExpressionNames(names, s.Value)
+	case *ThrowStatement:
+		return appendExpressionNames(names, s.Value)
+	case *ExpressionStatement:
+		return appendExpressionNames(names, s.Expression)
+	case *BlockStatement:
+		for _, stmt := range s.Statements {
+			names = appendStatementNames(names, stmt)
+		}
+	}
+
+	return names
+}
+
+func appendExpressionNames(names []string, e Expression) []string {
+	switch e := e.(type) {
+	case *IfExpression:
+		names = appendExpressionNames(names, e.Condition)
+		names = appendStatementNames(names, e.Consequence)
+		if e.Alternative != nil {
+			names = appendStatementNames(names, e.Alternative)
+		}
+	case *TryExpression:
+		names = appendStatementNames(names, e.Block)
+		if e.Finally != nil {
+			names = appendStatementNames(names, e.Finally)
+		}
+	case *PrefixExpression:
+		names = appendExpressionNames(names, e.Right)
+	case *InfixExpression:
+		names = appendExpressionNames(names, e.Left)
+		names = appendExpressionNames(names, e.Right)
+	case *CallExpression:
+		names = appendExpressionNames(names, e.Function)
+		for _, arg := range e.Arguments {
+			names = appendExpressionNames(names, arg)
+		}
+	case *ArrayLiteral:
+		for _, el := range e.Elements {
+			names = appendExpressionNames(names, el)
+		}
+	case *HashLiteral:
+		for i, key := range e.Keys {
+			names = appendExpressionNames(names, key)
+			names = appendExpressionNames(names, e.Values[i])
+		}
+	case *IndexExpression:
+		names = appendExpressionNames(names, e.Left)
+		names = appendExpressionNames(names, e.Index)
+	case *SpreadExpression:
+		names = appendExpressionNames(names, e.Value)
+	case *NamedArgument:
+		names = appendExpressionNames(names, e.Value)
+	case *SpawnExpression:
+		names = appendExpressionNames(names, e.Function)
+	case *MatchExpression:
+		names = appendExpressionNames(names, e.Value)
+	}
+
+	return names
+}
+
+// CatchNames lists the names bound in the scope of the catch block of te, the caught error and
+// the ones declared by the block
+func CatchNames(te *TryExpression) []string {
+	return appendStatementNames([]string{te.Catch.Value}, te.CatchBlock)
+}
+
+// ArmNames lists the names bound in the scope of a match arm, the ones of its pattern and
+// the ones declared by its guard and body
+func ArmNames(arm *MatchArm) []string {
+	names := []string{}
+	for _, ident := range PatternIdentifiers(arm.Pattern) {
+		names = append(names, ident.Value)
+	}
+	names = appendExpressionNames(names, arm.Guard)
+	return appendExpressionNames(names, arm.Body)
+}
@@ -0,0 +1,287 @@
+package ast
+
+// ModifierFunc returns the node that replaces node, or node itself to keep it
+type ModifierFunc func(node Node) Node
+
+// Modify walks node depth first replacing each node by the result of modifier, the children
+// of a node are modified before the node itself. Nodes are never changed in place: a node
+// whose children are replaced is copied, the rest of the tree is shared with the result
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		if statements, ok := modifyStatements(n.Statements, modifier); ok {
+			modified := *n
+			modified.Statements = statements
+			node = &modified
+		}
+	case *BlockStatement:
+		if statements, ok := modifyStatements(n.Statements, modifier); ok {
+			modified := *n
+			modified.Statements = statements
+			node = &modified
+		}
+	case *ExpressionStatement:
+		if expression := modifyExpression(n.Expression, modifier); expression != n.Expression {
+			modified := *n
+			modified.Expression = expression
+			node = &modified
+		}
+	case *ReturnStatement:
+		if value := modifyExpression(n.Value, modifier); value != n.Value {
+			modified := *n
+			modified.Value = value
+			node = &modified
+		}
+	case *ThrowStatement:
+		if value := modifyExpression(n.Value, modifier); value != n.Value {
+			modified := *n
+			modified.Value = value
+			node = &modified
+		}
+	case *LetStatement:
+		pattern := modifyPattern(n.Pattern, modifier)
+		if value := modifyExpression(n.Value, modifier); value != n.Value || pattern != n.Pattern {
+			modified := *n
+			modified.Pattern, modified.Value = pattern, value
+			node = &modified
+		}
+	case *PrefixExpression:
+		if right := modifyExpression(n.Right, modifier); right != n.Right {
+			modified := *n
+			modified.Right = right
+			node = &modified
+		}
+	case *InfixExpression:
+		left, right := modifyExpression(n.Left, modifier), modifyExpression(n.Right, modifier)
+		if left != n.Left || right != n.Right {
+			modified := *n
+			modified.Left, modified.Right = left, right
+			node = &modified
+		}
+	case *IndexExpression:
+		left, index := modifyExpression(n.Left, modifier), modifyExpression(n.Index, modifier)
+		if left != n.Left || index != n.Index {
+			modified := *n
+			modified.Left, modified.Index = left, index
+			node = &modified
+		}
+	case *IfExpression:
+		condition := modifyExpression(n.Condition, modifier)
+		consequence := modifyBlock(n.Consequence, modifier)
+		alternative := modifyBlock(n.Alternative, modifier)
+		if condition != n.Condition || consequence != n.Consequence || alternative != n.Alternative {
+			modified := *n
+			modified.Condition, modified.Consequence, modified.Alternative = condition, consequence, alternative
+			node = &modified
+		}
+	case *TryExpression:
+		block, catchBlock, finally := modifyBlock(n.Block, modifier), modifyBlock(n.CatchBlock, modifier), modifyBlock(n.Finally, modifier)
+		if block != n.Block || catchBlock != n.CatchBlock || finally != n.Finally {
+			modified := *n
+			modified.Block, modified.CatchBlock, modified.Finally = block, catchBlock, finally
+			node = &modified
+		}
+	case *FunctionExpression:
+		parameters, changed := modifyParameters(n.Parameters, modifier)
+		defaults, defaultsChanged := modifyExpressions(n.Defaults, modifier)
+		if body := modifyBlock(n.Body, modifier); changed || defaultsChanged || body != n.Body {
+			modified := *n
+			modified.Parameters, modified.Defaults, modified.Body = parameters, defaults, body
+			node = &modified
+		}
+	case *MacroLiteral:
+		parameters, changed := modifyParameters(n.Parameters, modifier)
+		if body := modifyBlock(n.Body, modifier); changed || body != n.Body {
+			modified := *n
+			modified.Parameters, modified.Body = parameters, body
+			node = &modified
+		}
+	case *CallExpression:
+		function := modifyExpression(n.Function, modifier)
+		if arguments, ok := modifyExpressions(n.Arguments, modifier); ok || function != n.Function {
+			modified := *n
+			modified.Function, modified.Arguments = function, arguments
+			node = &modified
+		}
+	case *SpreadExpression:
+		if value := modifyExpression(n.Value, modifier); value != n.Value {
+			modified := *n
+			modified.Value = value
+			node = &modified
+		}
+	case *NamedArgument:
+		if value := modifyExpression(n.Value, modifier); value != n.Value {
+			modified := *n
+			modified.Value = value
+			node = &modified
+		}
+	case *SpawnExpression:
+		if function := modifyExpression(n.Function, modifier); function != n.Function {
+			modified := *n
+			modified.Function = function
+			node = &modified
+		}
+	case *ArrayLiteral:
+		if elements, ok := modifyExpressions(n.Elements, modifier); ok {
+			modified := *n
+			modified.Elements = elements
+			node = &modified
+		}
+	case *HashLiteral:
+		keys, keysChanged := modifyExpressions(n.Keys, modifier)
+		if values, ok := modifyExpressions(n.Values, modifier); ok || keysChanged {
+			modified := *n
+			modified.Keys, modified.Values = keys, values
+			node = &modified
+		}
+	case *MatchExpression:
+		value := modifyExpression(n.Value, modifier)
+		if arms, ok := modifyList(n.Arms, func(arm *MatchArm) *MatchArm { return modifyArm(arm, modifier) }); ok || value != n.Value {
+			modified := *n
+			modified.Value, modified.Arms = value, arms
+			node = &modified
+		}
+	case *LiteralPattern:
+		if value := modifyExpression(n.Value, modifier); value != n.Value {
+			modified := *n
+			modified.Value = value
+			node = &modified
+		}
+	case *ArrayPattern:
+		elements, changed := modifyPatterns(n.Elements, modifier)
+		if rest := modifyPattern(n.Rest, modifier); changed || rest != n.Rest {
+			modified := *n
+			modified.Elements, modified.Rest = elements, rest
+			node = &modified
+		}
+	case *HashPattern:
+		keys, keysChanged := modifyExpressions(n.Keys, modifier)
+		if values, ok := modifyPatterns(n.Values, modifier); ok || keysChanged {
+			modified := *n
+			modified.Keys, modified.Values = keys, values
+			node = &modified
+		}
+	}
+
+	return modifier(node)
+}
+
+// IsCallTo reports whether node calls the identifier name with a single argument, like the
+// quote and unquote forms of macros
+func IsCallTo(node Node, name string) bool {
+	call, ok := node.(*CallExpression)
+	if !ok || len(call.Arguments) != 1 {
+		return false
+	}
+
+	ident, ok := call.Function.(*Identifier)
+	return ok && ident.Value == name
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) ([]Statement, bool) {
+	return modifyList(statements, func(s Statement) Statement {
+		if modified, ok := Modify(s, modifier).(Statement); ok {
+			return modified
+		}
+		return s
+	})
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) ([]Expression, bool) {
+	return modifyList(expressions, func(e Expression) Expression {
+		return modifyExpression(e, modifier)
+	})
+}
+
+func modifyParameters(parameters []*Identifier, modifier ModifierFunc) ([]*Identifier, bool) {
+	return modifyList(parameters, func(param *Identifier) *Identifier {
+		if modified, ok := Modify(param, modifier).(*Identifier); ok {
+			return modified
+		}
+		return param
+	})
+}
+
+func modifyPatterns(patterns []Pattern, modifier ModifierFunc) ([]Pattern, bool) {
+	return modifyList(patterns, func(p Pattern) Pattern {
+		return modifyPattern(p, modifier)
+	})
+}
+
+// modifyArm copies arm when its pattern, guard or body is replaced
+func modifyArm(arm *MatchArm, modifier ModifierFunc) *MatchArm {
+	pattern := modifyPattern(arm.Pattern, modifier)
+	guard, body := modifyExpression(arm.Guard, modifier), modifyExpression(arm.Body, modifier)
+	if pattern == arm.Pattern && guard == arm.Guard && body == arm.Body {
+		return arm
+	}
+
+	modified := *arm
+	modified.Pattern, modified.Guard, modified.Body = pattern, guard, body
+	return &modified
+}
+
+// modifyList returns the modified elements in a new slice and true when any of them was
+// replaced, list itself otherwise
+func modifyList[T comparable](list []T, modify func(T) T) ([]T, bool) {
+	var modified []T
+	for i, el := range list {
+		replacement := modify(el)
+		if replacement == el {
+			if modified != nil {
+				modified[i] = el
+			}
+			continue
+		}
+
+		if modified == nil {
+			modified = make([]T, len(list))
+			copy(modified, list[:i])
+		}
+		modified[i] = replacement
+	}
+
+	if modified == nil {
+		return list, false
+	}
+
+	return modified, true
+}
+
+// modifyExpression keeps nil expressions, which the parser leaves after syntax errors, and
+// the expressions replaced by nodes that aren't expressions
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+
+	if modified, ok := Modify(e, modifier).(Expression); ok {
+		return modified
+	}
+
+	return e
+}
+
+func modifyPattern(p Pattern, modifier ModifierFunc) Pattern {
+	if p == nil {
+		return nil
+	}
+
+	if modified, ok := Modify(p, modifier).(Pattern); ok {
+		return modified
+	}
+
+	return p
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+
+	return block
+}
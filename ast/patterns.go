@@ -0,0 +1,167 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"go-interpreter.com/m/token"
+)
+
+// Pattern is the left side of a match arm, identifiers are binding patterns that match
+// any value and bind it to their name
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+func (i *Identifier) patternNode() {}
+
+// WildcardPattern matches any value without binding it
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) String() string       { return "_" }
+
+// LiteralPattern matches values equal to an integer, string or boolean literal, negative
+// integers are prefix expressions
+type LiteralPattern struct {
+	Token token.Token
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+
+// ArrayPattern matches arrays element by element. Without Rest the array must have as many
+// elements as the pattern, with it the array of the remaining elements must match Rest,
+// which is a binding or a wildcard
+type ArrayPattern struct {
+	Token    token.Token
+	Elements []Pattern
+	Rest     Pattern
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := make([]string, 0, len(ap.Elements)+1)
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern matches hashes holding every key of the pattern, the value of each key must
+// match its pattern. Other keys of the hash are ignored
+type HashPattern struct {
+	Token  token.Token
+	Keys   []Expression
+	Values []Pattern
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	pairs := make([]string, 0, len(hp.Keys))
+	for i, key := range hp.Keys {
+		if hp.isShorthand(i) {
+			pairs = append(pairs, key.String())
+			continue
+		}
+		pairs = append(pairs, key.String()+":"+hp.Values[i].String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// isShorthand reports whether the i-th pair binds the value of a string key to a name
+// spelled like the key
+func (hp *HashPattern) isShorthand(i int) bool {
+	key, ok := hp.Keys[i].(*StringLiteral)
+	if !ok {
+		return false
+	}
+
+	name, ok := hp.Values[i].(*Identifier)
+	return ok && name.Value == key.Value
+}
+
+// MatchExpression evaluates to the body of the first arm whose pattern matches Value and
+// whose guard, if any, is truthy
+type MatchExpression struct {
+	Token token.Token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm is a `pattern if guard => body` case of a match expression, Guard may be nil
+type MatchArm struct {
+	Token   token.Token // first token of the pattern
+	Pattern Pattern
+	Guard   Expression
+	Body    Expression
+	Locals  []string // names of the slots of the arm's frame, nil until resolved
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := make([]string, 0, len(me.Arms))
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Value.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
+// PatternIdentifiers lists the identifiers bound by p in source order
+func PatternIdentifiers(p Pattern) []*Identifier {
+	switch p := p.(type) {
+	case *Identifier:
+		return []*Identifier{p}
+	case *ArrayPattern:
+		idents := []*Identifier{}
+		for _, el := range p.Elements {
+			idents = append(idents, PatternIdentifiers(el)...)
+		}
+		idents = append(idents, PatternIdentifiers(p.Rest)...)
+		return idents
+	case *HashPattern:
+		idents := []*Identifier{}
+		for _, value := range p.Values {
+			idents = append(idents, PatternIdentifiers(value)...)
+		}
+		return idents
+	}
+
+	return nil
+}
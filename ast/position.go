@@ -0,0 +1,56 @@
+package ast
+
+import "go-interpreter.com/m/token"
+
+// Position returns the token where node starts in the source, the zero token for nodes
+// without position
+func Position(node Node) token.Token {
+	switch node := node.(type) {
+	case *InfixExpression:
+		return Position(node.Left)
+	case *CallExpression:
+		return Position(node.Function)
+	case *IndexExpression:
+		return Position(node.Left)
+	case *ExpressionStatement:
+		return Position(node.Expression)
+	case *Identifier:
+		return node.Token
+	case *IntegerLiteral:
+		return node.Token
+	case *StringLiteral:
+		return node.Token
+	case *Boolean:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *IfExpression:
+		return node.Token
+	case *TryExpression:
+		return node.Token
+	case *ThrowStatement:
+		return node.Token
+	case *FunctionExpression:
+		return node.Token
+	case *ArrayLiteral:
+		return node.Token
+	case *HashLiteral:
+		return node.Token
+	case *SpreadExpression:
+		return node.Token
+	case *NamedArgument:
+		return node.Token
+	case *SpawnExpression:
+		return node.Token
+	case *ImportExpression:
+		return node.Token
+	case *MatchExpression:
+		return node.Token
+	case *LetStatement:
+		return node.Token
+	case *ReturnStatement:
+		return node.Token
+	}
+
+	return token.Token{}
+}
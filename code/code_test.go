@@ -0,0 +1,142 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	testCases := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534}, []byte{byte(OpClosure), 255, 254}},
+	}
+
+	for _, tc := range testCases {
+		instruction := Make(tc.op, tc.operands...)
+
+		if len(instruction) != len(tc.expected) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tc.expected), len(instruction))
+		}
+
+		for i, b := range tc.expected {
+			if instruction[i] != tc.expected[i] {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestMaxOperand(t *testing.T) {
+	testCases := []struct {
+		op       Opcode
+		operand  int
+		expected int
+	}{
+		{OpConstant, 0, 65535},
+		{OpGetLocal, 0, 255},
+		{OpCall, 0, 255},
+		{OpAdd, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		if got := MaxOperand(tc.op, tc.operand); got != tc.expected {
+			t.Errorf("wrong max operand for %d. want=%d, got=%d", tc.op, tc.expected, got)
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	testCases := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+	}
+
+	for _, tc := range testCases {
+		instruction := Make(tc.op, tc.operands...)
+
+		def, err := Lookup(byte(tc.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tc.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tc.bytesRead, n)
+		}
+
+		for i, want := range tc.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	var lines LineTable
+	lines = lines.Add(0, 1)
+	lines = lines.Add(3, 1)
+	lines = lines.Add(4, 2)
+	lines = lines.Add(9, 4)
+	lines = lines.Add(9, 5)
+
+	if len(lines) != 3 {
+		t.Fatalf("line table has wrong number of entries. got=%+v", lines)
+	}
+
+	testCases := []struct {
+		offset   int
+		expected int
+	}{
+		{0, 1},
+		{3, 1},
+		{4, 2},
+		{8, 2},
+		{9, 5},
+		{100, 5},
+	}
+
+	for _, tc := range testCases {
+		if line := lines.Line(tc.offset); line != tc.expected {
+			t.Errorf("wrong line for offset %d. want=%d, got=%d", tc.offset, tc.expected, line)
+		}
+	}
+
+	lines = lines.Truncate(4)
+	if line := lines.Line(9); line != 1 {
+		t.Errorf("truncated entries are still used. got=%d", line)
+	}
+}
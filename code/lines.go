@@ -0,0 +1,46 @@
+package code
+
+import "sort"
+
+// LineEntry maps the instructions from Offset up to the next entry to a line of the source
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// LineTable is the debug information of some instructions, sorted by offset
+type LineTable []LineEntry
+
+// Add records that the instructions from offset on come from line, it only grows the table
+// when the line changes
+func (lt LineTable) Add(offset, line int) LineTable {
+	if line <= 0 || len(lt) > 0 && lt[len(lt)-1].Line == line {
+		return lt
+	}
+
+	if len(lt) > 0 && lt[len(lt)-1].Offset == offset {
+		lt[len(lt)-1].Line = line
+		return lt
+	}
+
+	return append(lt, LineEntry{Offset: offset, Line: line})
+}
+
+// Truncate drops the entries of the instructions from offset on
+func (lt LineTable) Truncate(offset int) LineTable {
+	for len(lt) > 0 && lt[len(lt)-1].Offset >= offset {
+		lt = lt[:len(lt)-1]
+	}
+
+	return lt
+}
+
+// Line returns the source line of the instruction at offset, 0 when it's unknown
+func (lt LineTable) Line(offset int) int {
+	i := sort.Search(len(lt), func(i int) bool { return lt[i].Offset > offset })
+	if i == 0 {
+		return 0
+	}
+
+	return lt[i-1].Line
+}
@@ -0,0 +1,466 @@
+// Package compiler lowers the AST into bytecode for the vm
+package compiler
+
+import (
+	"fmt"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/object"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	lines               code.LineTable
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	line int   // source line of the node being compiled
+	err  error // first operand that didn't fit its encoding
+}
+
+// Bytecode is a compiled program, GlobalNames is indexed by global slot
+type Bytecode struct {
+	Instructions code.Instructions
+	Lines        code.LineTable
+	Constants    []object.Object
+	GlobalNames  []string
+}
+
+func New() *Compiler {
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{{instructions: code.Instructions{}}},
+	}
+}
+
+// NewWithState creates a compiler that keeps the globals and constants of previous
+// compilations, as a REPL needs
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) (err error) {
+	defer func() {
+		if err == nil {
+			err = c.err
+		}
+	}()
+
+	if line := nodeLine(node); line > 0 {
+		enclosingLine := c.line
+		c.line = line
+		defer func() { c.line = enclosingLine }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		c.declare(node.Statements)
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return fmt.Errorf("%d:%d: destructuring is only supported by the evaluator", node.Token.Line, node.Token.Column)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(object.NewInteger(node.Value)))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.Identifier:
+		c.loadIdentifier(node.Value)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for i, key := range node.Keys {
+			if err := c.Compile(key); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Values[i]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Keys)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionExpression:
+		return c.compileFunction(node)
+	case *ast.CallExpression:
+		if ast.IsCallTo(node, "quote") {
+			tok := node.Function.(*ast.Identifier).Token
+			return fmt.Errorf("%d:%d: quote is only supported by the evaluator", tok.Line, tok.Column)
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.NamedArgument:
+		return fmt.Errorf("%d:%d: named arguments are only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.SpreadExpression:
+		return fmt.Errorf("%d:%d: spread arguments are only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.SpawnExpression:
+		return fmt.Errorf("%d:%d: spawn is only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.MatchExpression:
+		return fmt.Errorf("%d:%d: match is only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.ImportExpression:
+		return fmt.Errorf("%d:%d: import is only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.TryExpression:
+		return fmt.Errorf("%d:%d: try is only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.ThrowStatement:
+		return fmt.Errorf("%d:%d: throw is only supported by the evaluator", node.Token.Line, node.Token.Column)
+	case *ast.MacroLiteral:
+		return fmt.Errorf("%d:%d: macros can only be defined by top-level let statements", node.Token.Line, node.Token.Column)
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Lines:        c.scopes[c.scopeIndex].lines,
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Global().Names(),
+	}
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Bogus offsets, patched once the branches are compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBranch(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBranch(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBranch leaves the value of the block on the stack, null when the block
+// doesn't end in an expression
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionExpression) error {
+	if node.Defaults != nil || node.Variadic {
+		return fmt.Errorf("%d:%d: default and rest parameters are only supported by the evaluator", node.Token.Line, node.Token.Column)
+	}
+
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	c.declare(node.Body.Statements)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	localNames := c.symbolTable.Names()
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		Lines:         lines,
+		Name:          node.Name,
+		NumLocals:     len(localNames),
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+	}
+
+	for _, s := range freeSymbols {
+		fn.FreeNames = append(fn.FreeNames, s.Name)
+		fn.Captures = append(fn.Captures, object.Capture{Local: s.Scope == LocalScope, Index: s.Index})
+	}
+
+	c.emit(code.OpClosure, c.addConstant(fn))
+	return nil
+}
+
+// loadIdentifier pushes the value bound to name. Names that aren't bound in any scope refer
+// to builtins when one is registered, otherwise they become globals that are never set so
+// the vm reports them when they are evaluated, like the evaluator does
+func (c *Compiler) loadIdentifier(name string) {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		if builtin, ok := object.LookupBuiltin(name); ok {
+			c.emit(code.OpConstant, c.addConstant(builtin))
+			return
+		}
+
+		symbol = c.symbolTable.Global().Define(name)
+	}
+
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	}
+}
+
+// declare defines the names bound by let statements before compiling the statements of a
+// scope, so functions can refer to names bound after them as they do in the evaluator
+func (c *Compiler) declare(statements []ast.Statement) {
+	for _, name := range ast.DeclaredNames(statements) {
+		c.symbolTable.Define(name)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.scopes[c.scopeIndex].lines = c.scopes[c.scopeIndex].lines.Add(pos, c.line)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+// operandNames says what the operands of op count, for the error of a program
+// that has more of them than the bytecode can address
+var operandNames = map[code.Opcode]string{
+	code.OpConstant:      "constants",
+	code.OpClosure:       "constants",
+	code.OpGetGlobal:     "global variables",
+	code.OpSetGlobal:     "global variables",
+	code.OpGetLocal:      "local variables",
+	code.OpSetLocal:      "local variables",
+	code.OpGetFree:       "free variables",
+	code.OpCall:          "call arguments",
+	code.OpArray:         "array elements",
+	code.OpHash:          "hash elements",
+	code.OpJump:          "instructions to jump over",
+	code.OpJumpNotTruthy: "instructions to jump over",
+}
+
+// checkOperands records an error for operands code.Make would truncate
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	if c.err != nil {
+		return
+	}
+	for i, o := range operands {
+		max := code.MaxOperand(op, i)
+		if o < 0 || o > max {
+			c.err = fmt.Errorf("%d: too many %s, the bytecode allows at most %d", c.line, operandNames[op], max)
+			return
+		}
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lines = c.scopes[c.scopeIndex].lines.Truncate(last.Position)
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, []int{operand})
+	c.replaceInstruction(opPos, code.Make(op, operand))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
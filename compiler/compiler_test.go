@@ -0,0 +1,352 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-1; !true",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestConditionals(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (true) { 10 } else { 20 }",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             `let one = 1; let two = "two"; one;`,
+			expectedConstants: []interface{}{1, "two"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestCollections(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "[1, 2][0]",
+			expectedConstants: []interface{}{1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `{"a": 1}`,
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestFunctions(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input: "fn(a) { let b = a; b }(1)",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestFunctionCaptures(t *testing.T) {
+	program := parser.New(lexer.New("fn(a) { fn() { fn() { a } } }")).ParseProgram()
+
+	c := New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	constants := c.Bytecode().Constants
+	innermost := constants[0].(*object.CompiledFunction)
+	middle := constants[1].(*object.CompiledFunction)
+
+	if len(middle.Captures) != 1 || middle.Captures[0] != (object.Capture{Local: true, Index: 0}) {
+		t.Errorf("wrong captures for middle function. got=%+v", middle.Captures)
+	}
+
+	if len(innermost.Captures) != 1 || innermost.Captures[0] != (object.Capture{Local: false, Index: 0}) {
+		t.Errorf("wrong captures for innermost function. got=%+v", innermost.Captures)
+	}
+}
+
+func TestIdentifierResolution(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			// builtins are constants unless a let statement shadows them
+			input:             "len; let first = 1; first",
+			expectedConstants: []interface{}{&object.Builtin{Name: "len"}, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// names bound later in the scope are resolved before they are set
+			input:             "fn() { later }; let later = 1;",
+			expectedConstants: []interface{}{[]code.Instructions{code.Make(code.OpGetGlobal, 0), code.Make(code.OpReturnValue)}, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+
+	c := New()
+	if err := c.Compile(parser.New(lexer.New("missing")).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	names := c.Bytecode().GlobalNames
+	if len(names) != 1 || names[0] != "missing" {
+		t.Errorf("unresolved names are not globals. got=%v", names)
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	var locals, args strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&locals, "let v%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+	}
+	for i := 0; i < 257; i++ {
+		fmt.Fprintf(&args, "%d,", i)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() { " + locals.String() + "vln }", "1: too many local variables, the bytecode allows at most 255"},
+		{"fn() { 1 }(" + args.String() + "0)", "1: too many call arguments, the bytecode allows at most 255"},
+	}
+
+	for _, tc := range testCases {
+		p := parser.New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		if len(p.Errors) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors)
+		}
+
+		err := New().Compile(program)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tc.expected, err)
+		}
+	}
+}
+
+func runCompilerTests(t *testing.T, testCases []compilerTestCase) {
+	t.Helper()
+
+	for _, tc := range testCases {
+		program := parser.New(lexer.New(tc.input)).ParseProgram()
+
+		c := New()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := c.Bytecode()
+		testInstructions(t, tc.input, tc.expectedInstructions, bytecode.Instructions)
+		testConstants(t, tc.input, tc.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testInstructions(t *testing.T, input string, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != actual.String() {
+		t.Errorf("wrong instructions for %q.\nwant=%q\ngot=%q", input, concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, input string, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("wrong number of constants for %q. got=%d, want=%d", input, len(actual), len(expected))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != constant {
+				t.Errorf("constant %d is not Integer %d. got=%+v", i, constant, actual[i])
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				t.Errorf("constant %d is not String %q. got=%+v", i, constant, actual[i])
+			}
+		case *object.Builtin:
+			builtin, ok := actual[i].(*object.Builtin)
+			if !ok || builtin.Name != constant.Name {
+				t.Errorf("constant %d is not Builtin %s. got=%+v", i, constant.Name, actual[i])
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("constant %d is not CompiledFunction. got=%T", i, actual[i])
+				continue
+			}
+			testInstructions(t, input, constant, fn.Instructions)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/object"
+)
+
+// FormatVersion is the version of the bytecode files written by MarshalBinary, files of any
+// other version are rejected since the opcodes may have changed between them
+const FormatVersion = 1
+
+// A bytecode file is the magic number, the format version (uint16), the length of the payload
+// (uint32), the CRC-32 of the payload (uint32) and the payload. Integers in the payload are
+// varints and strings are prefixed by their length
+var magic = []byte("MKBC")
+
+const headerSize = 14
+
+var (
+	ErrInvalidBytecode = errors.New("invalid bytecode")
+	ErrVersionMismatch = errors.New("bytecode version mismatch")
+)
+
+// Tags of the constants in the payload
+const (
+	integerConstant byte = iota + 1
+	stringConstant
+	functionConstant
+	builtinConstant
+)
+
+// MarshalBinary encodes the bytecode in the versioned file format
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+
+	e.strings(b.GlobalNames)
+
+	e.uvarint(len(b.Constants))
+	for i, constant := range b.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			e.buf.WriteByte(integerConstant)
+			e.varint(constant.Value)
+		case *object.String:
+			e.buf.WriteByte(stringConstant)
+			e.string(constant.Value)
+		case *object.CompiledFunction:
+			e.buf.WriteByte(functionConstant)
+			e.function(constant)
+		case *object.Builtin:
+			e.buf.WriteByte(builtinConstant)
+			e.string(constant.Name)
+		default:
+			return nil, fmt.Errorf("constant %d can't be encoded: %s", i, constant.Type())
+		}
+	}
+
+	e.bytes(b.Instructions)
+	e.lines(b.Lines)
+
+	payload := e.buf.Bytes()
+	data := make([]byte, headerSize, headerSize+len(payload))
+	copy(data, magic)
+	binary.BigEndian.PutUint16(data[4:], FormatVersion)
+	binary.BigEndian.PutUint32(data[6:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(data[10:], crc32.ChecksumIEEE(payload))
+
+	return append(data, payload...), nil
+}
+
+// WriteTo writes the bytecode in the versioned file format
+func (b *Bytecode) WriteTo(w io.Writer) (int64, error) {
+	data, err := b.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// ReadBytecode loads and validates a bytecode file
+func ReadBytecode(r io.Reader) (*Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnmarshalBytecode(data)
+}
+
+// UnmarshalBytecode decodes and validates a bytecode file, errors wrap ErrVersionMismatch when
+// the file was written by another version of the format and ErrInvalidBytecode otherwise.
+// Builtins are looked up by name, so the ones registered from Go when the file was built
+// must be registered before loading it
+func UnmarshalBytecode(data []byte) (*Bytecode, error) {
+	if len(data) < headerSize || !bytes.Equal(data[:4], magic) {
+		return nil, fmt.Errorf("%w: not a compiled monkey program", ErrInvalidBytecode)
+	}
+
+	if version := binary.BigEndian.Uint16(data[4:]); version != FormatVersion {
+		return nil, fmt.Errorf("%w: file has version %d, want version %d, compile the script again",
+			ErrVersionMismatch, version, FormatVersion)
+	}
+
+	payload := data[headerSize:]
+	if length := binary.BigEndian.Uint32(data[6:]); int(length) != len(payload) {
+		return nil, fmt.Errorf("%w: payload has %d bytes, want %d, the file is truncated or corrupt",
+			ErrInvalidBytecode, len(payload), length)
+	}
+
+	if binary.BigEndian.Uint32(data[10:]) != crc32.ChecksumIEEE(payload) {
+		return nil, fmt.Errorf("%w: checksum mismatch, the file is corrupt", ErrInvalidBytecode)
+	}
+
+	d := &decoder{data: payload}
+	bytecode := &Bytecode{GlobalNames: d.strings()}
+
+	numConstants := d.length()
+	bytecode.Constants = make([]object.Object, 0, numConstants)
+	for i := 0; i < numConstants && d.err == nil; i++ {
+		var constant object.Object
+
+		switch tag := d.byte(); tag {
+		case integerConstant:
+			constant = object.NewInteger(d.varint())
+		case stringConstant:
+			constant = &object.String{Value: d.string()}
+		case functionConstant:
+			constant = d.function()
+		case builtinConstant:
+			name := d.string()
+			builtin, ok := object.LookupBuiltin(name)
+			if !ok && d.err == nil {
+				return nil, fmt.Errorf("%w: builtin `%s` is not registered", ErrInvalidBytecode, name)
+			}
+			constant = builtin
+		default:
+			d.fail(fmt.Sprintf("unknown tag %d for constant %d", tag, i))
+		}
+
+		bytecode.Constants = append(bytecode.Constants, constant)
+	}
+
+	bytecode.Instructions = d.bytes()
+	bytecode.Lines = d.lines()
+
+	if d.err == nil && len(d.data) > 0 {
+		d.fail(fmt.Sprintf("%d unexpected bytes after the program", len(d.data)))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if err := bytecode.Validate(); err != nil {
+		return nil, err
+	}
+
+	return bytecode, nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uvarint(v int) {
+	e.buf.Write(binary.AppendUvarint(nil, uint64(v)))
+}
+
+func (e *encoder) varint(v int) {
+	e.buf.Write(binary.AppendVarint(nil, int64(v)))
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(len(b))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(len(s))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) strings(ss []string) {
+	e.uvarint(len(ss))
+	for _, s := range ss {
+		e.string(s)
+	}
+}
+
+func (e *encoder) lines(lines code.LineTable) {
+	e.uvarint(len(lines))
+	for _, entry := range lines {
+		e.uvarint(entry.Offset)
+		e.uvarint(entry.Line)
+	}
+}
+
+func (e *encoder) function(fn *object.CompiledFunction) {
+	e.string(fn.Name)
+	e.uvarint(fn.NumLocals)
+	e.uvarint(fn.NumParameters)
+	e.strings(fn.LocalNames)
+	e.strings(fn.FreeNames)
+
+	e.uvarint(len(fn.Captures))
+	for _, capture := range fn.Captures {
+		if capture.Local {
+			e.buf.WriteByte(1)
+		} else {
+			e.buf.WriteByte(0)
+		}
+		e.uvarint(capture.Index)
+	}
+
+	e.bytes(fn.Instructions)
+	e.lines(fn.Lines)
+}
+
+// decoder reads the payload, after the first error every read returns a zero value so the
+// error is only checked once the payload is read
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(reason string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrInvalidBytecode, reason)
+	}
+	d.data = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail("unexpected end of data")
+		return 0
+	}
+
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uvarint() int {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 || v > uint64(maxInt) {
+		d.fail("malformed integer")
+		return 0
+	}
+
+	d.data = d.data[n:]
+	return int(v)
+}
+
+func (d *decoder) varint() int {
+	v, n := binary.Varint(d.data)
+	if n <= 0 || v > int64(maxInt) || v < int64(minInt) {
+		d.fail("malformed integer")
+		return 0
+	}
+
+	d.data = d.data[n:]
+	return int(v)
+}
+
+// length reads the size of a list, every element takes at least a byte so larger sizes
+// can only come from a corrupt file
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > len(d.data) {
+		d.fail("unexpected end of data")
+		return 0
+	}
+
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.length()
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) strings() []string {
+	n := d.length()
+	ss := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		ss = append(ss, d.string())
+	}
+
+	return ss
+}
+
+func (d *decoder) lines() code.LineTable {
+	n := d.length()
+	lines := make(code.LineTable, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		lines = append(lines, code.LineEntry{Offset: d.uvarint(), Line: d.uvarint()})
+	}
+
+	return lines
+}
+
+func (d *decoder) function() *object.CompiledFunction {
+	fn := &object.CompiledFunction{
+		Name:          d.string(),
+		NumLocals:     d.uvarint(),
+		NumParameters: d.uvarint(),
+		LocalNames:    d.strings(),
+		FreeNames:     d.strings(),
+	}
+
+	n := d.length()
+	for i := 0; i < n && d.err == nil; i++ {
+		local := d.byte() == 1
+		fn.Captures = append(fn.Captures, object.Capture{Local: local, Index: d.uvarint()})
+	}
+
+	fn.Instructions = d.bytes()
+	fn.Lines = d.lines()
+	return fn
+}
+
+const (
+	maxInt = int(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
@@ -0,0 +1,314 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/parser"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	input := `let greeting = "hello";
+let adder = fn(x) {
+  fn(y) { x + y - -1 }
+};
+len([adder(1)(2), {true: greeting}]);`
+
+	c := New()
+	if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	original := c.Bytecode()
+
+	var buf bytes.Buffer
+	if _, err := original.WriteTo(&buf); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	loaded, err := ReadBytecode(&buf)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	if loaded.Instructions.String() != original.Instructions.String() {
+		t.Errorf("wrong instructions.\nwant=%q\ngot=%q", original.Instructions, loaded.Instructions)
+	}
+
+	if strings.Join(loaded.GlobalNames, ",") != strings.Join(original.GlobalNames, ",") {
+		t.Errorf("wrong global names. want=%v, got=%v", original.GlobalNames, loaded.GlobalNames)
+	}
+
+	testLineTable(t, original.Lines, loaded.Lines)
+
+	if len(loaded.Constants) != len(original.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(original.Constants), len(loaded.Constants))
+	}
+
+	for i, constant := range original.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFunction:
+			fn, ok := loaded.Constants[i].(*object.CompiledFunction)
+			if !ok {
+				t.Fatalf("constant %d is not CompiledFunction. got=%T", i, loaded.Constants[i])
+			}
+			if fn.Instructions.String() != constant.Instructions.String() ||
+				fn.Name != constant.Name ||
+				fn.NumLocals != constant.NumLocals ||
+				fn.NumParameters != constant.NumParameters ||
+				len(fn.Captures) != len(constant.Captures) {
+				t.Errorf("constant %d is a different function. want=%+v, got=%+v", i, constant, fn)
+			}
+			testLineTable(t, constant.Lines, fn.Lines)
+		case *object.Builtin:
+			if loaded.Constants[i] != constant {
+				t.Errorf("constant %d is not the builtin %s. got=%+v", i, constant.Name, loaded.Constants[i])
+			}
+		default:
+			if loaded.Constants[i].Inspect() != constant.Inspect() {
+				t.Errorf("constant %d is wrong. want=%s, got=%s", i, constant.Inspect(), loaded.Constants[i].Inspect())
+			}
+		}
+	}
+}
+
+func TestLineTables(t *testing.T) {
+	input := `let a = 1;
+
+let f = fn() {
+  a
+};
+f();`
+
+	c := New()
+	if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := c.Bytecode()
+
+	testLineTable(t, code.LineTable{{Offset: 0, Line: 1}, {Offset: 6, Line: 3}, {Offset: 12, Line: 6}}, bytecode.Lines)
+
+	fn := bytecode.Constants[1].(*object.CompiledFunction)
+	testLineTable(t, code.LineTable{{Offset: 0, Line: 4}}, fn.Lines)
+}
+
+func TestUnmarshalBytecodeErrors(t *testing.T) {
+	c := New()
+	if err := c.Compile(parser.New(lexer.New(`let f = fn(x) { x }; f("a")`)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	valid, err := c.Bytecode().MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	modified := func(change func(data []byte) []byte) []byte {
+		data := append([]byte{}, valid...)
+		return change(data)
+	}
+
+	testCases := []struct {
+		name            string
+		data            []byte
+		expectedErr     error
+		expectedMessage string
+	}{
+		{
+			"empty file",
+			[]byte{},
+			ErrInvalidBytecode,
+			"invalid bytecode: not a compiled monkey program",
+		},
+		{
+			"source file",
+			[]byte("let a = 1; let b = 2;"),
+			ErrInvalidBytecode,
+			"invalid bytecode: not a compiled monkey program",
+		},
+		{
+			"newer version",
+			modified(func(data []byte) []byte {
+				binary.BigEndian.PutUint16(data[4:], FormatVersion+1)
+				return data
+			}),
+			ErrVersionMismatch,
+			"bytecode version mismatch: file has version 2, want version 1, compile the script again",
+		},
+		{
+			"truncated",
+			valid[:len(valid)-3],
+			ErrInvalidBytecode,
+			"payload has",
+		},
+		{
+			"flipped bit",
+			modified(func(data []byte) []byte {
+				data[len(data)-5] ^= 1
+				return data
+			}),
+			ErrInvalidBytecode,
+			"invalid bytecode: checksum mismatch, the file is corrupt",
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := UnmarshalBytecode(tc.data)
+		if !errors.Is(err, tc.expectedErr) {
+			t.Errorf("%s: error does not wrap %v. got=%v", tc.name, tc.expectedErr, err)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), tc.expectedMessage) {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tc.name, tc.expectedMessage, err.Error())
+		}
+	}
+}
+
+func TestUnmarshalUnknownBuiltin(t *testing.T) {
+	bytecode := &Bytecode{
+		Instructions: code.Make(code.OpConstant, 0),
+		Constants:    []object.Object{&object.Builtin{Name: "notRegistered"}},
+	}
+
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	_, err = UnmarshalBytecode(data)
+	if err == nil || err.Error() != "invalid bytecode: builtin `notRegistered` is not registered" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	concat := func(instructions ...[]byte) code.Instructions {
+		out := code.Instructions{}
+		for _, ins := range instructions {
+			out = append(out, ins...)
+		}
+		return out
+	}
+
+	function := func(numLocals int, captures []object.Capture, instructions ...[]byte) *object.CompiledFunction {
+		return &object.CompiledFunction{NumLocals: numLocals, Captures: captures, Instructions: concat(instructions...)}
+	}
+
+	testCases := []struct {
+		bytecode        *Bytecode
+		expectedMessage string
+	}{
+		{
+			&Bytecode{Instructions: code.Instructions{255}},
+			"invalid bytecode: opcode 255 undefined at offset 0000 of the program",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpConstant, 1)[:2]},
+			"invalid bytecode: truncated OpConstant at offset 0000 of the program",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpConstant, 1)},
+			"invalid bytecode: constant 1 out of range at offset 0000 of the program",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetLocal, 0)},
+			"invalid bytecode: local 0 out of range at offset 0000 of the program",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpJump, 2))},
+			"invalid bytecode: jump to 0002 is not the start of an instruction at offset 0001 of the program",
+		},
+		{
+			&Bytecode{
+				Instructions: code.Make(code.OpClosure, 0),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+			},
+			"invalid bytecode: constant 0 is not a function at offset 0000 of the program",
+		},
+		{
+			&Bytecode{
+				Instructions: code.Make(code.OpClosure, 0),
+				Constants: []object.Object{
+					function(0, []object.Capture{{Local: true, Index: 0}}, code.Make(code.OpReturn)),
+				},
+			},
+			"invalid bytecode: closure of constant 0 captures a variable out of range at offset 0000 of the program",
+		},
+		{
+			&Bytecode{
+				Constants: []object.Object{function(1, nil, code.Make(code.OpGetFree, 0), code.Make(code.OpReturnValue))},
+			},
+			"invalid bytecode: free variable 0 out of range at offset 0000 of function 0",
+		},
+		{
+			&Bytecode{
+				Constants: []object.Object{function(1, nil, code.Make(code.OpGetLocal, 0))},
+			},
+			"invalid bytecode: function 0 doesn't end with a return",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpPop)},
+			"invalid bytecode: OpPop pops 1 values from a stack of 0 at offset 0000 of the program",
+		},
+		{
+			&Bytecode{Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpAdd))},
+			"invalid bytecode: OpAdd pops 2 values from a stack of 1 at offset 0001 of the program",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpArray, 60000)},
+			"invalid bytecode: OpArray pops 60000 values from a stack of 0 at offset 0000 of the program",
+		},
+		{
+			// the branch that skips the constant leaves nothing to pop
+			&Bytecode{
+				Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 7), code.Make(code.OpConstant, 0), code.Make(code.OpPop)),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+			},
+			"invalid bytecode: OpPop pops 1 values from a stack of 0 at offset 0007 of the program",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpReturn)},
+			"invalid bytecode: return outside of a function at offset 0000 of the program",
+		},
+		{
+			&Bytecode{
+				Constants: []object.Object{function(0, nil, code.Make(code.OpReturnValue))},
+			},
+			"invalid bytecode: OpReturnValue pops 1 values from a stack of 0 at offset 0000 of function 0",
+		},
+	}
+
+	for _, tc := range testCases {
+		err := tc.bytecode.Validate()
+		if err == nil {
+			t.Errorf("invalid bytecode accepted. expected=%q", tc.expectedMessage)
+			continue
+		}
+
+		if err.Error() != tc.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tc.expectedMessage, err.Error())
+		}
+	}
+}
+
+func testLineTable(t *testing.T, expected, actual code.LineTable) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Errorf("wrong line table. want=%+v, got=%+v", expected, actual)
+		return
+	}
+
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("wrong line table. want=%+v, got=%+v", expected, actual)
+			return
+		}
+	}
+}
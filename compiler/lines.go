@@ -0,0 +1,43 @@
+package compiler
+
+import "go-interpreter.com/m/ast"
+
+// nodeLine returns the source line where node starts, 0 for nodes without a position
+func nodeLine(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token.Line
+	case *ast.ReturnStatement:
+		return node.Token.Line
+	case *ast.ExpressionStatement:
+		return node.Token.Line
+	case *ast.BlockStatement:
+		return node.Token.Line
+	case *ast.Identifier:
+		return node.Token.Line
+	case *ast.IntegerLiteral:
+		return node.Token.Line
+	case *ast.StringLiteral:
+		return node.Token.Line
+	case *ast.Boolean:
+		return node.Token.Line
+	case *ast.PrefixExpression:
+		return node.Token.Line
+	case *ast.InfixExpression:
+		return node.Token.Line
+	case *ast.IfExpression:
+		return node.Token.Line
+	case *ast.FunctionExpression:
+		return node.Token.Line
+	case *ast.CallExpression:
+		return node.Token.Line
+	case *ast.ArrayLiteral:
+		return node.Token.Line
+	case *ast.IndexExpression:
+		return node.Token.Line
+	case *ast.HashLiteral:
+		return node.Token.Line
+	}
+
+	return 0
+}
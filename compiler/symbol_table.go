@@ -0,0 +1,96 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// FreeSymbols holds the symbols of the enclosing scopes captured by this one,
+	// indexed like the free variables of the closure
+	FreeSymbols []Symbol
+
+	store map[string]Symbol
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in this scope, defining a name twice returns the existing symbol since
+// a let statement overwrites the binding of the same scope
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: len(s.names), Scope: LocalScope}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// Resolve looks name up through the enclosing scopes, locals of an enclosing function
+// become free symbols of this scope
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// Global returns the outermost scope
+func (s *SymbolTable) Global() *SymbolTable {
+	if s.Outer == nil {
+		return s
+	}
+
+	return s.Outer.Global()
+}
+
+// Names returns the names defined in this scope indexed by their slot
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
+
+// NumDefinitions is the number of slots needed by the names defined in this scope
+func (s *SymbolTable) NumDefinitions() int {
+	return len(s.names)
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
@@ -0,0 +1,71 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(global)
+
+	testCases := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{global, "b", Symbol{Name: "b", Scope: GlobalScope, Index: 1}},
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{local, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{local, "a", Symbol{Name: "a", Scope: LocalScope, Index: 1}},
+	}
+
+	for _, tc := range testCases {
+		if symbol := tc.table.Define(tc.name); symbol != tc.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tc.name, tc.expected, symbol)
+		}
+	}
+
+	if global.NumDefinitions() != 2 || local.NumDefinitions() != 2 {
+		t.Errorf("wrong number of definitions. global=%d, local=%d", global.NumDefinitions(), local.NumDefinitions())
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	testCases := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tc := range testCases {
+		symbol, ok := secondLocal.Resolve(tc.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tc.name)
+			continue
+		}
+
+		if symbol != tc.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tc.name, tc.expected, symbol)
+		}
+	}
+
+	expectedFree := []Symbol{{Name: "b", Scope: LocalScope, Index: 0}}
+	if len(secondLocal.FreeSymbols) != len(expectedFree) || secondLocal.FreeSymbols[0] != expectedFree[0] {
+		t.Errorf("wrong free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+
+	if _, ok := secondLocal.Resolve("d"); ok {
+		t.Errorf("name d resolved but was never defined")
+	}
+}
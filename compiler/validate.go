@@ -0,0 +1,210 @@
+package compiler
+
+import (
+	"fmt"
+
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/object"
+)
+
+// Validate checks that the vm can run the bytecode without reading outside of the
+// instructions, the constants, the stack or the variables of a function. Compiled programs are always
+// valid, it's meant for bytecode loaded from files
+func (b *Bytecode) Validate() error {
+	if err := b.validateInstructions("the program", b.Instructions, 0, 0, false); err != nil {
+		return err
+	}
+
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		name := fmt.Sprintf("function %d", i)
+		if fn.Name != "" {
+			name = fmt.Sprintf("function %d (%s)", i, fn.Name)
+		}
+
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("%w: %s has %d parameters but %d locals", ErrInvalidBytecode, name, fn.NumParameters, fn.NumLocals)
+		}
+
+		if err := b.validateInstructions(name, fn.Instructions, fn.NumLocals, len(fn.Captures), true); err != nil {
+			return err
+		}
+
+		last, err := lastOpcode(fn.Instructions)
+		if err != nil || last != code.OpReturn && last != code.OpReturnValue {
+			return fmt.Errorf("%w: %s doesn't end with a return", ErrInvalidBytecode, name)
+		}
+	}
+
+	return nil
+}
+
+// decodedInstruction is an instruction whose operands were read by validateInstructions
+type decodedInstruction struct {
+	op       code.Opcode
+	def      *code.Definition
+	operands []int
+	next     int
+}
+
+func (b *Bytecode) validateInstructions(name string, ins code.Instructions, numLocals, numFree int, function bool) error {
+	invalid := func(offset int, format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s at offset %04d of %s", ErrInvalidBytecode, fmt.Sprintf(format, args...), offset, name)
+	}
+
+	decoded := map[int]decodedInstruction{}
+	jumps := map[int]int{}
+
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			return invalid(offset, "%s", err)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if offset+1+width > len(ins) {
+			return invalid(offset, "truncated %s", def.Name)
+		}
+
+		operands, _ := code.ReadOperands(def, ins[offset+1:])
+		decoded[offset] = decodedInstruction{code.Opcode(ins[offset]), def, operands, offset + 1 + width}
+
+		switch code.Opcode(ins[offset]) {
+		case code.OpConstant:
+			if operands[0] >= len(b.Constants) {
+				return invalid(offset, "constant %d out of range", operands[0])
+			}
+		case code.OpClosure:
+			if operands[0] >= len(b.Constants) {
+				return invalid(offset, "constant %d out of range", operands[0])
+			}
+			fn, ok := b.Constants[operands[0]].(*object.CompiledFunction)
+			if !ok {
+				return invalid(offset, "constant %d is not a function", operands[0])
+			}
+			for _, capture := range fn.Captures {
+				if capture.Local && capture.Index >= numLocals || !capture.Local && capture.Index >= numFree {
+					return invalid(offset, "closure of constant %d captures a variable out of range", operands[0])
+				}
+			}
+		case code.OpGetLocal, code.OpSetLocal:
+			if operands[0] >= numLocals {
+				return invalid(offset, "local %d out of range", operands[0])
+			}
+		case code.OpGetFree:
+			if operands[0] >= numFree {
+				return invalid(offset, "free variable %d out of range", operands[0])
+			}
+		case code.OpHash:
+			if operands[0]%2 != 0 {
+				return invalid(offset, "odd number of hash elements %d", operands[0])
+			}
+		case code.OpJump, code.OpJumpNotTruthy:
+			jumps[offset] = operands[0]
+		case code.OpReturn:
+			if !function {
+				return invalid(offset, "return outside of a function")
+			}
+		}
+
+		offset += 1 + width
+	}
+
+	for offset, target := range jumps {
+		if _, ok := decoded[target]; target != len(ins) && !ok {
+			return invalid(offset, "jump to %04d is not the start of an instruction", target)
+		}
+	}
+
+	return validateStack(decoded, invalid)
+}
+
+// validateStack follows every path through the instructions and checks that none of them
+// pops more values than the previous instructions pushed. An instruction is walked again only
+// when a path reaches it with fewer values than before, so loops end
+func validateStack(decoded map[int]decodedInstruction, invalid func(int, string, ...interface{}) error) error {
+	type path struct{ offset, depth int }
+	paths := []path{{0, 0}}
+	depths := map[int]int{}
+
+	for len(paths) > 0 {
+		p := paths[len(paths)-1]
+		paths = paths[:len(paths)-1]
+
+		instruction, ok := decoded[p.offset]
+		if !ok {
+			continue // the end of the instructions
+		}
+		if depth, seen := depths[p.offset]; seen && depth <= p.depth {
+			continue
+		}
+		depths[p.offset] = p.depth
+
+		pops, pushes := instruction.stackEffect()
+		if p.depth < pops {
+			return invalid(p.offset, "%s pops %d values from a stack of %d", instruction.def.Name, pops, p.depth)
+		}
+		depth := p.depth - pops + pushes
+
+		switch instruction.op {
+		case code.OpReturn, code.OpReturnValue:
+		case code.OpJump:
+			paths = append(paths, path{instruction.operands[0], depth})
+		case code.OpJumpNotTruthy:
+			paths = append(paths, path{instruction.operands[0], depth}, path{instruction.next, depth})
+		default:
+			paths = append(paths, path{instruction.next, depth})
+		}
+	}
+
+	return nil
+}
+
+// stackEffect returns how many values the instruction pops from the stack and pushes on it
+func (ins decodedInstruction) stackEffect() (pops, pushes int) {
+	switch ins.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetFree, code.OpClosure:
+		return 0, 1
+	case code.OpPop, code.OpSetGlobal, code.OpSetLocal, code.OpJumpNotTruthy, code.OpReturnValue:
+		return 1, 0
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan, code.OpIndex:
+		return 2, 1
+	case code.OpMinus, code.OpBang:
+		return 1, 1
+	case code.OpArray, code.OpHash:
+		return ins.operands[0], 1
+	case code.OpCall:
+		return ins.operands[0] + 1, 1
+	default:
+		return 0, 0
+	}
+}
+
+func lastOpcode(ins code.Instructions) (code.Opcode, error) {
+	var last code.Opcode
+	if len(ins) == 0 {
+		return last, fmt.Errorf("no instructions")
+	}
+
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			return last, err
+		}
+
+		last = code.Opcode(ins[offset])
+		_, read := code.ReadOperands(def, ins[offset+1:])
+		offset += 1 + read
+	}
+
+	return last, nil
+}
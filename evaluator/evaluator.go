@@ -1,45 +1,669 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
 	"go-interpreter.com/m/ast"
 	"go-interpreter.com/m/object"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+)
+
+// Errors wrapped by the runtime errors returned when a script goes over the limits of its environment
+var (
+	ErrStepBudgetExceeded  = object.ErrStepBudgetExceeded
+	ErrMemoryLimitExceeded = object.ErrMemoryLimitExceeded
+	ErrCallDepthExceeded   = object.ErrCallDepthExceeded
 )
 
-func Eval(node ast.Node) object.Object {
+// cancelCheckInterval is the number of evaluated nodes between checks of the context
+const cancelCheckInterval = 1024
+
+// evaluator holds the state of a single evaluation, the limits come from the
+// environment the evaluation started in
+type evaluator struct {
+	limits    *object.Limits
+	callChain []string // names of the functions being applied, outermost first
+	imports   []string // files of the modules being loaded, the importing script first
+
+	ctx   context.Context
+	steps int
+
+	// totalSteps counts the steps of every task of the evaluation once it spawns one
+	totalSteps *int64
+
+	tasks      []*object.Task // spawned by this evaluator, they are waited for before it returns
+	tasksCtx   context.Context
+	cancelTask context.CancelFunc
+}
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, env)
+}
+
+// EvalContext evaluates node like Eval but stops with an error wrapping ctx.Err()
+// once ctx is done, or with one wrapping ErrStepBudgetExceeded when the evaluation
+// goes over the MaxSteps limit of env
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	ctx, cancel := object.WithScheduler(ctx)
+	defer cancel()
+
+	e := &evaluator{limits: env.Limits(), ctx: ctx}
+	return e.finish(e.eval(node, env))
+}
+
+// ApplyFunction calls a function or builtin with args as a call expression would, the
+// limits of env bound the call
+func ApplyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return ApplyFunctionContext(context.Background(), fn, args, env)
+}
+
+// ApplyFunctionContext is like ApplyFunction but stops the call once ctx is done
+func ApplyFunctionContext(ctx context.Context, fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	ctx, cancel := object.WithScheduler(ctx)
+	defer cancel()
+
+	e := &evaluator{limits: env.Limits(), ctx: ctx}
+	return e.finish(e.applyFunction(fn, args))
+}
+
+// eval evaluates node locating the runtime errors at the innermost node that failed
+func (e *evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	result := e.evalNode(node, env)
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Line != 0 {
+		return result
+	}
+
+	pos := ast.Position(node)
+	if pos.Line == 0 {
+		return result
+	}
+
+	// errors may be shared by tasks awaiting the same one, they are copied instead of modified
+	located := *err
+	located.Line, located.Column = pos.Line, pos.Column
+	return &located
+}
+
+func (e *evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgramStatements(node.Statements)
+		return e.evalProgramStatements(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return e.eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return e.evalBlockStatement(node, env)
+	case *ast.ReturnStatement:
+		val := e.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := e.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return thrownError(val)
+	case *ast.LetStatement:
+		val := e.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if node.Pattern != nil {
+			if err := e.destructure(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
+		if node.Name.Local {
+			env.SetAt(node.Name.Slot, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return object.NewInteger(node.Value)
 	case *ast.Boolean:
 		return nativeBoolToObject(node.Value)
 	case *ast.PrefixExpression:
-		return evalPrefixExpression(node.Operator, Eval(node.Right))
+		right := e.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		return evalInfixExpression(node.Operator, Eval(node.Left), Eval(node.Right))
+		left := e.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := e.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return e.evalInfixExpression(node.Operator, left, right)
+	case *ast.IfExpression:
+		return e.evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.FunctionExpression:
+		return &object.Function{
+			Name: node.Name, Parameters: node.Parameters, Defaults: node.Defaults, Variadic: node.Variadic,
+			Body: node.Body, Env: env, Locals: node.Locals,
+		}
+	case *ast.CallExpression:
+		if ast.IsCallTo(node, "quote") {
+			return e.quote(node.Arguments[0], env)
+		}
+		function := e.eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args, err := e.evalArguments(function, node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		return e.applyFunction(function, args)
+	case *ast.SpreadExpression:
+		value := e.eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		if _, ok := value.(*object.Array); !ok {
+			return newKindError(object.TypeErrorKind, "cannot spread %s, want ARRAY", value.Type())
+		}
+		return value
+	case *ast.StringLiteral:
+		if err := e.allocate(len(node.Value)); err != nil {
+			return err
+		}
+		return &object.String{Value: node.Value}
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		if err := e.allocate(object.ArraySize(len(elements))); err != nil {
+			return err
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := e.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *ast.SpawnExpression:
+		return e.evalSpawnExpression(node, env)
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env)
+	case *ast.ImportExpression:
+		return e.evalImportExpression(node, env)
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined by top-level let statements")
 	}
 
 	return nil
 }
 
-func evalProgramStatements(statements []ast.Statement) object.Object {
+// step counts an evaluated node against the step budget and checks the context every
+// cancelCheckInterval nodes
+func (e *evaluator) step() *object.Error {
+	if e.steps%cancelCheckInterval == 0 {
+		select {
+		case <-e.ctx.Done():
+			return object.NewInterruptedError(e.ctx.Err())
+		default:
+		}
+	}
+
+	e.steps++
+	if e.totalSteps != nil {
+		return e.limits.CheckSteps(int(atomic.AddInt64(e.totalSteps, 1)))
+	}
+
+	return e.limits.CheckSteps(e.steps)
+}
+
+// allocate accounts size bytes against the memory limit, the caller must not build the
+// object when it returns an error
+func (e *evaluator) allocate(size int) *object.Error {
+	return e.limits.Reserve(size)
+}
+
+func (e *evaluator) evalSpawnExpression(node *ast.SpawnExpression, env *object.Environment) object.Object {
+	call, ok := node.Function.(*ast.CallExpression)
+	if !ok {
+		call = &ast.CallExpression{Function: node.Function}
+	}
+
+	function := e.eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+
+	switch function.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newKindError(object.TypeErrorKind, "not a function: %s", function.Type())
+	}
+
+	args, err := e.evalArguments(function, call.Arguments, env)
+	if err != nil {
+		return err
+	}
+
+	return e.spawn(function, args)
+}
+
+// spawn applies fn to args on a new goroutine. The task shares the limits and the step budget
+// of the evaluation and it's cancelled when the evaluator that spawned it fails
+func (e *evaluator) spawn(fn object.Object, args []object.Object) *object.Task {
+	if e.cancelTask == nil {
+		e.tasksCtx, e.cancelTask = context.WithCancel(e.ctx)
+	}
+	if e.totalSteps == nil {
+		e.totalSteps = new(int64)
+		*e.totalSteps = int64(e.steps)
+	}
+
+	object.Share(fn)
+	for _, arg := range args {
+		object.Share(arg)
+	}
+
+	task := object.NewTask(e.tasksCtx)
+	e.tasks = append(e.tasks, task)
+
+	child := &evaluator{limits: e.limits, ctx: e.tasksCtx, totalSteps: e.totalSteps, imports: e.imports}
+	go func() {
+		task.Finish(child.finish(child.applyFunction(fn, args)))
+	}()
+
+	return task
+}
+
+// finish waits for the tasks spawned by the evaluator before returning its result. The tasks
+// are cancelled once the evaluator or a task nobody awaited fails, the error of the first of
+// those tasks in spawn order is returned when the evaluator itself succeeded
+func (e *evaluator) finish(result object.Object) object.Object {
+	if e.cancelTask == nil {
+		return result
+	}
+	defer e.cancelTask()
+
+	if isError(result) {
+		e.cancelTask()
+	}
+
+	cancelled := false
+	pending := append([]*object.Task{}, e.tasks...)
+	for len(pending) > 0 {
+		i := object.WaitAny(e.ctx, pending)
+		if task := pending[i]; isError(task.Result()) && !task.Awaited() {
+			e.cancelTask()
+			cancelled = true
+		}
+		pending = append(pending[:i], pending[i+1:]...)
+	}
+
+	if isError(result) {
+		return result
+	}
+
+	for _, task := range e.tasks {
+		err, ok := task.Result().(*object.Error)
+		if !ok || task.Awaited() {
+			continue
+		}
+
+		// tasks interrupted because a sibling failed
+		if cancelled && errors.Is(err, context.Canceled) && e.ctx.Err() == nil {
+			continue
+		}
+
+		return err
+	}
+
+	return result
+}
+
+func (e *evaluator) evalProgramStatements(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range statements {
-		result = Eval(stmt)
+		result = e.eval(stmt, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement keeps return values wrapped so they stop the evaluation of every enclosing block
+func (e *evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, stmt := range block.Statements {
+		result = e.eval(stmt, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.ReturnValue_Obj || rt == object.Error_Obj {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func (e *evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return e.eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return e.eval(ie.Alternative, env)
+	}
+
+	return NULL
+}
+
+func (e *evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := make([]object.Object, 0, len(exps))
+
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
 	}
 
 	return result
 }
 
+// evalArguments evaluates the arguments of a call to fn, the elements of the arrays spread
+// with ...array are passed as separate arguments. The arguments passed by name are placed
+// at the position of their parameter, see bindNamedArguments, or collected in the keyword
+// hash that builtins get as their last argument
+func (e *evaluator) evalArguments(fn object.Object, exps []ast.Expression, env *object.Environment) ([]object.Object, *object.Error) {
+	args := make([]object.Object, 0, len(exps))
+	var named []namedArgument
+
+	for _, exp := range exps {
+		if arg, ok := exp.(*ast.NamedArgument); ok {
+			value := e.eval(arg.Value, env)
+			if err, ok := value.(*object.Error); ok {
+				return nil, err
+			}
+			named = append(named, namedArgument{name: arg.Name, value: value})
+			continue
+		}
+
+		evaluated := e.eval(exp, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			return nil, err
+		}
+
+		if _, ok := exp.(*ast.SpreadExpression); ok {
+			args = append(args, evaluated.(*object.Array).Elements...)
+			continue
+		}
+		args = append(args, evaluated)
+	}
+
+	if named == nil {
+		return args, nil
+	}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		return bindNamedArguments(fn, args, named)
+	case *object.Builtin:
+		if !fn.Keywords {
+			return nil, newKindError(object.TypeErrorKind, "`%s` doesn't take named arguments", fn.Name)
+		}
+
+		keywords := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(named))}
+		for _, arg := range named {
+			key := &object.String{Value: arg.name}
+			keywords.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: arg.value}
+		}
+		if err := e.allocate(object.SizeOf(keywords)); err != nil {
+			return nil, err
+		}
+		return append(args, keywords), nil
+	}
+
+	return args, nil
+}
+
+type namedArgument struct {
+	name  string
+	value object.Object
+}
+
+// bindNamedArguments returns the arguments of a call to fn with the ones passed by name at
+// the position of their parameter, the parameters left without argument are nil so they
+// get their default value. The rest parameter can't be passed by name
+func bindNamedArguments(fn *object.Function, args []object.Object, named []namedArgument) ([]object.Object, *object.Error) {
+	fixed := len(fn.Parameters)
+	if fn.Variadic {
+		fixed--
+	}
+	if !fn.Variadic && len(args) > fixed {
+		return nil, checkArity(fn, len(args)+len(named))
+	}
+
+	bound := make([]object.Object, fixed, len(args)+fixed)
+	copy(bound, args)
+	if len(args) > fixed {
+		bound = append(bound, args[fixed:]...)
+	}
+
+	for _, arg := range named {
+		i := 0
+		for i < fixed && fn.Parameters[i].Value != arg.name {
+			i++
+		}
+
+		switch {
+		case i == fixed:
+			return nil, functionError(fn, "unknown argument %s", arg.name)
+		case bound[i] != nil:
+			return nil, functionError(fn, "argument %s passed twice", arg.name)
+		}
+		bound[i] = arg.value
+	}
+
+	for i, arg := range bound[:fixed] {
+		if arg == nil && (i >= len(fn.Defaults) || fn.Defaults[i] == nil) {
+			return nil, functionError(fn, "missing argument %s", fn.Parameters[i].Value)
+		}
+	}
+
+	return bound, nil
+}
+
+func (e *evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return e.applyBuiltin(builtin, args)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newKindError(object.TypeErrorKind, "not a function: %s", fn.Type())
+	}
+
+	if err := checkArity(function, len(args)); err != nil {
+		return err
+	}
+
+	if err := e.limits.CheckCallDepth(e.callChain); err != nil {
+		return err
+	}
+
+	name := function.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	e.callChain = append(e.callChain, name)
+	defer func() { e.callChain = e.callChain[:len(e.callChain)-1] }()
+
+	env, err := e.extendFunctionEnv(function, args)
+	if err != nil {
+		return err
+	}
+
+	evaluated := e.eval(function.Body, env)
+	return unwrapReturnValue(evaluated)
+}
+
+// checkArity reports calls to fn with got arguments, like "add: expected 2..3 arguments, got 5"
+func checkArity(fn *object.Function, got int) *object.Error {
+	min, max := fn.Arity()
+	if got >= min && (max < 0 || got <= max) {
+		return nil
+	}
+
+	return object.NewArityError(fn.Name, min, max, got)
+}
+
+// functionError is a type error about a call to fn, prefixed by its name when it has one
+func functionError(fn *object.Function, format string, a ...interface{}) *object.Error {
+	message := fmt.Sprintf(format, a...)
+	if fn.Name != "" {
+		message = fn.Name + ": " + message
+	}
+
+	return &object.Error{Message: message, Kind: object.TypeErrorKind}
+}
+
+// applyBuiltin accounts the objects returned by builtins once they are built, since only the
+// builtin knows their size beforehand
+func (e *evaluator) applyBuiltin(builtin *object.Builtin, args []object.Object) object.Object {
+	result := builtin.Call(e.ctx, args...)
+	if result == nil {
+		return NULL
+	}
+
+	for _, arg := range args {
+		if arg == result {
+			return result
+		}
+	}
+
+	if err := e.allocate(object.SizeOf(result)); err != nil {
+		return err
+	}
+
+	return result
+}
+
+// extendFunctionEnv binds the parameters of fn in a new frame. Parameters without argument
+// are bound to their default value, evaluated in the frame so it can use the parameters
+// before it, and the rest parameter of a variadic function to an array of the remaining ones
+func (e *evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	var env *object.Environment
+	if fn.Locals != nil {
+		env = object.NewFrameEnvironment(fn.Env, fn.Locals)
+	} else {
+		env = object.NewEnclosedEnvironment(fn.Env)
+	}
+
+	for i, param := range fn.Parameters {
+		var value object.Object
+		switch {
+		case fn.Variadic && i == len(fn.Parameters)-1:
+			rest := []object.Object{}
+			if i < len(args) {
+				rest = append(rest, args[i:]...)
+			}
+			if err := e.allocate(object.ArraySize(len(rest))); err != nil {
+				return nil, err
+			}
+			value = &object.Array{Elements: rest}
+		case i < len(args) && args[i] != nil:
+			value = args[i]
+		default:
+			value = e.eval(fn.Defaults[i], env)
+			if err, ok := value.(*object.Error); ok {
+				return nil, err
+			}
+		}
+
+		if fn.Locals != nil {
+			env.SetAt(param.Slot, value)
+		} else {
+			env.Set(param.Value, value)
+		}
+	}
+
+	return env, nil
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+
+	return obj
+}
+
+// evalIdentifier reads resolved locals from their slot. A local that wasn't set, like one bound
+// in an if block that didn't run, is looked up by name in the enclosing scopes
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Local {
+		if val := env.GetAt(node.Depth, node.Slot); val != nil {
+			return val
+		}
+
+		for i := 0; i <= node.Depth; i++ {
+			env = env.Outer()
+		}
+	}
+
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin, ok := object.LookupBuiltin(node.Value); ok {
+		return builtin
+	}
+
+	return newKindError(object.NameErrorKind, "identifier not found: %s", node.Value)
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -48,16 +672,110 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evaluateMinusOperator(right)
 	}
 
-	return NULL
+	return newKindError(object.TypeErrorKind, "unknown operator: %s%s", operator, right.Type())
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func (e *evaluator) evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.Integer_Obj && right.Type() == object.Integer_Obj:
 		return evaluateArimethic(operator, left, right)
+	case left.Type() == object.String_Obj && right.Type() == object.String_Obj:
+		return e.evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type():
+		return newKindError(object.TypeErrorKind, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case left.Type() == object.Boolean_Obj && operator == "==":
+		return nativeBoolToObject(left.(*object.Boolean).Value == right.(*object.Boolean).Value)
+	case left.Type() == object.Boolean_Obj && operator == "!=":
+		return nativeBoolToObject(left.(*object.Boolean).Value != right.(*object.Boolean).Value)
 	}
 
-	return NULL
+	return newKindError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func (e *evaluator) evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		if err := e.allocate(len(leftVal) + len(rightVal)); err != nil {
+			return err
+		}
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToObject(leftVal != rightVal)
+	}
+
+	return newKindError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.Array_Obj && index.Type() == object.Integer_Obj:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.Hash_Obj:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.Module_Obj:
+		return evalModuleIndexExpression(left.(*object.Module), index)
+	}
+
+	return newKindError(object.TypeErrorKind, "index operator not supported: %s", left.Type())
+}
+
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= len(elements) {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newKindError(object.TypeErrorKind, "unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+func (e *evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair, len(node.Keys))
+
+	for i, keyNode := range node.Keys {
+		key := e.eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newKindError(object.TypeErrorKind, "unusable as hash key: %s", key.Type())
+		}
+
+		value := e.eval(node.Values[i], env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	if err := e.allocate(object.HashSize(len(pairs))); err != nil {
+		return err
+	}
+
+	return &object.Hash{Pairs: pairs}
 }
 
 type OperateOnInfixOperators[T any] func(left, right T) object.Object
@@ -68,24 +786,27 @@ func evaluateArimethic(operator string, left, right object.Object) object.Object
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return object.NewInteger(leftVal + rightVal)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return object.NewInteger(leftVal - rightVal)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return object.NewInteger(leftVal * rightVal)
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newKindError(object.ArithmeticErrorKind, "division by zero: %d / %d", leftVal, rightVal)
+		}
+		return object.NewInteger(leftVal / rightVal)
 	case ">":
-		return &object.Boolean{Value: leftVal > rightVal}
+		return nativeBoolToObject(leftVal > rightVal)
 	case "<":
-		return &object.Boolean{Value: leftVal < rightVal}
+		return nativeBoolToObject(leftVal < rightVal)
 	case "==":
-		return &object.Boolean{Value: leftVal == rightVal}
+		return nativeBoolToObject(leftVal == rightVal)
 	case "!=":
-		return &object.Boolean{Value: leftVal != rightVal}
+		return nativeBoolToObject(leftVal != rightVal)
 	}
 
-	return NULL
+	return newKindError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
 func evaluateNegationOperator(right object.Object) object.Object {
@@ -103,11 +824,11 @@ func evaluateNegationOperator(right object.Object) object.Object {
 
 func evaluateMinusOperator(right object.Object) object.Object {
 	if right.Type() != object.Integer_Obj {
-		return NULL
+		return newKindError(object.TypeErrorKind, "unknown operator: -%s", right.Type())
 	}
 
 	rightVal := right.(*object.Integer).Value
-	return &object.Integer{Value: -rightVal}
+	return object.NewInteger(-rightVal)
 }
 
 func nativeBoolToObject(input bool) *object.Boolean {
@@ -117,3 +838,31 @@ func nativeBoolToObject(input bool) *object.Boolean {
 
 	return FALSE
 }
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// newKindError is like newError for the errors scripts may want to tell apart when caught
+func newKindError(kind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.Error_Obj
+	}
+
+	return false
+}
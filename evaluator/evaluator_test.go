@@ -1,11 +1,19 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"go-interpreter.com/m/lexer"
 	"go-interpreter.com/m/object"
 	"go-interpreter.com/m/parser"
+	"go-interpreter.com/m/resolver"
 )
 
 func TestEvalInteger(t *testing.T) {
@@ -87,26 +95,1485 @@ func TestInfixExpressions(t *testing.T) {
 
 		if !ok && !okBool {
 			t.Errorf("expected value is not supoorted, got=%t\n", tc.expected)
-			return
+			continue
 		}
 
 		if ok {
 			testIntegerLiteral(t, evaluated, val)
-			return
+			continue
 		}
 
 		if okBool {
 			testBooleanLiteral(t, evaluated, valBool)
-			return
 		}
 	}
 }
 
+func TestBooleanSingletons(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"1 < 2", TRUE},
+		{"1 > 2", FALSE},
+		{"1 == 1", TRUE},
+		{"1 != 1", FALSE},
+		{`"a" == "a"`, TRUE},
+		{"true == false", FALSE},
+		{"!(1 < 2)", FALSE},
+		{"!(1 > 2)", TRUE},
+		{"!!(1 > 2)", FALSE},
+	}
+
+	for _, tc := range testCases {
+		if evaluated := executeEval(tc.input); evaluated != tc.expected {
+			t.Errorf("%q is not the canonical %s. got=%T (%+v)", tc.input, tc.expected.Inspect(), evaluated, evaluated)
+		}
+	}
+}
+
+func TestSmallIntegersAreShared(t *testing.T) {
+	if executeEval("1 + 2") != executeEval("6 / 2") {
+		t.Errorf("small integer results are not shared")
+	}
+
+	if executeEval("1000 * 1000") == executeEval("1000 * 1000") {
+		t.Errorf("large integer results are shared")
+	}
+}
+
+func BenchmarkInfixExpressions(b *testing.B) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"arithmetic", "(1 + 2) * 3 - 4 / 2"},
+		{"comparison", "!(1 < 2) == (3 > 4)"},
+		{"recursion", "let count = fn(n) { if (n < 1) { 0 } else { 1 + count(n - 1) } }; count(500)"},
+	}
+
+	for _, tc := range testCases {
+		program := parser.New(lexer.New(tc.input)).ParseProgram()
+		resolver.Resolve(program, nil)
+
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Eval(program, object.NewEnvironment())
+			}
+		})
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		integer, ok := tc.expected.(int)
+		if ok {
+			testIntegerLiteral(t, evaluated, integer)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		testIntegerLiteral(t, evaluated, tc.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	testCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"let f = fn(x) { x }; f(1, 2)", "f: expected 1 argument, got 2"},
+		{"5()", "not a function: INTEGER"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		testErrorObject(t, evaluated, tc.expectedMessage)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tc := range testCases {
+		testIntegerLiteral(t, executeEval(tc.input), tc.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "let double = fn(x) { x * 2; }; double"
+
+	evaluated := executeEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if fn.Name != "double" {
+		t.Errorf("function has wrong name. got=%q", fn.Name)
+	}
+
+	if len(fn.Parameters) != 1 || fn.Parameters[0].String() != "x" {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+
+	if fn.Body.String() != "(x * 2)" {
+		t.Fatalf("body is not %q. got=%q", "(x * 2)", fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tc := range testCases {
+		testIntegerLiteral(t, executeEval(tc.input), tc.expected)
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a, b = a * 2) { a + b }; f(3)", 9},
+		{"let n = 0; let f = fn(x = n + 1) { x }; let n = 5; f()", 6},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1)", "[1, 2, []]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 4, 5)", "[1, 3, [4, 5]]"},
+		{"let add = fn(a, b) { a + b }; add(...[1, 2])", 3},
+		{"let add = fn(a, b, c) { a + b + c }; add(1, ...[2], ...[3])", 6},
+		{"let count = fn(...xs) { len(xs) }; count(...[], 1, ...[2, 3])", 3},
+		{"len(...[[1, 2]])", 2},
+		{"let f = fn([a, b], c = 3) { a + b + c }; f([1, 2])", 6},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. got=%s, want=%s", tc.input, evaluated.Inspect(), expected)
+			}
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b, c = 1) { a }; f(1, 2, 3, 4, 5)", "f: expected 2..3 arguments, got 5"},
+		{"let f = fn(a, b) { a }; f(1)", "f: expected 2 arguments, got 1"},
+		{"let f = fn(a, b, ...rest) { a }; f(1)", "f: expected at least 2 arguments, got 1"},
+		{"fn(a = 1) { a }(1, 2)", "expected 0..1 arguments, got 2"},
+		{"let f = fn(a) { a }; f(...5)", "cannot spread INTEGER, want ARRAY"},
+		{"let f = fn(a, b = missing) { a }; f(1)", "identifier not found: missing"},
+	}
+
+	for _, tc := range errorCases {
+		errObj, ok := executeEval(tc.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tc.input)
+			continue
+		}
+		if errObj.Message != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, errObj.Message)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b) { a - b }; f(b: 1, a: 10)", 9},
+		{"let f = fn(a, b) { a - b }; f(10, b: 1)", 9},
+		{"let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c: 30)", "[1, 2, 30]"},
+		{"let f = fn(a, b = a * 2) { [a, b] }; f(a: 4)", "[4, 8]"},
+		{"let f = fn(a, ...rest) { [a, rest] }; f(a: 1)", "[1, []]"},
+		{"let f = fn(a, ...rest) { [a, rest] }; f(1, 2, 3)", "[1, [2, 3]]"},
+		{"let f = fn(a, b) { a - b }; await(spawn f(b: 1, a: 3))", 2},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. got=%s, want=%s", tc.input, evaluated.Inspect(), expected)
+			}
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b) { a }; f(1, timeout: 30)", "f: unknown argument timeout"},
+		{"let f = fn(a, b) { a }; f(1, a: 2)", "f: argument a passed twice"},
+		{"let f = fn(a, b) { a }; f(b: 2)", "f: missing argument a"},
+		{"let f = fn(a, ...rest) { a }; f(1, rest: [2])", "f: unknown argument rest"},
+		{"let f = fn(a) { a }; f(1, 2, a: 3)", "f: expected 1 argument, got 3"},
+		{"len([], x: 1)", "`len` doesn't take named arguments"},
+		{"let f = fn(a) { a }; f(a: missing)", "identifier not found: missing"},
+	}
+
+	for _, tc := range errorCases {
+		errObj, ok := executeEval(tc.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tc.input)
+			continue
+		}
+		if errObj.Message != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, errObj.Message)
+		}
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3] |> len", 3},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3)", 6},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(b: 3)", 7},
+		{"let xs = [1, 2]; xs |> push(3) |> rest", "[2, 3]"},
+		{"5 |> fn(n) { n * n }", 25},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. got=%s, want=%s", tc.input, evaluated.Inspect(), expected)
+			}
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerLiteral(t, executeEval(input), 4)
+}
+
+func TestRecursionDepthLimit(t *testing.T) {
+	input := `
+let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } };
+countdown(50);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	env := object.NewEnvironment()
+	testIntegerLiteral(t, Eval(program, env), 0)
+
+	env = object.NewEnvironment()
+	env.Limits().MaxCallDepth = 10
+	evaluated := Eval(program, env)
+	testErrorObject(t, evaluated,
+		"maximum recursion depth exceeded (10)\ncall chain: countdown (x10)")
+
+	evaluated = executeEval("let loop = fn() { loop() }; loop();")
+	testErrorObject(t, evaluated, fmt.Sprintf(
+		"maximum recursion depth exceeded (%d)\ncall chain: loop (x%d)",
+		object.DefaultMaxCallDepth, object.DefaultMaxCallDepth))
+
+	// the error unwinds the calls to the nearest catch block
+	evaluated = executeEval(`let loop = fn(n) { loop(n + 1) }; try { loop(0) } catch (e) { e["kind"] }`)
+	if str, ok := evaluated.(*object.String); !ok || str.Value != "error" {
+		t.Errorf("recursion depth error wasn't caught. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(40);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated := EvalContext(ctx, program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if !errors.Is(errObj, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap context.DeadlineExceeded. got=%q", errObj.Message)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluated = EvalContext(canceled, program, object.NewEnvironment())
+	testErrorObject(t, evaluated, "evaluation interrupted: context canceled")
+}
+
+func TestStepBudget(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(15);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	env := object.NewEnvironment()
+	env.Limits().MaxSteps = 100
+	evaluated := Eval(program, env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if !errors.Is(errObj, ErrStepBudgetExceeded) {
+		t.Errorf("error does not wrap ErrStepBudgetExceeded. got=%q", errObj.Message)
+	}
+
+	env = object.NewEnvironment()
+	env.Limits().MaxSteps = 1000000
+	testIntegerLiteral(t, Eval(program, env), 610)
+}
+
+func TestStringLiterals(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"Hello" == "Hello"`, true},
+		{`"Hello" != "World"`, true},
+		{`"Hello" == "World"`, false},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case string:
+			testStringObject(t, evaluated, expected)
+		case bool:
+			testBooleanLiteral(t, evaluated, expected)
+		}
+	}
+
+	testErrorObject(t, executeEval(`"Hello" - "World"`), "unknown operator: STRING - STRING")
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := executeEval("[1, 2 * 2, 3 + 3]")
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerLiteral(t, result.Elements[0], 1)
+	testIntegerLiteral(t, result.Elements[1], 4)
+	testIntegerLiteral(t, result.Elements[2], 6)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		integer, ok := tc.expected.(int)
+		if ok {
+			testIntegerLiteral(t, evaluated, integer)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+
+	evaluated := executeEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerLiteral(t, pair.Value, expectedValue)
+	}
+
+	testErrorObject(t, executeEval(`{"name": "Monkey"}[fn(x) { x }];`), "unusable as hash key: FUNCTION")
+}
+
+func TestMemoryLimit(t *testing.T) {
+	input := `
+let grow = fn(s, n) { if (n == 0) { s } else { grow(s + s, n - 1) } };
+grow("0123456789", 40);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	env := object.NewEnvironment()
+	env.Limits().MaxAllocation = 1 << 20
+	evaluated := Eval(program, env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if !errors.Is(errObj, ErrMemoryLimitExceeded) {
+		t.Errorf("error does not wrap ErrMemoryLimitExceeded. got=%q", errObj.Message)
+	}
+
+	if allocated := env.Limits().Allocated(); allocated > 1<<20 {
+		t.Errorf("allocated more than the limit. got=%d", allocated)
+	}
+
+	env = object.NewEnvironment()
+	env.Limits().MaxAllocation = 1 << 10
+	evaluated = Eval(parser.New(lexer.New("let a = [1, 2, 3]; let h = {1: a}; [a, a]")).ParseProgram(), env)
+	if _, ok := evaluated.(*object.Array); !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := object.ArraySize(3) + object.HashSize(1) + object.ArraySize(2)
+	if allocated := env.Limits().Allocated(); allocated != expected {
+		t.Errorf("wrong number of bytes allocated. got=%d, want=%d", allocated, expected)
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument 1 to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])[0]`, 2},
+		{`rest([])`, nil},
+		{`push([], 1)[0]`, 1},
+		{`push(1, 1)`, "argument 1 to `push` must be ARRAY, got INTEGER"},
+		{`puts("hello")`, nil},
+		{`type(1) == "INTEGER"`, true},
+		{`type(len)`, "BUILTIN"},
+		{`let len = fn(x) { 42 }; len("shadowed")`, 42},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case bool:
+			testBooleanLiteral(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	object.RegisterBuiltin("sum", func(args ...object.Object) object.Object {
+		if err := object.ExpectArguments("sum", args, object.Integer_Obj, object.Integer_Obj); err != nil {
+			return err
+		}
+
+		return &object.Integer{Value: args[0].(*object.Integer).Value + args[1].(*object.Integer).Value}
+	})
+
+	testIntegerLiteral(t, executeEval("sum(2, 3)"), 5)
+	testErrorObject(t, executeEval(`sum(2, "3")`), "argument 2 to `sum` must be INTEGER, got STRING")
+	testErrorObject(t, executeEval("sum(2)"), "wrong number of arguments to `sum`: want=2, got=1")
+}
+
+// executeEval resolves the program before evaluating it, static errors are returned as error objects
+func TestResolvedFrames(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b) { let c = a * b; c + a }; f(3, 4)", 15},
+		{"let f = fn(x) { if (x > 1) { let y = x; } y }; f(5)", 5},
+		{"let y = 7; let f = fn(x) { if (x > 1) { let y = x; } y }; f(0)", 7},
+		{"let f = fn(x) { if (x > 1) { let y = x; } y }; f(0)", "identifier not found: y"},
+		{"let f = fn() { let g = fn() { h() }; let h = fn() { 9 }; g() }; f()", 9},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{"let f = fn(a) { let a = a + 1; a }; f(1)", 2},
+		{"let f = fn() { len }; f()([1, 2])", 2},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestUnresolvedPrograms(t *testing.T) {
+	input := `
+let newAdder = fn(x) { fn(y) { let z = x + y; z } };
+let f = fn(x) { if (x > 1) { let y = x; } y };
+newAdder(2)(3) + f(5);`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	testIntegerLiteral(t, Eval(program, object.NewEnvironment()), 10)
+}
+
+func TestSpawn(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"await(spawn fn() { 1 + 2 })", 3},
+		{"let add = fn(a, b) { a + b }; await(spawn add(2, 3))", 5},
+		{"let n = 4; let t = spawn fn() { n * n }; await(t) + await(t)", 32},
+		{"let t = spawn fn() { let x = 2; fn(y) { x * y } }; await(t)(5)", 10},
+		{"await(spawn len([1, 2]))", 2},
+		{`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let tasks = [spawn fib(10), spawn fib(11), spawn fib(12)];
+await(tasks[0]) + await(tasks[1]) + await(tasks[2])`, 55 + 89 + 144},
+		{"let f = fn() { let t = spawn fn() { 7 }; await(t) }; f()", 7},
+		// tasks read the scopes of their function while the spawner keeps binding names in them
+		{`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let f = fn() { let t = spawn fn() { fib(12) }; let a = 1; let b = 2; await(t) + a + b };
+let t = spawn f;
+let c = 3;
+await(t) + c`, 144 + 6},
+		{"spawn 5", "not a function: INTEGER"},
+		{"spawn f()", "identifier not found: f"},
+		{"await(5)", "argument 1 to `await` must be TASK, got INTEGER"},
+		{"await(spawn fn() { 1 / 0 })", "division by zero: 1 / 0"},
+		{"let t = spawn fn() { 1 / 0 }; 5", "division by zero: 1 / 0"},
+		{"let t = spawn fn(x) { x }; 5", "expected 1 argument, got 0"},
+		// failures are reported in spawn order, the channel makes sure both tasks run
+		{"let c = channel(0); spawn fn() { send(c, 1); 1 + true }; spawn fn() { recv(c); 1 / 0 }; 5", "type mismatch: INTEGER + BOOLEAN"},
+		// the failure of a task cancels the ones waiting for it
+		{"let c = channel(0); spawn fn() { recv(c) }; spawn fn() { -true }; 5", "unknown operator: -BOOLEAN"},
+		{"let f = fn() { spawn fn() { 1 / 0 }; 5 }; f()", "division by zero: 1 / 0"},
+		{"let t = spawn fn() { -true }; if (true) { await(t) } 5", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestChannels(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let c = channel(1); send(c, 5); recv(c)", 5},
+		{"let c = channel(0); spawn send(c, 3); recv(c)", 3},
+		{`
+let results = channel(0);
+let worker = fn(n) { send(results, n * n) };
+spawn worker(2);
+spawn worker(3);
+recv(results) + recv(results)`, 13},
+		{`
+let jobs = channel(3);
+let done = channel(0);
+let sum = fn(total, n) { if (n == 0) { total } else { sum(total + recv(jobs), n - 1) } };
+spawn fn() { send(done, sum(0, 3)) };
+send(jobs, 1); send(jobs, 2); send(jobs, 3);
+recv(done)`, 6},
+		{"let a = channel(1); let b = channel(1); send(b, 9); select([a, b])[0]", 1},
+		{"let a = channel(1); let b = channel(1); send(b, 9); select([a, b])[1]", 9},
+		{"let a = channel(1); select([a], 42)", 42},
+		{"channel(-1)", "capacity of `channel` must not be negative, got -1"},
+		{"send(1, 2)", "argument 1 to `send` must be CHANNEL, got INTEGER"},
+		{"recv(channel(1), 1)", "wrong number of arguments to `recv`: want=1, got=2"},
+		{"select(1)", "argument 1 to `select` must be ARRAY, got INTEGER"},
+		{"select([channel(1), 2], 0)", "element 1 of argument 1 to `select` must be CHANNEL, got INTEGER"},
+		{"select()", "wrong number of arguments to `select`: want=1 or 2, got=0"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBlockedTasksAreCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the host function keeps running, so the blocked task isn't a deadlock
+	env := object.NewEnvironment()
+	env.Set("wait", &object.Builtin{Name: "wait", ContextFn: func(ctx context.Context, args ...object.Object) object.Object {
+		<-ctx.Done()
+		return object.NewInterruptedError(ctx.Err())
+	}})
+
+	program := parser.New(lexer.New("let c = channel(0); let t = spawn recv(c); wait()")).ParseProgram()
+	evaluated := EvalContext(ctx, program, env)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || !errors.Is(errObj, context.DeadlineExceeded) {
+		t.Errorf("expected error wrapping context.DeadlineExceeded. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestDeadlocks(t *testing.T) {
+	testCases := []string{
+		"let c = channel(0); recv(c)",
+		"let c = channel(1); send(c, 1); send(c, 2)",
+		"let c = channel(0); spawn fn() { recv(c) }(); 1",
+		"let c = channel(0); await(spawn recv(c))",
+		"let a = channel(0); let b = channel(0); spawn fn() { recv(a) }; select([b])",
+		"let c = channel(0); let t = spawn fn() { await(spawn recv(c)) }; send(c, 1); recv(c)",
+		"let c = channel(0); try { recv(c) } catch (e) { 1 }",
+	}
+
+	for _, input := range testCases {
+		evaluated := executeEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || !errors.Is(errObj, object.ErrDeadlock) {
+			t.Errorf("expected error wrapping ErrDeadlock for %q. got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestChannelsOfOtherEvaluations(t *testing.T) {
+	env := object.NewEnvironment()
+	first := Eval(parser.New(lexer.New("let c = channel(1); send(c, 1); c")).ParseProgram(), env)
+	if _, ok := first.(*object.Channel); !ok {
+		t.Fatalf("expected a channel. got=%T (%+v)", first, first)
+	}
+
+	// the channel stays guarded by the evaluation that used it first
+	evaluated := Eval(parser.New(lexer.New("recv(c)")).ParseProgram(), env)
+	testIntegerLiteral(t, evaluated, 1)
+
+	evaluated = Eval(parser.New(lexer.New("let d = channel(1); send(d, 2); select([d, c])")).ParseProgram(), env)
+	testErrorObject(t, evaluated, "channels passed to `select` must be used by a single evaluation")
+}
+
+func TestTasksShareTheStepBudget(t *testing.T) {
+	input := `
+let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } };
+let tasks = [spawn count(100), spawn count(100), spawn count(100)];
+await(tasks[0]); await(tasks[1]); await(tasks[2]);`
+
+	env := object.NewEnvironment()
+	env.Limits().MaxSteps = 2000
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	resolver.Resolve(program, nil)
+
+	evaluated := Eval(program, env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || !errors.Is(errObj, ErrStepBudgetExceeded) {
+		t.Errorf("expected error wrapping ErrStepBudgetExceeded. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (1) { 1 => 10, 2 => 20 }", 10},
+		{"match (2) { 1 => 10, 2 => 20 }", 20},
+		{"match (-3) { -3 => 1, _ => 2 }", 1},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (1 > 2) { true => 1, false => 2 }", 2},
+		{`match ("1") { 1 => 1, _ => 2 }`, 2},
+		{"match (5) { n => n * 2 }", 10},
+		{"match (5) { n if n > 10 => 1, n if n > 1 => 2, _ => 3 }", 2},
+		{"match ([1, 2]) { [] => 0, [a] => a, [a, b] => a + b }", 3},
+		{"match ([1, 2, 3]) { [a, b] => 0, [a, ...rest] => len(rest) }", 2},
+		{"match ([1]) { [a, ...rest] => len(rest) }", 0},
+		{"match ([1, 2, 3]) { [_, ..._] => 7 }", 7},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", 6},
+		{"match ([1, 2]) { [1, x] => x, _ => 0 }", 2},
+		{"match ([3, 2]) { [1, x] => x, _ => 0 }", 0},
+		{`match ({"name": "a", "age": 3}) { {"age": 4} => 1, {name, "age": age} => age }`, 3},
+		{`match ({"x": 1}) { {"y": y} => y, {} => 5 }`, 5},
+		{`match ({1: [2]}) { {1: [n]} => n }`, 2},
+		{"match (1) { [a] => a, {} => 0, _ => 9 }", 9},
+		{"let f = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + f(rest) } }; f([1, 2, 3, 4])", 10},
+		{"let f = fn(x) { let r = match (x) { [a, b] => a * b, n => n }; r + 1 }; f([2, 3])", 7},
+		{"let f = fn(x) { fn() { match (x) { [a] => fn() { a } } } }; f([4])()()", 4},
+		{"match (5) { 1 => 1 }", "no match arm matches 5"},
+		{"match ([1, 2]) { [a] => a, n if n == 1 => 2 }", "type mismatch: ARRAY == INTEGER"},
+		{"match (1 / 0) { _ => 1 }", "division by zero: 1 / 0"},
+		{"match (1) { x if x / 0 => 1 }", "division by zero: 1 / 0"},
+		// the names bound by an arm are only visible in the arm
+		{"let v = 5; match (1) { v => v }; v", 5},
+		{"let v = 5; match (1) { v if false => 0, _ => 1 }; v", 5},
+		{"let f = fn() { let v = 5; match (1) { v => v }; v }; f()", 5},
+		{"let f = fn(x) { let y = 3; match (x) { n => n + y } }; f(1)", 4},
+		{"match (1) { n => if (true) { let k = n + 1; k } }", 2},
+		{"match (1) { n => n }; n", "identifier not found: n"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMatchBindingsAreSetOnMatch(t *testing.T) {
+	input := `
+let a = 1;
+let r = match ([5, 6]) { [a, 7] => a, [_, b] => b };
+a + r`
+
+	testIntegerLiteral(t, executeEval(input), 7)
+}
+
+func TestDestructuring(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; a + b + len(rest)", 5},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{"let [_, [b, c]] = [1, [2, 3]]; b * c", 6},
+		{`let {name, age} = {"name": "ann", "age": 30, "id": 1}; age`, 30},
+		{`let {"pos": [x, y]} = {"pos": [4, 5]}; x * y`, 20},
+		{"let f = fn([a, b]) { a - b }; f([5, 3])", 2},
+		{`let f = fn(n, {x, y}) { n * (x + y) }; f(2, {"x": 1, "y": 2})`, 6},
+		{"let f = fn([a, ...rest]) { if (len(rest) == 0) { a } else { a + f(rest) } }; f([1, 2, 3])", 6},
+		{"let f = fn(xs) { let [head, ...tail] = xs; fn() { head + len(tail) } }; f([10, 1, 1])()", 12},
+		{"let [a, b] = 5;", "cannot destructure INTEGER with [a, b]"},
+		{"let [a, b] = [1];", "cannot destructure array of 1 elements with [a, b]"},
+		{"let [a, b] = [1, 2, 3];", "cannot destructure array of 3 elements with [a, b]"},
+		{`let {name, age} = {"name": "ann"};`, "cannot destructure hash without key age with {name, age}"},
+		{"let {name} = [1];", "cannot destructure ARRAY with {name}"},
+		{"let [a, [b]] = [1, 2];", "cannot destructure INTEGER with [b]"},
+		{"let [1, a] = [2, 3];", "cannot destructure 2 with 1"},
+		{"let f = fn([a, b]) { a }; f(1)", "cannot destructure INTEGER with [a, b]"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "5"},
+		{"quote(5 + 8)", "(5 + 8)"},
+		{"quote(foobar + barfoo)", "(foobar + barfoo)"},
+		{"quote(unquote(4))", "4"},
+		{"quote(unquote(4 + 4))", "8"},
+		{"quote(8 + unquote(4 + 4))", "(8 + 8)"},
+		{"quote(unquote(4 + 4) + 8)", "(8 + 8)"},
+		{"let foobar = 8; quote(unquote(foobar))", "8"},
+		{"quote(unquote(true))", "true"},
+		{"quote(unquote(true == false))", "false"},
+		{`quote(unquote("a" + "b"))`, "ab"},
+		{"quote(unquote(quote(4 + 4)))", "(4 + 4)"},
+		{"let quotedInfix = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfix))", "(8 + (4 + 4))"},
+		{"let f = fn(x) { quote(unquote(x) * 2) }; f(1); f(3)", "(3 * 2)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("expected *object.Quote for %q. got=%T (%+v)", tc.input, evaluated, evaluated)
+		}
+
+		if quote.Node.String() != tc.expected {
+			t.Errorf("wrong quote for %q. want=%q, got=%q", tc.input, tc.expected, quote.Node.String())
+		}
+	}
+
+	testErrorObject(t, executeEval("quote(unquote(len))"), "unquote can't turn BUILTIN into code")
+	testErrorObject(t, executeEval("quote(unquote(1 / 0))"), "division by zero: 1 / 0")
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(input)).ParseProgram()
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 || macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Errorf("wrong macro parameters. got=%v", macro.Parameters)
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Errorf("wrong macro body. got=%q", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let infixExpression = macro() { quote(1 + 2); }; infixExpression();", "(1 + 2)"},
+		{"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);", "(10 - 5) - (2 + 2)"},
+		{`
+let unless = macro(condition, consequence, alternative) {
+  quote(if (!(unquote(condition))) { unquote(consequence); } else { unquote(alternative); });
+};
+unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`},
+		{"let double = macro(x) { quote(unquote(x) * 2) }; double(double(3))", "((3 * 2) * 2)"},
+		{"let add = macro(a, b) { quote(unquote(a) + unquote(b)) }; add(1, 2); add(3, 4)", "(1 + 2); (3 + 4)"},
+	}
+
+	for _, tc := range testCases {
+		expected := parser.New(lexer.New(tc.expected)).ParseProgram()
+		program := parser.New(lexer.New(tc.input)).ParseProgram()
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.input, err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("wrong expansion of %q. want=%q, got=%q", tc.input, expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro(x) { x }; m(1, 2)", "1:25: wrong number of arguments to macro m: want=1, got=2"},
+		{"let m = macro() { 5 }; m()", "1:24: macro m must return a QUOTE, got INTEGER"},
+		{"let m = macro() { }; m()", "1:22: macro m must return a QUOTE, got NULL"},
+		{"let m = macro() { 1 / 0 }; m()", "1:28: expanding macro m: division by zero: 1 / 0"},
+	}
+
+	for _, tc := range testCases {
+		program := parser.New(lexer.New(tc.input)).ParseProgram()
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+
+		var macroErr *MacroError
+		if !errors.As(err, &macroErr) {
+			t.Fatalf("error is not *MacroError for %q. got=%T (%v)", tc.input, err, err)
+		}
+
+		if err.Error() != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, err.Error())
+		}
+	}
+
+	testErrorObject(t, executeEval("let f = fn() { let m = macro() { quote(1) }; m }; f()"),
+		"macros can only be defined by top-level let statements")
+}
+
+func TestExpandedProgramsAreResolved(t *testing.T) {
+	input := `
+let square = macro(x) { quote(unquote(x) * unquote(x)) };
+let f = fn(a) { let b = a + 1; square(b) };
+let g = fn(c) { square(c) };
+f(2) + g(5)`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if errs := resolver.Resolve(expanded, nil); len(errs) != 0 {
+		t.Fatalf("unexpected resolve errors: %v", errs)
+	}
+
+	testIntegerLiteral(t, Eval(expanded, env), 9+25)
+}
+
+// Programs can be parsed and evaluated on several goroutines at the same time as long as
+// each evaluation has its own environment, the parsed program is only read
+func TestConcurrentEvaluation(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let pairs = {"a": [1, 2], true: fn(x) { x * 2 }};
+fib(10) + pairs["a"][1] + pairs[true](len("abc")) + first(rest([1, 2, 3]))
+`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	resolver.Resolve(program, nil)
+
+	const workers = 8
+	results := make([]object.Object, 2*workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			results[w] = Eval(program, object.NewEnvironment())
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			results[workers+w] = executeEval(input)
+		}(w)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		testIntegerLiteral(t, result, 55+2+6+2)
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 + true } catch (e) { e["kind"] }`, "type"},
+		{`try { 10 / 0 } catch (e) { e["kind"] }`, "arithmetic"},
+		{`try { missing } catch (e) { e["kind"] }`, "name"},
+		{`try { len(1) } catch (e) { e["kind"] }`, "type"},
+		{"let x = 1;\nlet y = try {\n  x + \"a\"\n} catch (e) { [e[\"line\"], e[\"column\"]] }; y[0] * 10 + y[1]", 33},
+		{`try { throw "boom"; 1 } catch (e) { e["message"] + " " + e["kind"] }`, "boom error"},
+		{`try { throw {"message": "no", "kind": "range"} } catch (e) { e["kind"] }`, "range"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { try { 1 + true } catch (e) { throw e } } catch (e) { [e["kind"], e["column"]] }`, "[type, 13]"},
+		{`let f = fn(n) { if (n < 0) { throw "negative" } n }; try { f(-1) } catch (e) { e["line"] }`, 1},
+		{`let f = fn() { try { return 1; } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { 1 } finally { return 2; } }; f()`, 2},
+		{`let f = fn() { try { throw "x" } catch (e) { 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { let done = false; try { throw "x" } catch (e) { 1 } finally { let done = true; }; done }; f()`, true},
+		{`let f = fn(x) { try { x["a"] } catch (err) { err["kind"] } }; f(5)`, "type"},
+		// the caught error is only visible in the catch block
+		{`let f = fn() { let e = 5; try { throw 1 } catch (e) { 0 }; e }; f()`, 5},
+		{`let e = 5; try { throw 1 } catch (e) { 0 }; e`, 5},
+		{`let e = 5; let f = fn() { let x = e; try { throw 1 } catch (e) { x } }; f()`, 5},
+		{`let f = fn() { let y = 3; try { throw "a" } catch (e) { let z = y; z } }; f()`, 3},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeEval(tc.input)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case bool:
+			testBooleanLiteral(t, evaluated, expected)
+		case string:
+			if arr, ok := evaluated.(*object.Array); ok {
+				if arr.Inspect() != expected {
+					t.Errorf("wrong result for %q. got=%s, want=%s", tc.input, arr.Inspect(), expected)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`throw "boom"`, "boom"},
+		{`try { throw "a" } finally { 1 }`, "a"},
+		{`try { 1 } finally { throw "b" }`, "b"},
+		{`try { throw "a" } catch (e) { throw "c" } finally { 1 }`, "c"},
+		{`throw {"kind": "x"}`, `thrown hash without a string message: {kind: x}`},
+	}
+
+	for _, tc := range errorCases {
+		errObj, ok := executeEval(tc.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tc.input)
+			continue
+		}
+		if errObj.Message != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, errObj.Message)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	testCases := []struct {
+		input  string
+		kind   string
+		line   int
+		column int
+	}{
+		{"5 + true", object.TypeErrorKind, 1, 1},
+		{"let a = 1;\n  a + missing", object.NameErrorKind, 2, 7},
+		{"let f = fn(x) {\n x / 0 };\nf(1)", object.ArithmeticErrorKind, 2, 2},
+		{"[1][\"a\"]", object.TypeErrorKind, 1, 1},
+		{"if (true) {\n  throw \"a\" }", object.ErrorKind, 2, 3},
+		{"match (1) { 2 => 3 }", object.MatchErrorKind, 1, 1},
+		{"channel(-1)", object.TypeErrorKind, 1, 1},
+		{"select([channel(1), 2])", object.TypeErrorKind, 1, 1},
+	}
+
+	for _, tc := range testCases {
+		errObj, ok := executeEval(tc.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tc.input)
+			continue
+		}
+		if errObj.ErrorKind() != tc.kind || errObj.Line != tc.line || errObj.Column != tc.column {
+			t.Errorf("wrong error for %q. got=%s at %d:%d, want=%s at %d:%d",
+				tc.input, errObj.ErrorKind(), errObj.Line, errObj.Column, tc.kind, tc.line, tc.column)
+		}
+	}
+}
+
+// Going over the step budget or the memory limit can't be caught, otherwise a script could run
+// forever retrying
+func TestLimitErrorsAreUncaught(t *testing.T) {
+	testCases := []struct {
+		input  string
+		limits object.Limits
+		err    error
+	}{
+		{`let loop = fn(n) { loop(n + 1) }; try { loop(0) } catch (e) { 1 } finally { 2 }`, object.Limits{MaxSteps: 500}, ErrStepBudgetExceeded},
+		{`let grow = fn(s) { grow(s + s) }; try { grow("ab") } catch (e) { 1 }`, object.Limits{MaxAllocation: 1000}, ErrMemoryLimitExceeded},
+	}
+
+	for _, tc := range testCases {
+		program := parser.New(lexer.New(tc.input)).ParseProgram()
+		env := object.NewEnvironment()
+		*env.Limits() = tc.limits
+
+		errObj, ok := Eval(program, env).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tc.input)
+			continue
+		}
+		if !errors.Is(errObj, tc.err) {
+			t.Errorf("wrong error for %q. want=%v, got=%q", tc.input, tc.err, errObj.Message)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	program := parser.New(lexer.New(`try { 1 } catch (e) { 2 }`)).ParseProgram()
+	if errObj, ok := EvalContext(ctx, program, object.NewEnvironment()).(*object.Error); !ok || !errors.Is(errObj, context.Canceled) {
+		t.Errorf("cancellation was caught. got=%v", errObj)
+	}
+}
+
+func TestImports(t *testing.T) {
+	dir, lib := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "main.mk"):     `import "c"`,
+		filepath.Join(dir, "math.mk"):     `export let square = fn(x) { x * x }; let hidden = 1; export let [one, two] = [1, 2];`,
+		filepath.Join(dir, "sub/util.mk"): `let m = import "../math"; export let cube = fn(x) { x * m["square"](x) };`,
+		filepath.Join(lib, "strings.mk"):  `export let greet = fn(name) { "hi " + name };`,
+		filepath.Join(dir, "a.mk"):        `export let b = import "b";`,
+		filepath.Join(dir, "b.mk"):        `export let a = import "a";`,
+		filepath.Join(dir, "c.mk"):        `import "main"`,
+		filepath.Join(dir, "broken.mk"):   `let x = ;`,
+		filepath.Join(dir, "fails.mk"):    `export let x = 1; x + true`,
+	}
+	for path, src := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "math"["square"](4)`, 16},
+		{`let m = import "math.mk"; m["one"] + m["two"]`, 3},
+		{`import "sub/util"["cube"](3)`, 27},
+		{`import "strings"["greet"]("bob")`, "hi bob"},
+		{`import "math"["hidden"]`, errors.New("module math has no export hidden")},
+		{`import "math"[1]`, errors.New("module members are named by strings, got INTEGER")},
+		{`import "missing"`, fmt.Errorf("cannot import missing: missing.mk not found in %s, %s", dir, lib)},
+		{`import "./strings"`, fmt.Errorf("cannot import ./strings: ./strings.mk not found in %s", dir)},
+		{`import "a"`, fmt.Errorf("import cycle: %s -> %s -> %s",
+			filepath.Join(dir, "a.mk"), filepath.Join(dir, "b.mk"), filepath.Join(dir, "a.mk"))},
+		{`import "c"`, fmt.Errorf("import cycle: %s -> %s -> %s",
+			filepath.Join(dir, "main.mk"), filepath.Join(dir, "c.mk"), filepath.Join(dir, "main.mk"))},
+		{`import "broken"`, fmt.Errorf("cannot import broken: %s: parse errors: no prefix parse function for ; found",
+			filepath.Join(dir, "broken.mk"))},
+		{`import "fails"`, errors.New("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	for _, tc := range testCases {
+		env := object.NewEnvironment()
+		env.SetFile(filepath.Join(dir, "main.mk"))
+		env.Modules().SearchPath = []string{lib}
+
+		evaluated := Eval(parser.New(lexer.New(tc.input)).ParseProgram(), env)
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerLiteral(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error for %q. got=%T (%+v)", tc.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected.Error() {
+				t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestModuleErrorsRecordTheirFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"fails.mk":  "export let x = 1;\nlet y = x / 0;",
+		"nested.mk": `import "fails"`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		input  string
+		file   string
+		line   int
+		column int
+	}{
+		{`import "fails"`, filepath.Join(dir, "fails.mk"), 2, 9},
+		{`import "nested"`, filepath.Join(dir, "fails.mk"), 2, 9},
+		{`import "missing"`, "", 1, 1},
+		{`let x = 1 / 0`, "", 1, 9},
+	}
+
+	for _, tc := range testCases {
+		env := object.NewEnvironment()
+		env.SetFile(filepath.Join(dir, "main.mk"))
+
+		errObj, ok := Eval(parser.New(lexer.New(tc.input)).ParseProgram(), env).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tc.input)
+			continue
+		}
+		if errObj.File != tc.file || errObj.Line != tc.line || errObj.Column != tc.column {
+			t.Errorf("wrong location for %q. got=%s:%d:%d, want=%s:%d:%d",
+				tc.input, errObj.File, errObj.Line, errObj.Column, tc.file, tc.line, tc.column)
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "main.mk"))
+	input := `try { import "fails" } catch (e) { throw e }`
+	errObj, ok := Eval(parser.New(lexer.New(input)).ParseProgram(), env).(*object.Error)
+	if !ok || errObj.File != filepath.Join(dir, "fails.mk") {
+		t.Errorf("rethrown error lost its file. got=%+v", errObj)
+	}
+}
+
+// Each file is run once per environment, every import of it returns the same module
+func TestImportedModulesAreCached(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "m.mk"), []byte(`export let x = 1;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "main.mk"))
+	input := `let f = fn() { import "m" }; [import "m", f(), await(spawn f)]`
+
+	evaluated, ok := Eval(parser.New(lexer.New(input)).ParseProgram(), env).(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T", evaluated)
+	}
+	for _, el := range evaluated.Elements[1:] {
+		if el != evaluated.Elements[0] {
+			t.Errorf("module loaded twice, got=%v and %v", el.Inspect(), evaluated.Elements[0].Inspect())
+		}
+	}
+	if evaluated.Elements[0].Inspect() != "module m {x}" {
+		t.Errorf("wrong module, got=%s", evaluated.Elements[0].Inspect())
+	}
+}
+
+// Tasks loading modules that import each other report the cycle instead of waiting forever
+func TestConcurrentImportCycle(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ca.mk": `rendezvous(); export let b = import "cb";`,
+		"cb.mk": `rendezvous(); export let a = import "ca";`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// both tasks are loading their module before either imports the other one
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	object.RegisterBuiltin("rendezvous", func(args ...object.Object) object.Object {
+		arrived.Done()
+		arrived.Wait()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "main.mk"))
+	input := `let a = spawn fn() { import "ca" }(); let b = spawn fn() { import "cb" }(); [await(a), await(b)]`
+
+	evaluated := EvalContext(ctx, parser.New(lexer.New(input)).ParseProgram(), env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || ctx.Err() != nil {
+		t.Fatalf("expected an import cycle error before the deadline. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	cycles := []string{
+		fmt.Sprintf("import cycle: %s -> %s -> %s", filepath.Join(dir, "ca.mk"), filepath.Join(dir, "cb.mk"), filepath.Join(dir, "ca.mk")),
+		fmt.Sprintf("import cycle: %s -> %s -> %s", filepath.Join(dir, "cb.mk"), filepath.Join(dir, "ca.mk"), filepath.Join(dir, "cb.mk")),
+	}
+	if errObj.Message != cycles[0] && errObj.Message != cycles[1] {
+		t.Errorf("wrong error. got=%q", errObj.Message)
+	}
+}
+
+func BenchmarkFrames(b *testing.B) {
+	input := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(18);"
+
+	b.Run("maps", func(b *testing.B) {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			Eval(program, object.NewEnvironment())
+		}
+	})
+
+	b.Run("slots", func(b *testing.B) {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		resolver.Resolve(program, nil)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			Eval(program, object.NewEnvironment())
+		}
+	})
+}
+
 func executeEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	return Eval(program)
+	if errs := resolver.Resolve(program, nil); len(errs) != 0 {
+		return newError("%s", errs[0])
+	}
+
+	return Eval(program, object.NewEnvironment())
 }
 
 func testIntegerLiteral(t *testing.T, obj object.Object, expected int) bool {
@@ -140,3 +1607,42 @@ func testBooleanLiteral(t *testing.T, obj object.Object, expected bool) bool {
 
 	return true
 }
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("no error object returned. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+
+	return true
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("String has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/object"
+)
+
+// evalTryExpression runs the catch block when the try block fails with a catchable error and
+// the finally block after both. Errors that can't be caught stop the evaluation without
+// running any of them, a finally block that fails or returns replaces the result of the others
+func (e *evaluator) evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.eval(node.Block, env)
+
+	if err, ok := result.(*object.Error); ok {
+		if !err.Catchable() {
+			return err
+		}
+
+		if node.CatchBlock != nil {
+			if allocErr := e.allocate(object.HashSize(len(errorFields))); allocErr != nil {
+				return allocErr
+			}
+
+			// the caught error is only visible in the catch block
+			catchEnv := object.NewEnclosedEnvironment(env)
+			if node.CatchLocals != nil {
+				catchEnv = object.NewFrameEnvironment(env, node.CatchLocals)
+			}
+			if node.Catch.Local {
+				catchEnv.SetAt(node.Catch.Slot, errorValue(err))
+			} else {
+				catchEnv.Set(node.Catch.Value, errorValue(err))
+			}
+
+			result = e.eval(node.CatchBlock, catchEnv)
+			if err, ok := result.(*object.Error); ok && !err.Catchable() {
+				return err
+			}
+		}
+	}
+
+	if node.Finally != nil {
+		finally := e.eval(node.Finally, env)
+		if finally != nil && (finally.Type() == object.Error_Obj || finally.Type() == object.ReturnValue_Obj) {
+			return finally
+		}
+	}
+
+	return result
+}
+
+// errorFields are the keys of the hashes caught errors are bound as
+var errorFields = []string{"message", "kind", "line", "column", "file"}
+
+// errorValue is the hash bound to the name of a catch block, line and column are 0 when the
+// position of the error isn't known and file is empty when it isn't a module
+func errorValue(err *object.Error) *object.Hash {
+	values := []object.Object{
+		&object.String{Value: err.Message},
+		&object.String{Value: err.ErrorKind()},
+		object.NewInteger(err.Line),
+		object.NewInteger(err.Column),
+		&object.String{Value: err.File},
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair, len(errorFields))
+	for i, field := range errorFields {
+		key := &object.String{Value: field}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: values[i]}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+// thrownError turns the value of a throw statement into a runtime error. A string is the
+// message of the error and a hash gives its fields like the ones bound by catch blocks, so a
+// caught error is thrown again unchanged. Other values are the message of the error inspected
+func thrownError(value object.Object) *object.Error {
+	err := &object.Error{Kind: object.ErrorKind}
+
+	switch value := value.(type) {
+	case *object.String:
+		err.Message = value.Value
+	case *object.Hash:
+		message, ok := hashField(value, "message").(*object.String)
+		if !ok {
+			return newKindError(object.TypeErrorKind, "thrown hash without a string message: %s", value.Inspect())
+		}
+		err.Message = message.Value
+
+		if kind, ok := hashField(value, "kind").(*object.String); ok {
+			err.Kind = kind.Value
+		}
+		if line, ok := hashField(value, "line").(*object.Integer); ok {
+			err.Line = line.Value
+		}
+		if column, ok := hashField(value, "column").(*object.Integer); ok {
+			err.Column = column.Value
+		}
+		if file, ok := hashField(value, "file").(*object.String); ok {
+			err.File = file.Value
+		}
+	default:
+		err.Message = value.Inspect()
+	}
+
+	return err
+}
+
+func hashField(hash *object.Hash, name string) object.Object {
+	pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+	if !ok {
+		return nil
+	}
+
+	return pair.Value
+}
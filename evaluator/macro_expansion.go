@@ -0,0 +1,205 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/token"
+)
+
+// MacroError is returned when a macro call can't be expanded, positioned at the call
+type MacroError struct {
+	Token   token.Token
+	Message string
+	Err     error // runtime error of the macro body, if any
+}
+
+func (me *MacroError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", me.Token.Line, me.Token.Column, me.Message)
+}
+
+func (me *MacroError) Unwrap() error { return me.Err }
+
+// DefineMacros removes the top-level let statements that bind macro literals from program,
+// binding the macros in env
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := make([]ast.Statement, 0, len(program.Statements))
+
+	for _, s := range program.Statements {
+		let, ok := s.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			statements = append(statements, s)
+			continue
+		}
+
+		macro, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, s)
+			continue
+		}
+
+		env.Set(let.Name.Value, &object.Macro{Parameters: macro.Parameters, Body: macro.Body, Env: env})
+	}
+
+	program.Statements = statements
+}
+
+// ExpandMacros replaces the calls to the macros bound in env by the code they return, the
+// arguments are passed to the macro as quotes. The code returned by a macro isn't expanded
+// again, but macro calls in the arguments are expanded before the macro runs
+func ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, error) {
+	var err error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+
+		macro, ok := lookupMacro(call, env)
+		if !ok {
+			return node
+		}
+
+		quote, expandErr := expandMacro(macro, call)
+		if expandErr != nil {
+			err = expandErr
+			return node
+		}
+
+		return freshIdentifiers(quote.Node)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expanded.(*ast.Program), nil
+}
+
+func lookupMacro(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func expandMacro(macro *object.Macro, call *ast.CallExpression) (*object.Quote, error) {
+	ident := call.Function.(*ast.Identifier)
+	name := ident.Value
+
+	for _, arg := range call.Arguments {
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			return nil, &MacroError{Token: ident.Token, Message: fmt.Sprintf("macro %s doesn't take named arguments", name)}
+		}
+	}
+
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, &MacroError{
+			Token:   ident.Token,
+			Message: fmt.Sprintf("wrong number of arguments to macro %s: want=%d, got=%d", name, len(macro.Parameters), len(call.Arguments)),
+		}
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	evaluated := unwrapReturnValue(Eval(macro.Body, env))
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, &MacroError{Token: ident.Token, Message: fmt.Sprintf("expanding macro %s: %s", name, errObj.Message), Err: errObj}
+	}
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		got := object.ObjectType(object.Null_Obj)
+		if evaluated != nil {
+			got = evaluated.Type()
+		}
+		return nil, &MacroError{Token: ident.Token, Message: fmt.Sprintf("macro %s must return a QUOTE, got %s", name, got)}
+	}
+
+	return quote, nil
+}
+
+// freshIdentifiers copies the identifiers of node, and the nodes that contain them, since the
+// resolver annotates identifiers in place and the code returned by a macro may share nodes
+// with the code it returns for other calls
+func freshIdentifiers(node ast.Node) ast.Node {
+	return ast.Modify(node, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			fresh := *ident
+			return &fresh
+		}
+		return node
+	})
+}
+
+// quote returns node without evaluating it, except for the arguments of the unquote calls
+// in it which are evaluated in env and replaced by their value
+func (e *evaluator) quote(node ast.Node, env *object.Environment) object.Object {
+	var err *object.Error
+
+	quoted := ast.Modify(node, func(node ast.Node) ast.Node {
+		if err != nil || !ast.IsCallTo(node, "unquote") {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		value := e.eval(call.Arguments[0], env)
+		if errObj, ok := value.(*object.Error); ok {
+			err = errObj
+			return node
+		}
+
+		converted, convertErr := objectToNode(value, call.Function.(*ast.Identifier).Token)
+		if convertErr != nil {
+			err = convertErr
+			return node
+		}
+
+		return converted
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: quoted}
+}
+
+// objectToNode builds the literal for the value of an unquote call, positioned at the call
+func objectToNode(obj object.Object, pos token.Token) (ast.Node, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value), Line: pos.Line, Column: pos.Column}
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Boolean:
+		tok := token.Token{Type: token.False, Literal: "false", Line: pos.Line, Column: pos.Column}
+		if obj.Value {
+			tok.Type, tok.Literal = token.True, "true"
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, nil
+	case *object.String:
+		tok := token.Token{Type: token.STRING, Literal: obj.Value, Line: pos.Line, Column: pos.Column}
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Quote:
+		return obj.Node, nil
+	}
+
+	typ := object.ObjectType(object.Null_Obj)
+	if obj != nil {
+		typ = obj.Type()
+	}
+	return nil, newError("unquote can't turn %s into code", typ)
+}
@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/parser"
+	"go-interpreter.com/m/resolver"
+)
+
+// ModuleExtension is added to the imported paths without extension
+const ModuleExtension = ".mk"
+
+// evalImportExpression returns the module imported by node, the file is run the first time
+// any script of the environment imports it. Importing a file being loaded by the evaluation,
+// or by a task waiting for the module of the evaluation, is an import cycle
+func (e *evaluator) evalImportExpression(node *ast.ImportExpression, env *object.Environment) object.Object {
+	path, err := findModule(node.Path, env.File(), env.Modules().SearchPath)
+	if err != nil {
+		return newError("cannot import %s: %s", node.Path, err)
+	}
+
+	chain := e.imports
+	if len(chain) == 0 && env.File() != "" {
+		if file, err := filepath.Abs(env.File()); err == nil {
+			chain = []string{file}
+		}
+	}
+
+	for i, imported := range chain {
+		if imported == path {
+			return importCycleError(append(chain[i:len(chain):len(chain)], path))
+		}
+	}
+
+	importer := ""
+	if len(e.imports) > 0 {
+		importer = e.imports[len(e.imports)-1]
+	}
+
+	module, loadErr := env.Modules().Load(e.ctx, path, importer, func() (*object.Module, *object.Error) {
+		imports := e.imports
+		e.imports = append(chain[:len(chain):len(chain)], path)
+		defer func() { e.imports = imports }()
+
+		return e.loadModule(node.Path, path, env)
+	})
+
+	if loadErr != nil {
+		var cycle *object.ImportCycleError
+		if errors.As(loadErr, &cycle) {
+			return importCycleError(cycle.Paths)
+		}
+		return loadErr
+	}
+
+	return module
+}
+
+func importCycleError(paths []string) *object.Error {
+	cycle := make([]string, 0, len(paths))
+	for _, p := range paths {
+		cycle = append(cycle, displayPath(p))
+	}
+
+	return newError("import cycle: %s", strings.Join(cycle, " -> "))
+}
+
+// loadModule runs the script at path in a new global scope and returns its exported
+// bindings. Parse and resolve errors are reported as runtime errors of the import, the
+// runtime errors located in the script record its path
+func (e *evaluator) loadModule(name, path string, importer *object.Environment) (*object.Module, *object.Error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("cannot import %s: %s", name, err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		return nil, newError("cannot import %s: %s: parse errors: %s", name, displayPath(path), joinErrors(p.Errors))
+	}
+
+	env := object.NewModuleEnvironment(importer, path)
+	macros := object.NewEnclosedEnvironment(env)
+	DefineMacros(program, macros)
+	program, err = ExpandMacros(program, macros)
+	if err != nil {
+		return nil, newError("cannot import %s: %s: %s", name, displayPath(path), err)
+	}
+
+	if errs := resolver.Resolve(program, nil); len(errs) != 0 {
+		return nil, newError("cannot import %s: %s: resolve errors: %s", name, displayPath(path), joinErrors(errs))
+	}
+
+	if result := e.eval(program, env); isError(result) {
+		err := result.(*object.Error)
+		if err.Line == 0 || err.File != "" {
+			return nil, err
+		}
+
+		located := *err
+		located.File = path
+		return nil, &located
+	}
+
+	module := &object.Module{Name: name, Path: path, Exports: map[string]object.Object{}}
+	for _, s := range program.Statements {
+		let, ok := s.(*ast.LetStatement)
+		if !ok || !let.Exported {
+			continue
+		}
+
+		names := []*ast.Identifier{let.Name}
+		if let.Pattern != nil {
+			names = ast.PatternIdentifiers(let.Pattern)
+		}
+		for _, ident := range names {
+			if value, ok := env.Get(ident.Value); ok {
+				module.Exports[ident.Value] = value
+			}
+		}
+	}
+
+	return module, nil
+}
+
+// findModule returns the absolute path of the file imported as name by the script at file.
+// Names starting with ./ or ../ are relative to the directory of file, the working directory
+// when file is empty. Other relative names are looked up there first and then in each
+// directory of searchPath
+func findModule(name, file string, searchPath []string) (string, error) {
+	if filepath.Ext(name) == "" {
+		name += ModuleExtension
+	}
+
+	dirs := []string{filepath.Dir(file)}
+	switch {
+	case filepath.IsAbs(name):
+		dirs = []string{""}
+	case !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../"):
+		dirs = append(dirs, searchPath...)
+	}
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return filepath.Abs(candidate)
+		}
+	}
+
+	if dirs[0] == "" {
+		return "", fmt.Errorf("%s not found", name)
+	}
+	return "", fmt.Errorf("%s not found in %s", name, strings.Join(dirs, ", "))
+}
+
+// displayPath shortens the paths under the working directory for error messages
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return rel
+}
+
+func evalModuleIndexExpression(module *object.Module, index object.Object) object.Object {
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module members are named by strings, got %s", index.Type())
+	}
+
+	value, ok := module.Exports[name.Value]
+	if !ok {
+		return newError("module %s has no export %s", module.Name, name.Value)
+	}
+
+	return value
+}
+
+func joinErrors(errs []error) string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
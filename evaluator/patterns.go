@@ -0,0 +1,210 @@
+package evaluator
+
+import (
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/object"
+)
+
+// binding is a value matched by a binding pattern, bindings are only set once the whole
+// pattern matches
+type binding struct {
+	name  *ast.Identifier
+	value object.Object
+}
+
+// mismatch is the part of a pattern that didn't match value, missingKey is set when a hash
+// pattern didn't find one of its keys
+type mismatch struct {
+	pattern    ast.Pattern
+	value      object.Object
+	missingKey object.Object
+}
+
+func (m *mismatch) error() *object.Error {
+	if m.missingKey != nil {
+		return newKindError(object.MatchErrorKind, "cannot destructure hash without key %s with %s", m.missingKey.Inspect(), m.pattern.String())
+	}
+
+	if array, ok := m.value.(*object.Array); ok {
+		if _, ok := m.pattern.(*ast.ArrayPattern); ok {
+			return newKindError(object.MatchErrorKind, "cannot destructure array of %d elements with %s", len(array.Elements), m.pattern.String())
+		}
+	}
+
+	if _, ok := m.pattern.(*ast.LiteralPattern); ok {
+		return newKindError(object.MatchErrorKind, "cannot destructure %s with %s", m.value.Inspect(), m.pattern.String())
+	}
+
+	return newKindError(object.MatchErrorKind, "cannot destructure %s with %s", m.value.Type(), m.pattern.String())
+}
+
+// evalMatchExpression evaluates the guard and the body of the matching arm in a scope of their
+// own, the names bound by the pattern aren't visible after the match expression
+func (e *evaluator) evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	value := e.eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	for _, arm := range node.Arms {
+		bindings, mismatch, err := e.matchPattern(arm.Pattern, value, env, nil)
+		if err != nil {
+			return err
+		}
+		if mismatch != nil {
+			continue
+		}
+
+		armEnv := object.NewEnclosedEnvironment(env)
+		if arm.Locals != nil {
+			armEnv = object.NewFrameEnvironment(env, arm.Locals)
+		}
+		bind(bindings, armEnv)
+
+		if arm.Guard != nil {
+			guard := e.eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return e.eval(arm.Body, armEnv)
+	}
+
+	return newKindError(object.MatchErrorKind, "no match arm matches %s", value.Inspect())
+}
+
+// destructure binds the names of pattern to the parts of value, values that don't have the
+// shape of the pattern are an error
+func (e *evaluator) destructure(pattern ast.Pattern, value object.Object, env *object.Environment) *object.Error {
+	bindings, mismatch, err := e.matchPattern(pattern, value, env, nil)
+	if err != nil {
+		return err
+	}
+	if mismatch != nil {
+		return mismatch.error()
+	}
+
+	bind(bindings, env)
+	return nil
+}
+
+func bind(bindings []binding, env *object.Environment) {
+	for _, b := range bindings {
+		if b.name.Local {
+			env.SetAt(b.name.Slot, b.value)
+		} else {
+			env.Set(b.name.Value, b.value)
+		}
+	}
+}
+
+// matchPattern appends the values of the binding patterns of pattern to bindings, it returns
+// the part of the pattern that didn't match when value doesn't match it
+func (e *evaluator) matchPattern(pattern ast.Pattern, value object.Object, env *object.Environment, bindings []binding) ([]binding, *mismatch, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return bindings, nil, nil
+	case *ast.Identifier:
+		return append(bindings, binding{name: pattern, value: value}), nil, nil
+	case *ast.LiteralPattern:
+		literal := e.eval(pattern.Value, env)
+		if err, ok := literal.(*object.Error); ok {
+			return nil, nil, err
+		}
+		if !literalEquals(literal, value) {
+			return nil, &mismatch{pattern: pattern, value: value}, nil
+		}
+		return bindings, nil, nil
+	case *ast.ArrayPattern:
+		return e.matchArrayPattern(pattern, value, env, bindings)
+	case *ast.HashPattern:
+		return e.matchHashPattern(pattern, value, env, bindings)
+	}
+
+	return nil, nil, newError("unknown pattern: %s", pattern.String())
+}
+
+func (e *evaluator) matchArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment, bindings []binding) ([]binding, *mismatch, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return nil, &mismatch{pattern: pattern, value: value}, nil
+	}
+
+	if len(array.Elements) < len(pattern.Elements) || pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+		return nil, &mismatch{pattern: pattern, value: value}, nil
+	}
+
+	for i, el := range pattern.Elements {
+		var m *mismatch
+		var err *object.Error
+		if bindings, m, err = e.matchPattern(el, array.Elements[i], env, bindings); err != nil || m != nil {
+			return nil, m, err
+		}
+	}
+
+	if _, ok := pattern.Rest.(*ast.Identifier); !ok {
+		return bindings, nil, nil
+	}
+
+	rest := array.Elements[len(pattern.Elements):]
+	if err := e.allocate(object.ArraySize(len(rest))); err != nil {
+		return nil, nil, err
+	}
+	restArray := &object.Array{Elements: append([]object.Object{}, rest...)}
+
+	return e.matchPattern(pattern.Rest, restArray, env, bindings)
+}
+
+func (e *evaluator) matchHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment, bindings []binding) ([]binding, *mismatch, *object.Error) {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return nil, &mismatch{pattern: pattern, value: value}, nil
+	}
+
+	for i, keyNode := range pattern.Keys {
+		key := e.eval(keyNode, env)
+		if err, ok := key.(*object.Error); ok {
+			return nil, nil, err
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, nil, newKindError(object.TypeErrorKind, "unusable as hash key: %s", key.Type())
+		}
+
+		pair, ok := hash.Pairs[hashKey.HashKey()]
+		if !ok {
+			return nil, &mismatch{pattern: pattern, value: value, missingKey: key}, nil
+		}
+
+		var m *mismatch
+		var err *object.Error
+		if bindings, m, err = e.matchPattern(pattern.Values[i], pair.Value, env, bindings); err != nil || m != nil {
+			return nil, m, err
+		}
+	}
+
+	return bindings, nil, nil
+}
+
+// literalEquals compares the value of a literal pattern with a matched value, values of
+// different types never match
+func literalEquals(literal, value object.Object) bool {
+	switch literal := literal.(type) {
+	case *object.Integer:
+		v, ok := value.(*object.Integer)
+		return ok && v.Value == literal.Value
+	case *object.String:
+		v, ok := value.(*object.String)
+		return ok && v.Value == literal.Value
+	case *object.Boolean:
+		v, ok := value.(*object.Boolean)
+		return ok && v.Value == literal.Value
+	}
+
+	return false
+}
@@ -7,19 +7,30 @@ type Lexer struct {
 	position     int  // Current char
 	readPosition int  // Next char
 	ch           byte // Char under examination
+	line         int  // Line of the current char
+	lineStart    int  // Position of the first char of the current line
 }
 
 func New(sourceCode string) *Lexer {
-	l := &Lexer{code: sourceCode}
+	l := &Lexer{code: sourceCode, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
 
+	line, column := l.line, l.position-l.lineStart+1
+	tok := l.readToken()
+	tok.Line, tok.Column = line, column
+
+	return tok
+}
+
+// readToken reads the token starting at the current char
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -27,6 +38,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(currentCharacter) + string(l.ch)
 			tok = token.Token{Type: token.Equal, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.Arrow, Literal: "=>"}
 		} else {
 			tok = newToken(token.Assign, l.ch)
 		}
@@ -46,7 +60,12 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.Negation, l.ch)
 		}
 	case '-':
-		tok = newToken(token.Minus, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ReturnArrow, Literal: "->"}
+		} else {
+			tok = newToken(token.Minus, l.ch)
+		}
 	case '/':
 		tok = newToken(token.Slash, l.ch)
 	case '*':
@@ -63,6 +82,35 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBrace, l.ch)
 	case '}':
 		tok = newToken(token.RBrace, l.ch)
+	case '[':
+		tok = newToken(token.LBracket, l.ch)
+	case ']':
+		tok = newToken(token.RBracket, l.ch)
+	case ':':
+		tok = newToken(token.Colon, l.ch)
+	case '|':
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.Pipe, Literal: "|>"}
+		} else {
+			tok = newToken(token.Illegal, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.Ellipsis, Literal: "..."}
+		} else {
+			tok = newToken(token.Illegal, l.ch)
+		}
+	case '"':
+		literal, terminated := l.readString()
+		if !terminated {
+			// the illegal token keeps the opening quote so the parser can tell what's wrong
+			return token.Token{Type: token.Illegal, Literal: `"` + literal}
+		}
+		tok.Type = token.STRING
+		tok.Literal = literal
 	case 0:
 		tok = newToken(token.EOF, l.ch)
 	default:
@@ -92,7 +140,21 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// peekCharAt returns the char n positions after the current one
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.position+n >= len(l.code) {
+		return 0
+	}
+
+	return l.code[l.position+n]
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
+
 	if l.readPosition >= len(l.code) {
 		l.ch = 0
 	} else {
@@ -120,6 +182,20 @@ func (l *Lexer) readDigit() string {
 	return l.code[initialPosition:l.position]
 }
 
+// readString reads the string starting at the current quote, it's not terminated when the
+// source ends before the closing quote
+func (l *Lexer) readString() (string, bool) {
+	initialPosition := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+	}
+
+	return l.code[initialPosition:l.position], l.ch == '"'
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
@@ -95,6 +95,15 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+"foobar"
+"foo bar"
+[1, 2];
+{"foo": "bar"}
+match (x) { [a, ...b] => a }
+fn(a: int) -> bool
+export let m = import "lib/m";
+try { throw e } catch (e) {} finally {}
+xs |> f | g
 `
 
 	testCases := []struct {
@@ -174,6 +183,67 @@ if (5 < 10) {
 		{token.Different, "!="},
 		{token.INT, "9"},
 		{token.Semicolon, ";"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.LBracket, "["},
+		{token.INT, "1"},
+		{token.Comma, ","},
+		{token.INT, "2"},
+		{token.RBracket, "]"},
+		{token.Semicolon, ";"},
+		{token.LBrace, "{"},
+		{token.STRING, "foo"},
+		{token.Colon, ":"},
+		{token.STRING, "bar"},
+		{token.RBrace, "}"},
+		{token.Match, "match"},
+		{token.LParen, "("},
+		{token.Ident, "x"},
+		{token.RParent, ")"},
+		{token.LBrace, "{"},
+		{token.LBracket, "["},
+		{token.Ident, "a"},
+		{token.Comma, ","},
+		{token.Ellipsis, "..."},
+		{token.Ident, "b"},
+		{token.RBracket, "]"},
+		{token.Arrow, "=>"},
+		{token.Ident, "a"},
+		{token.RBrace, "}"},
+		{token.Function, "fn"},
+		{token.LParen, "("},
+		{token.Ident, "a"},
+		{token.Colon, ":"},
+		{token.Ident, "int"},
+		{token.RParent, ")"},
+		{token.ReturnArrow, "->"},
+		{token.Ident, "bool"},
+		{token.Export, "export"},
+		{token.Let, "let"},
+		{token.Ident, "m"},
+		{token.Assign, "="},
+		{token.Import, "import"},
+		{token.STRING, "lib/m"},
+		{token.Semicolon, ";"},
+		{token.Try, "try"},
+		{token.LBrace, "{"},
+		{token.Throw, "throw"},
+		{token.Ident, "e"},
+		{token.RBrace, "}"},
+		{token.Catch, "catch"},
+		{token.LParen, "("},
+		{token.Ident, "e"},
+		{token.RParent, ")"},
+		{token.LBrace, "{"},
+		{token.RBrace, "}"},
+		{token.Finally, "finally"},
+		{token.LBrace, "{"},
+		{token.RBrace, "}"},
+		{token.Ident, "xs"},
+		{token.Pipe, "|>"},
+		{token.Ident, "f"},
+		{token.Illegal, "|"},
+		{token.Ident, "g"},
 		{token.EOF, "\x00"},
 	}
 
@@ -191,3 +261,51 @@ if (5 < 10) {
 		}
 	})
 }
+
+func TestUnterminatedString(t *testing.T) {
+	lexer := New(`x "abc`)
+	lexer.NextToken()
+
+	tok := lexer.NextToken()
+	if tok.Type != token.Illegal || tok.Literal != `"abc` || tok.Column != 3 {
+		t.Errorf("wrong token for an unterminated string. got=%+v", tok)
+	}
+
+	if tok := lexer.NextToken(); tok.Type != token.EOF {
+		t.Errorf("expected EOF after the unterminated string. got=%+v", tok)
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let five = 5;\n  five + \"a\nb\";\n\tx"
+
+	testCases := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"five", 1, 5},
+		{"=", 1, 10},
+		{"5", 1, 12},
+		{";", 1, 13},
+		{"five", 2, 3},
+		{"+", 2, 8},
+		{"a\nb", 2, 10},
+		{";", 3, 3},
+		{"x", 4, 2},
+	}
+
+	lexer := New(input)
+	for _, tc := range testCases {
+		tok := lexer.NextToken()
+		if tok.Literal != tc.expectedLiteral {
+			t.Fatalf("Token literal wrong expected=%q actual=%q", tc.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tc.expectedLine || tok.Column != tc.expectedColumn {
+			t.Errorf("Token %q position wrong expected=%d:%d actual=%d:%d",
+				tc.expectedLiteral, tc.expectedLine, tc.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
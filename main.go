@@ -1,19 +1,256 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	osUser "os/user"
+	"path/filepath"
+	"strings"
 
+	"go-interpreter.com/m/compiler"
+	"go-interpreter.com/m/evaluator"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/monkey"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/optimizer"
+	"go-interpreter.com/m/parser"
 	"go-interpreter.com/m/repl"
+	"go-interpreter.com/m/resolver"
+	"go-interpreter.com/m/typecheck"
+	"go-interpreter.com/m/types"
+	"go-interpreter.com/m/vm"
 )
 
+const usage = `usage:
+  monkey [-typecheck]                            start the REPL
+  monkey build [-typecheck] script.mk [-o out]   compile a script to bytecode, script.mkc by default
+  monkey run [-typecheck] script.mk|script.mkc   evaluate a script or run a compiled script
+  monkey infer script.mk                         print the inferred types of the top level bindings
+
+-typecheck checks the type annotations of the source before running or compiling it
+`
+
 func main() {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		startRepl(os.Args[1:])
+		return
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "build":
+		err = build(os.Args[2:])
+	case "run":
+		err = run(os.Args[2:])
+	case "infer":
+		err = infer(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func startRepl(args []string) {
+	flags := flag.NewFlagSet("monkey", flag.ExitOnError)
+	typeCheck := flags.Bool("typecheck", false, "check type annotations")
+	flags.Parse(args)
+
 	user, err := osUser.Current()
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Println(fmt.Sprintf("Hello %s feel free to type commands", user.Username))
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, *typeCheck)
+}
+
+func build(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "output file")
+	typeCheck := flags.Bool("typecheck", false, "check type annotations")
+
+	// flags may come before or after the script
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		return fmt.Errorf("build: missing script\n%s", usage)
+	}
+	source := flags.Arg(0)
+	flags.Parse(flags.Args()[1:])
+	if flags.NArg() > 0 {
+		return fmt.Errorf("build: unexpected arguments %v\n%s", flags.Args(), usage)
+	}
+
+	bytecode, err := compileFile(source, *typeCheck)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(source, filepath.Ext(source)) + ".mkc"
+	}
+
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	return os.WriteFile(*output, data, 0o644)
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	typeCheck := flags.Bool("typecheck", false, "check type annotations")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("run: expected one script\n%s", usage)
+	}
+	path := flags.Arg(0)
+
+	if filepath.Ext(path) != ".mkc" {
+		return runSource(path, *typeCheck)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	bytecode, err := compiler.UnmarshalBytecode(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		return fmt.Errorf("%s:%d: %w", path, machine.Line(), err)
+	}
+
+	return nil
+}
+
+// runSource evaluates a script, unlike the vm the evaluator supports every construct of the
+// language, like imports, exceptions and tasks
+func runSource(path string, typeCheck bool) error {
+	opts := []monkey.Option{
+		monkey.WithModulePath(filepath.SplitList(os.Getenv(repl.ModulePathVariable))...),
+		monkey.WithWarningHandler(func(warning error) {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", path, warning)
+		}),
+	}
+	if typeCheck {
+		opts = append(opts, monkey.WithTypeChecking())
+	}
+
+	_, err := monkey.New(opts...).RunFile(path)
+
+	var errObj *object.Error
+	if errors.As(err, &errObj) && errObj.Line > 0 {
+		file := path
+		if errObj.File != "" {
+			file = relativePath(errObj.File)
+		}
+		return fmt.Errorf("%s:%d:%d: %s", file, errObj.Line, errObj.Column, errObj.Message)
+	}
+	return err
+}
+
+// relativePath shortens the paths under the working directory
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return rel
+}
+
+func infer(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("infer: expected one script\n%s", usage)
+	}
+	path := args[0]
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		return fmt.Errorf("%s: parse errors:\n\t%s", path, joinErrors(p.Errors))
+	}
+
+	result, errs := typecheck.Infer(program)
+	if len(errs) != 0 {
+		return fmt.Errorf("%s: type errors:\n\t%s", path, joinErrors(errs))
+	}
+
+	for _, name := range result.Names {
+		fmt.Printf("%s: %s\n", name, result.Types[name])
+	}
+
+	return nil
+}
+
+// compileFile compiles the script at path, checking its type annotations when typeCheck is set
+func compileFile(path string, typeCheck bool) (*compiler.Bytecode, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		return nil, fmt.Errorf("%s: parse errors:\n\t%s", path, joinErrors(p.Errors))
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, warning)
+	}
+
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(program, macros)
+	program, err = evaluator.ExpandMacros(program, macros)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if errs := resolver.Resolve(program, nil); len(errs) != 0 {
+		return nil, fmt.Errorf("%s: resolve errors:\n\t%s", path, joinErrors(errs))
+	}
+
+	if typeCheck {
+		if errs := types.Check(program); len(errs) != 0 {
+			return nil, fmt.Errorf("%s: type errors:\n\t%s", path, joinErrors(errs))
+		}
+	}
+
+	c := compiler.New()
+	if err := c.Compile(optimizer.Optimize(program)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return c.Bytecode(), nil
+}
+
+func joinErrors(errs []error) string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n\t")
 }
@@ -0,0 +1,280 @@
+// Package monkey embeds the interpreter in Go programs, wiring the lexer, parser and
+// evaluator behind an Interpreter that keeps its bindings between runs
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go-interpreter.com/m/evaluator"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/parser"
+	"go-interpreter.com/m/resolver"
+	"go-interpreter.com/m/types"
+)
+
+// ParseError is returned when the source code has syntax errors, nothing is evaluated in that case
+type ParseError struct {
+	Errors []error
+}
+
+func (pe *ParseError) Error() string {
+	messages := make([]string, 0, len(pe.Errors))
+	for _, err := range pe.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return "parse error: " + strings.Join(messages, "; ")
+}
+
+// ResolveError is returned when the source code has scoping errors, like using a name before
+// the let statement that declares it, nothing is evaluated in that case
+type ResolveError struct {
+	Errors []error
+}
+
+func (re *ResolveError) Error() string {
+	messages := make([]string, 0, len(re.Errors))
+	for _, err := range re.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return "resolve error: " + strings.Join(messages, "; ")
+}
+
+// TypeError is returned by interpreters with type checking enabled when the source code has
+// type errors, nothing is evaluated in that case
+type TypeError struct {
+	Errors []error
+}
+
+func (te *TypeError) Error() string {
+	messages := make([]string, 0, len(te.Errors))
+	for _, err := range te.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return "type error: " + strings.Join(messages, "; ")
+}
+
+type Option func(*Interpreter)
+
+func WithMaxCallDepth(depth int) Option {
+	return func(i *Interpreter) { i.env.Limits().MaxCallDepth = depth }
+}
+
+func WithMaxSteps(steps int) Option {
+	return func(i *Interpreter) { i.env.Limits().MaxSteps = steps }
+}
+
+// WithMaxAllocation limits the bytes allocated for strings, arrays and hashes over the whole
+// lifetime of the interpreter
+func WithMaxAllocation(bytes int) Option {
+	return func(i *Interpreter) { i.env.Limits().MaxAllocation = bytes }
+}
+
+// WithPrelude makes the bindings of prelude visible to the interpreter, bindings made by the
+// interpreter shadow them without modifying the prelude
+func WithPrelude(prelude *Prelude) Option {
+	return func(i *Interpreter) {
+		env := object.NewPreludeEnvironment(prelude.env, i.env.Limits())
+		env.Modules().SearchPath = i.env.Modules().SearchPath
+		i.env = env
+	}
+}
+
+// WithModulePath adds dirs to the directories where imports are looked up when they aren't
+// found next to the importing script
+func WithModulePath(dirs ...string) Option {
+	return func(i *Interpreter) {
+		modules := i.env.Modules()
+		modules.SearchPath = append(modules.SearchPath, dirs...)
+	}
+}
+
+// WithWarningHandler calls handler with the warnings about the source of each run, like
+// unreachable match arms. Warnings are dropped by default
+func WithWarningHandler(handler func(warning error)) Option {
+	return func(i *Interpreter) { i.warn = handler }
+}
+
+// WithOutput makes puts print to w instead of os.Stdout, w must be safe for concurrent use
+// when scripts spawn tasks that print
+func WithOutput(w io.Writer) Option {
+	return func(i *Interpreter) { i.output = w }
+}
+
+// WithTypeChecking checks the type annotations of the source before each run, see
+// types.Check. Bindings of previous runs and of the host are checked as values of any type
+func WithTypeChecking() Option {
+	return func(i *Interpreter) { i.typeCheck = true }
+}
+
+// Interpreter evaluates source code in a global environment shared by every run,
+// it must not be used by several goroutines at the same time. Interpreters don't share
+// mutable state, so different interpreters can run on different goroutines
+type Interpreter struct {
+	env *object.Environment
+
+	// macros binds the macros defined by previous runs, their bodies can use the globals
+	// of env while expanding
+	macros *object.Environment
+
+	warn      func(warning error)
+	typeCheck bool
+	output    io.Writer
+}
+
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{env: object.NewEnvironment()}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.macros = object.NewEnclosedEnvironment(i.env)
+
+	return i
+}
+
+// Run evaluates src returning the value of its last statement, runtime errors are
+// returned as *object.Error
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is like Run but stops the evaluation once ctx is done
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		return nil, &ParseError{Errors: p.Errors}
+	}
+	if i.warn != nil {
+		for _, warning := range p.Warnings {
+			i.warn(warning)
+		}
+	}
+
+	evaluator.DefineMacros(program, i.macros)
+	program, err := evaluator.ExpandMacros(program, i.macros)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := resolver.Resolve(program, i.isDefined); len(errs) != 0 {
+		return nil, &ResolveError{Errors: errs}
+	}
+
+	if i.typeCheck {
+		if errs := types.Check(program); len(errs) != 0 {
+			return nil, &TypeError{Errors: errs}
+		}
+	}
+
+	return result(evaluator.EvalContext(i.context(ctx), program, i.env))
+}
+
+// Call applies a function or builtin to args with the same semantics as a call expression,
+// functions keep access to the bindings of the run that defined them
+func (i *Interpreter) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext is like Call but stops the evaluation once ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	return result(evaluator.ApplyFunctionContext(i.context(ctx), fn, args, i.env))
+}
+
+// context returns the context of the evaluations run by the interpreter
+func (i *Interpreter) context(ctx context.Context) context.Context {
+	if i.output != nil {
+		return object.WithOutput(ctx, i.output)
+	}
+
+	return ctx
+}
+
+// isDefined tells the resolver which globals previous runs and the host already bound
+func (i *Interpreter) isDefined(name string) bool {
+	_, ok := i.env.Get(name)
+	return ok
+}
+
+// result turns runtime errors into Go errors and a missing value into null
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errObj
+	}
+
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+
+	return obj, nil
+}
+
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the imports of the script are relative to its directory
+	previous := i.env.File()
+	i.env.SetFile(path)
+	defer i.env.SetFile(previous)
+
+	result, err := i.Run(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return result, nil
+}
+
+// Set binds name to value in the global environment of the interpreter
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.env.Set(name, value)
+}
+
+// SetFunc binds name to a builtin wrapping the Go function fn, see object.NewGoBuiltin
+// for the supported signatures
+func (i *Interpreter) SetFunc(name string, fn any) error {
+	builtin, err := object.NewGoBuiltin(name, fn)
+	if err != nil {
+		return err
+	}
+
+	i.env.Set(name, builtin)
+	return nil
+}
+
+// Get returns the value bound to name in the global environment of the interpreter
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Prelude is a global environment evaluated once and never modified afterwards, it can be
+// shared by interpreters running on several goroutines without copying its bindings
+type Prelude struct {
+	env *object.Environment
+}
+
+// NewPrelude evaluates src in a new interpreter configured by opts, the limits of the
+// interpreters using the prelude don't account for the work done by src
+func NewPrelude(src string, opts ...Option) (*Prelude, error) {
+	i := New(opts...)
+	if _, err := i.Run(src); err != nil {
+		return nil, err
+	}
+
+	return &Prelude{env: i.env}, nil
+}
+
+// Get returns the value bound to name by the prelude
+func (p *Prelude) Get(name string) (object.Object, bool) {
+	return p.env.Get(name)
+}
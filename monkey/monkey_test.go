@@ -0,0 +1,479 @@
+package monkey
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-interpreter.com/m/evaluator"
+	"go-interpreter.com/m/object"
+)
+
+func TestRun(t *testing.T) {
+	interpreter := New()
+
+	result, err := interpreter.Run("let add = fn(a, b) { a + b }; add(2, 3)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 5)
+
+	result, err = interpreter.Run("add(add(1, 1), 1)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 3)
+
+	result, err = interpreter.Run("let x = 1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != evaluator.NULL {
+		t.Errorf("result is not NULL. got=%T (%+v)", result, result)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	interpreter := New()
+
+	_, err := interpreter.Run("let = 5;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("error is not *ParseError. got=%T (%v)", err, err)
+	}
+
+	if len(parseErr.Errors) == 0 {
+		t.Errorf("parse error has no errors")
+	}
+
+	_, err = interpreter.Run("let f = fn(a, a) { b; let b = a; }; c; let c = 1;")
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) {
+		t.Fatalf("error is not *ResolveError. got=%T (%v)", err, err)
+	}
+
+	expected := "resolve error: 1:15: duplicate parameter: a; 1:20: identifier used before declaration: b; " +
+		"1:37: identifier used before declaration: c"
+	if resolveErr.Error() != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, resolveErr.Error())
+	}
+
+	if _, err := interpreter.Run("let c = 1;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := interpreter.Run("let c = c + 1; c"); err != nil {
+		t.Errorf("globals of previous runs are not declared. got=%s", err)
+	}
+
+	_, err = interpreter.Run("1 + true")
+	var runtimeErr *object.Error
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("error is not *object.Error. got=%T (%v)", err, err)
+	}
+
+	if runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", runtimeErr.Message)
+	}
+}
+
+func TestTypeChecking(t *testing.T) {
+	interpreter := New(WithTypeChecking())
+
+	_, err := interpreter.Run(`let f = fn(a: int) -> int { a * 2 }; f("a")`)
+	var typeErr *TypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("error is not *TypeError. got=%T (%v)", err, err)
+	}
+
+	expected := "type error: 1:40: cannot use string as int in argument 1 of f"
+	if typeErr.Error() != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, typeErr.Error())
+	}
+
+	if _, err := interpreter.Run("let x: int = 2;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// bindings of previous runs aren't typed
+	result, err := interpreter.Run(`let double = fn(a: int) -> int { a * 2 }; double(x)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 4)
+}
+
+func TestOptions(t *testing.T) {
+	interpreter := New(WithMaxSteps(50))
+	_, err := interpreter.Run("let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)")
+	if !errors.Is(err, evaluator.ErrStepBudgetExceeded) {
+		t.Errorf("error is not ErrStepBudgetExceeded. got=%v", err)
+	}
+
+	interpreter = New(WithMaxCallDepth(5))
+	_, err = interpreter.Run("let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10)")
+	if err == nil {
+		t.Errorf("expected maximum recursion depth error")
+	}
+
+	interpreter = New(WithMaxAllocation(10))
+	_, err = interpreter.Run(`"0123456789" + "0123456789"`)
+	if !errors.Is(err, evaluator.ErrMemoryLimitExceeded) {
+		t.Errorf("error is not ErrMemoryLimitExceeded. got=%v", err)
+	}
+
+	var warnings []string
+	interpreter = New(WithWarningHandler(func(warning error) { warnings = append(warnings, warning.Error()) }))
+	if _, err := interpreter.Run("match (1) { _ => 1, 2 => 2 }"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0] != "1:21: unreachable match arm, the arm at 1:13 matches every value" {
+		t.Errorf("wrong warnings. got=%q", warnings)
+	}
+
+	var output strings.Builder
+	interpreter = New(WithOutput(&output))
+	if _, err := interpreter.Run(`puts("a", 1); let f = fn() { puts([2]) }`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fn, _ := interpreter.Get("f")
+	if _, err := interpreter.Call(fn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.String() != "a\n1\n[2]\n" {
+		t.Errorf("wrong output. got=%q", output.String())
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	interpreter := New()
+	interpreter.Set("answer", &object.Integer{Value: 42})
+
+	result, err := interpreter.Run("let double = answer * 2; double")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 84)
+
+	double, ok := interpreter.Get("double")
+	if !ok {
+		t.Fatalf("double is not defined")
+	}
+	testInteger(t, double, 84)
+
+	if _, ok := interpreter.Get("missing"); ok {
+		t.Errorf("missing should not be defined")
+	}
+}
+
+func TestSetFunc(t *testing.T) {
+	interpreter := New()
+	err := interpreter.SetFunc("divide", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("cannot divide by zero")
+		}
+		return a / b, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interpreter.Run("divide(10, 2)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 5)
+
+	_, err = interpreter.Run("divide(1, 0)")
+	if err == nil || err.Error() != "divide: cannot divide by zero" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	if err := interpreter.SetFunc("crash", func() { panic("host bug") }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = interpreter.Run(`try { crash() } catch (e) { throw e["message"] }`)
+	if err == nil || err.Error() != "crash: panic: host bug" {
+		t.Errorf("wrong error for a panicking host function. got=%v", err)
+	}
+
+	// functions are bound to the interpreter that set them
+	if _, err := New().Run("divide(10, 2)"); err == nil {
+		t.Errorf("divide should only be defined in the interpreter that set it")
+	}
+}
+
+func TestSetFuncNamedArguments(t *testing.T) {
+	type options struct {
+		Timeout int `monkey:"timeout"`
+		Retries int `monkey:"retries"`
+	}
+
+	interpreter := New()
+	err := interpreter.SetFunc("request", func(url string, opts object.Keywords[options]) string {
+		return fmt.Sprintf("%s timeout=%d retries=%d", url, opts.Value.Timeout, opts.Value.Retries)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = interpreter.SetFunc("tags", func(name string, tags object.Keywords[map[string]string]) int { return len(tags.Value) })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`request("a", timeout: 30, retries: 3)`, "a timeout=30 retries=3"},
+		{`request("a", retries: 1)`, "a timeout=0 retries=1"},
+		{`request("a")`, "a timeout=0 retries=0"},
+		{`tags("a", env: "prod", team: "core")`, "2"},
+	}
+
+	for _, tc := range testCases {
+		result, err := interpreter.Run(tc.input)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.input, err)
+		}
+		if result.Inspect() != tc.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tc.input, result.Inspect(), tc.expected)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`request("a", tmeout: 30)`, "named arguments to `request`: cannot convert: key \"tmeout\" doesn't match any field of monkey.options"},
+		{`request("a", timeout: "x")`, "named arguments to `request`: cannot convert timeout: STRING can't be stored in int"},
+		{`len("a", x: 1)`, "`len` doesn't take named arguments"},
+	}
+
+	for _, tc := range errorCases {
+		_, err := interpreter.Run(tc.input)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("wrong error for %q. got=%v, want=%q", tc.input, err, tc.expected)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	interpreter := New()
+	_, err := interpreter.Run(`
+let offset = 10;
+let handlers = {"add": fn(x) { x + offset }, "len": len};
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	handlers, _ := interpreter.Get("handlers")
+	add := handlers.(*object.Hash).Pairs[(&object.String{Value: "add"}).HashKey()].Value
+
+	result, err := interpreter.Call(add, &object.Integer{Value: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 15)
+
+	interpreter.Set("offset", &object.Integer{Value: 100})
+	result, err = interpreter.Call(add, &object.Integer{Value: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 105)
+
+	length := handlers.(*object.Hash).Pairs[(&object.String{Value: "len"}).HashKey()].Value
+	result, err = interpreter.Call(length, &object.String{Value: "four"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 4)
+
+	testCases := []struct {
+		fn       object.Object
+		args     []object.Object
+		expected string
+	}{
+		{add, nil, "expected 1 argument, got 0"},
+		{add, []object.Object{evaluator.TRUE}, "type mismatch: BOOLEAN + INTEGER"},
+		{&object.Integer{Value: 1}, nil, "not a function: INTEGER"},
+	}
+
+	for _, tc := range testCases {
+		_, err := interpreter.Call(tc.fn, tc.args...)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("wrong error. got=%v, want=%q", err, tc.expected)
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte("let x = 20; x + 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New().RunFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 21)
+
+	if _, err := New().RunFile(filepath.Join(t.TempDir(), "missing.mk")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("error is not os.ErrNotExist. got=%v", err)
+	}
+}
+
+func TestRunFileImports(t *testing.T) {
+	dir, lib := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "script.mk"): `let math = import "math"; math["double"](import "consts"["ten"])`,
+		filepath.Join(dir, "math.mk"):   `export let double = fn(x) { x * 2 };`,
+		filepath.Join(lib, "consts.mk"): `export let ten = 10;`,
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New(WithModulePath(lib)).RunFile(filepath.Join(dir, "script.mk"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 20)
+
+	_, err = New().RunFile(filepath.Join(dir, "script.mk"))
+	if err == nil || !strings.Contains(err.Error(), "cannot import consts") {
+		t.Errorf("expected an import error without the module path, got=%v", err)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	interpreter := New()
+
+	_, err := interpreter.Run(`
+let factor = 10;
+let unless = macro(condition, consequence, alternative) {
+  quote(if (!(unquote(condition))) { unquote(consequence) } else { unquote(alternative) })
+};`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interpreter.Run("unless(1 > 2, factor * 2, 0)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 20)
+
+	if _, ok := interpreter.Get("unless"); ok {
+		t.Errorf("macros should not be bound in the global environment")
+	}
+
+	_, err = interpreter.Run("unless(true)")
+	var macroErr *evaluator.MacroError
+	if !errors.As(err, &macroErr) {
+		t.Fatalf("error is not *evaluator.MacroError. got=%T (%v)", err, err)
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	prelude, err := NewPrelude(`
+let base = 100;
+let scale = fn(x) { x * base };
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	interpreter := New(WithPrelude(prelude))
+	result, err := interpreter.Run("let base = 1; scale(2) + base")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 201)
+
+	base, _ := prelude.Get("base")
+	testInteger(t, base, 100)
+
+	if _, err := New(WithPrelude(prelude)).Run("base"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the work done by the prelude isn't accounted against the interpreter
+	interpreter = New(WithPrelude(prelude), WithMaxSteps(200))
+	if _, err := interpreter.Run("fib(15)"); !errors.Is(err, evaluator.ErrStepBudgetExceeded) {
+		t.Errorf("error is not ErrStepBudgetExceeded. got=%v", err)
+	}
+	if _, err := interpreter.Run("scale(1)"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if _, err := NewPrelude("let = 1;"); err == nil {
+		t.Errorf("expected error for invalid prelude")
+	}
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	prelude, err := NewPrelude(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let words = ["a", "b", "c"];
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	const workers = 8
+	results := make([]object.Object, workers)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			interpreter := New(WithPrelude(prelude), WithMaxAllocation(1<<20))
+			interpreter.Set("id", &object.Integer{Value: w})
+			src := "let words = push(words, \"" + strconv.Itoa(w) + "\"); let n = fib(12) + id; len(words) * 1000 + n"
+			results[w], errs[w] = interpreter.Run(src)
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		if errs[w] != nil {
+			t.Fatalf("worker %d failed: %s", w, errs[w])
+		}
+		testInteger(t, results[w], 4000+144+w)
+	}
+
+	words, _ := prelude.Get("words")
+	if words.Inspect() != `[a, b, c]` {
+		t.Errorf("prelude was modified. got=%s", words.Inspect())
+	}
+}
+
+func testInteger(t *testing.T, obj object.Object, expected int) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
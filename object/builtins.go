@@ -0,0 +1,261 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// AnyType accepted by ExpectArguments in the positions where any object is valid
+const AnyType = ObjectType("")
+
+// BuiltinFunction implements a function available to every script, returning nil evaluates to null
+type BuiltinFunction func(args ...Object) Object
+
+// ContextBuiltinFunction implements a builtin that may block, it must return once ctx is done
+type ContextBuiltinFunction func(ctx context.Context, args ...Object) Object
+
+type Builtin struct {
+	Name      string
+	Fn        BuiltinFunction
+	ContextFn ContextBuiltinFunction // used instead of Fn when set
+
+	// Keywords is set by the builtins that take arguments by name, they get them in a hash
+	// after the positional arguments. Calls passing arguments by name to other builtins fail
+	Keywords bool
+}
+
+func (b *Builtin) Type() ObjectType { return Builtin_Obj }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// Call applies the builtin to args, ctx interrupts the builtins that block
+func (b *Builtin) Call(ctx context.Context, args ...Object) Object {
+	if b.ContextFn != nil {
+		return b.ContextFn(ctx, args...)
+	}
+
+	return b.Fn(args...)
+}
+
+var builtins = struct {
+	sync.RWMutex
+	byName map[string]*Builtin
+}{byName: make(map[string]*Builtin)}
+
+func init() {
+	RegisterBuiltin("len", builtinLen)
+	RegisterContextBuiltin("puts", builtinPuts)
+	RegisterBuiltin("first", builtinFirst)
+	RegisterBuiltin("last", builtinLast)
+	RegisterBuiltin("rest", builtinRest)
+	RegisterBuiltin("push", builtinPush)
+	RegisterBuiltin("type", builtinType)
+
+	RegisterContextBuiltin("await", builtinAwait)
+	RegisterBuiltin("channel", builtinChannel)
+	RegisterContextBuiltin("send", builtinSend)
+	RegisterContextBuiltin("recv", builtinRecv)
+	RegisterContextBuiltin("select", builtinSelect)
+}
+
+// RegisterBuiltin makes fn available to every script under name, identifiers bound in the
+// environment shadow builtins and registering an existing name replaces the previous builtin.
+// The builtins are shared by every interpreter of the process, a function meant for a single
+// one is bound in its environment instead, see monkey.Interpreter.SetFunc
+func RegisterBuiltin(name string, fn BuiltinFunction) *Builtin {
+	return registerBuiltin(&Builtin{Name: name, Fn: fn})
+}
+
+// RegisterContextBuiltin is like RegisterBuiltin for builtins that block, Fn applies them
+// with a context that is never done
+func RegisterContextBuiltin(name string, fn ContextBuiltinFunction) *Builtin {
+	return registerBuiltin(&Builtin{
+		Name:      name,
+		Fn:        func(args ...Object) Object { return fn(context.Background(), args...) },
+		ContextFn: fn,
+	})
+}
+
+func registerBuiltin(builtin *Builtin) *Builtin {
+	builtins.Lock()
+	defer builtins.Unlock()
+	builtins.byName[builtin.Name] = builtin
+
+	return builtin
+}
+
+func LookupBuiltin(name string) (*Builtin, bool) {
+	builtins.RLock()
+	defer builtins.RUnlock()
+
+	builtin, ok := builtins.byName[name]
+	return builtin, ok
+}
+
+func NewError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// NewTypeError is like NewError for arguments of the wrong type or count
+func NewTypeError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: TypeErrorKind}
+}
+
+// NewArityError is the type error of a call passing got arguments to the function name, which
+// takes min to max of them or at least min when max is negative. Anonymous functions have no name
+func NewArityError(name string, min, max, got int) *Error {
+	var expected string
+	switch {
+	case max < 0:
+		expected = fmt.Sprintf("at least %d %s", min, plural(min, "argument"))
+	case min == max:
+		expected = fmt.Sprintf("%d %s", min, plural(min, "argument"))
+	default:
+		expected = fmt.Sprintf("%d..%d arguments", min, max)
+	}
+
+	message := fmt.Sprintf("expected %s, got %d", expected, got)
+	if name != "" {
+		message = name + ": " + message
+	}
+
+	return &Error{Message: message, Kind: TypeErrorKind}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// ExpectArgumentCount returns an error when the builtin name didn't get n arguments
+func ExpectArgumentCount(name string, args []Object, n int) *Error {
+	if len(args) != n {
+		return NewTypeError("wrong number of arguments to `%s`: want=%d, got=%d", name, n, len(args))
+	}
+
+	return nil
+}
+
+// ExpectArguments returns an error when the builtin name didn't get one argument for each
+// type or an argument is not of the type at its position, AnyType accepts every object
+func ExpectArguments(name string, args []Object, types ...ObjectType) *Error {
+	if err := ExpectArgumentCount(name, args, len(types)); err != nil {
+		return err
+	}
+
+	for i, t := range types {
+		if t != AnyType && args[i].Type() != t {
+			return NewTypeError("argument %d to `%s` must be %s, got %s", i+1, name, t, args[i].Type())
+		}
+	}
+
+	return nil
+}
+
+func builtinLen(args ...Object) Object {
+	if err := ExpectArgumentCount("len", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return NewInteger(len(arg.Value))
+	case *Array:
+		return NewInteger(len(arg.Elements))
+	case *Hash:
+		return NewInteger(len(arg.Pairs))
+	}
+
+	return NewTypeError("argument to `len` not supported, got %s", args[0].Type())
+}
+
+type outputKey struct{}
+
+// WithOutput returns a context whose evaluations print to w with puts
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// Output returns the writer puts prints to in the evaluations of ctx, os.Stdout by default
+func Output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+
+	return os.Stdout
+}
+
+func builtinPuts(ctx context.Context, args ...Object) Object {
+	w := Output(ctx)
+	for _, arg := range args {
+		fmt.Fprintln(w, arg.Inspect())
+	}
+
+	return nil
+}
+
+func builtinFirst(args ...Object) Object {
+	if err := ExpectArguments("first", args, Array_Obj); err != nil {
+		return err
+	}
+
+	elements := args[0].(*Array).Elements
+	if len(elements) == 0 {
+		return nil
+	}
+
+	return elements[0]
+}
+
+func builtinLast(args ...Object) Object {
+	if err := ExpectArguments("last", args, Array_Obj); err != nil {
+		return err
+	}
+
+	elements := args[0].(*Array).Elements
+	if len(elements) == 0 {
+		return nil
+	}
+
+	return elements[len(elements)-1]
+}
+
+func builtinRest(args ...Object) Object {
+	if err := ExpectArguments("rest", args, Array_Obj); err != nil {
+		return err
+	}
+
+	elements := args[0].(*Array).Elements
+	if len(elements) == 0 {
+		return nil
+	}
+
+	rest := make([]Object, len(elements)-1)
+	copy(rest, elements[1:])
+	return &Array{Elements: rest}
+}
+
+func builtinPush(args ...Object) Object {
+	if err := ExpectArguments("push", args, Array_Obj, AnyType); err != nil {
+		return err
+	}
+
+	elements := args[0].(*Array).Elements
+	pushed := make([]Object, len(elements)+1)
+	copy(pushed, elements)
+	pushed[len(elements)] = args[1]
+
+	return &Array{Elements: pushed}
+}
+
+func builtinType(args ...Object) Object {
+	if err := ExpectArgumentCount("type", args, 1); err != nil {
+		return err
+	}
+
+	return &String{Value: string(args[0].Type())}
+}
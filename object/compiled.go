@@ -0,0 +1,52 @@
+package object
+
+import (
+	"fmt"
+
+	"go-interpreter.com/m/code"
+)
+
+// Capture tells where a closure finds one of its free variables when it's created, either a
+// local of the enclosing function or a free variable of the enclosing closure
+type Capture struct {
+	Local bool
+	Index int
+}
+
+// CompiledFunction is the bytecode of a function literal, it's only visible to scripts
+// wrapped in a Closure
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Lines         code.LineTable
+	Name          string
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string // indexed by local slot, used to report unset locals
+	FreeNames     []string // indexed by free variable, used to report unset free variables
+	Captures      []Capture
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return CompiledFunction_Obj }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// FreeVariable refers to a local slot of the function call that defined it, so closures
+// see later assignments to the variables they capture as the evaluator does
+type FreeVariable struct {
+	Locals []Object
+	Index  int
+}
+
+func (fv FreeVariable) Get() Object { return fv.Locals[fv.Index] }
+
+// Closure is a function value of the vm, for scripts it's indistinguishable from a Function
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []FreeVariable
+}
+
+func (c *Closure) Type() ObjectType { return Function_Obj }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
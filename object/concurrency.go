@@ -0,0 +1,549 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Scripts run functions concurrently with spawn and pass values between them over channels.
+// Values are never modified once built, so any of them can be shared. The scopes a task can
+// reach through its function are shared with the code that spawned it: a let statement run
+// by that code after the spawn may or may not be visible to the task, values must be handed
+// over with a channel or returned to await for the task to be sure to see them. Sending a
+// value happens before receiving it and the end of a task happens before await returns.
+
+// ErrDeadlock is wrapped by the error of the tasks that were blocked when every task of the
+// evaluation was waiting on a channel or on another task, since none of them could wake up
+var ErrDeadlock = errors.New("deadlock: all tasks are blocked")
+
+// Scheduler counts the tasks of an evaluation that are running, the ones not blocked on a
+// channel or on another task. When none is left the evaluation is cancelled with ErrDeadlock.
+// Its lock guards the tasks of the evaluation and the channels it used first, handing a value
+// to a blocked task and counting it as running again happen at once. Channels are meant to be
+// used by the tasks of a single evaluation, a task blocked on the channel or the task of
+// another evaluation still counts as running and select can't mix channels of two evaluations
+type Scheduler struct {
+	mu      sync.Mutex
+	running int
+	blocked map[*waiter]bool
+	cancel  context.CancelCauseFunc // nil when the scheduler only guards objects used without one
+}
+
+type schedulerKey struct{}
+
+// WithScheduler returns a context whose blocking builtins detect deadlocks, the caller is its
+// only running task. A context that already has a scheduler is returned as it is
+func WithScheduler(ctx context.Context) (context.Context, context.CancelFunc) {
+	if schedulerOf(ctx) != nil {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	s := &Scheduler{running: 1, blocked: map[*waiter]bool{}, cancel: cancel}
+	return context.WithValue(ctx, schedulerKey{}, s), func() { cancel(nil) }
+}
+
+func schedulerOf(ctx context.Context) *Scheduler {
+	s, _ := ctx.Value(schedulerKey{}).(*Scheduler)
+	return s
+}
+
+// schedulerFor returns the scheduler of ctx, or one that only guards objects when ctx has none
+func schedulerFor(ctx context.Context) *Scheduler {
+	if s := schedulerOf(ctx); s != nil {
+		return s
+	}
+
+	return &Scheduler{}
+}
+
+// The methods of the scheduler must be called with its lock held, a nil scheduler or one
+// without cancel function doesn't count anything
+
+func (s *Scheduler) counts() bool {
+	return s != nil && s.cancel != nil
+}
+
+func (s *Scheduler) start() {
+	if s.counts() {
+		s.running++
+	}
+}
+
+func (s *Scheduler) stop() {
+	if s.counts() {
+		s.running--
+		s.detectDeadlock()
+	}
+}
+
+func (s *Scheduler) block(w *waiter) {
+	if s.counts() {
+		s.blocked[w] = true
+		s.stop()
+	}
+}
+
+func (s *Scheduler) wake(w *waiter) {
+	if s.counts() {
+		delete(s.blocked, w)
+		s.start()
+	}
+}
+
+// detectDeadlock cancels the evaluation when no task is running, unless a blocked one was
+// cancelled and is about to wake up
+func (s *Scheduler) detectDeadlock() {
+	if s.running > 0 {
+		return
+	}
+
+	for w := range s.blocked {
+		if w.ctx.Err() != nil {
+			return
+		}
+	}
+
+	s.cancel(ErrDeadlock)
+}
+
+// waiter is a blocked task, it's registered in the queues of the channels or the tasks that
+// can wake it up and removed from all of them once one does. They're all guarded by the lock
+// of guard, the waiter only counts as blocked in scheduler when it's the same one
+type waiter struct {
+	guard     *Scheduler
+	scheduler *Scheduler
+	ctx       context.Context
+	woken     chan struct{}
+	fired     bool
+	queues    []*[]*waiter
+
+	value   Object   // the value to send or the one received
+	channel *Channel // the channel a receive was woken by
+}
+
+// newWaiter must be called with the lock of guard held
+func newWaiter(ctx context.Context, guard *Scheduler) *waiter {
+	w := &waiter{guard: guard, woken: make(chan struct{})}
+	if schedulerOf(ctx) == guard {
+		w.scheduler = guard
+	}
+	return w
+}
+
+func (w *waiter) register(queue *[]*waiter) {
+	*queue = append(*queue, w)
+	w.queues = append(w.queues, queue)
+}
+
+// fire wakes the waiter up, the lock of its guard must be held
+func (w *waiter) fire() {
+	w.fired = true
+	for _, queue := range w.queues {
+		for i, other := range *queue {
+			if other == w {
+				*queue = append((*queue)[:i], (*queue)[i+1:]...)
+				break
+			}
+		}
+	}
+	w.scheduler.wake(w)
+	close(w.woken)
+}
+
+// wait counts the waiter as blocked, releases the lock of its guard and waits until it's
+// woken up. It returns false when ctx is done first
+func (w *waiter) wait(ctx context.Context) bool {
+	w.ctx = ctx
+	w.scheduler.block(w)
+	w.guard.mu.Unlock()
+
+	select {
+	case <-w.woken:
+		return true
+	case <-ctx.Done():
+	}
+
+	w.guard.mu.Lock()
+	defer w.guard.mu.Unlock()
+
+	if w.fired {
+		return true
+	}
+	w.fire()
+	return false
+}
+
+// Task is the handle of a function running on its own goroutine, it's guarded by the lock
+// of the scheduler of the evaluation that started it
+type Task struct {
+	scheduler *Scheduler
+	done      chan struct{}
+	result    Object
+	awaited   atomic.Bool
+	awaiters  []*waiter
+}
+
+// NewTask counts a task started by the evaluation of ctx as running, the task must call
+// Finish when it ends
+func NewTask(ctx context.Context) *Task {
+	t := &Task{scheduler: schedulerFor(ctx), done: make(chan struct{})}
+
+	t.scheduler.mu.Lock()
+	defer t.scheduler.mu.Unlock()
+
+	t.scheduler.start()
+	return t
+}
+
+func (t *Task) Type() ObjectType { return Task_Obj }
+func (t *Task) Inspect() string  { return "task" }
+
+// Finish records the result of the task and wakes up the ones awaiting it, it's called once
+func (t *Task) Finish(result Object) {
+	t.scheduler.mu.Lock()
+	defer t.scheduler.mu.Unlock()
+
+	t.result = result
+	close(t.done)
+	for len(t.awaiters) > 0 {
+		t.awaiters[0].fire()
+	}
+	t.scheduler.stop()
+}
+
+// Done is closed once the task finished
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Result returns the value or the error the task finished with, it must be called after Done
+// is closed
+func (t *Task) Result() Object {
+	return t.result
+}
+
+// Awaited reports whether a script awaited the task, the errors of tasks that weren't
+// awaited are reported by the evaluation that spawned them
+func (t *Task) Awaited() bool {
+	return t.awaited.Load()
+}
+
+// finished must be called with the lock of the scheduler of the task held
+func (t *Task) finished() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitAny blocks until one of tasks finished and returns its index. Unlike await it doesn't
+// stop once ctx is done, the tasks do. The tasks must have been started by one evaluation
+func WaitAny(ctx context.Context, tasks []*Task) int {
+	guard := tasks[0].scheduler
+	guard.mu.Lock()
+
+	for i, t := range tasks {
+		if t.finished() {
+			guard.mu.Unlock()
+			return i
+		}
+	}
+
+	w := newWaiter(ctx, guard)
+	for _, t := range tasks {
+		w.register(&t.awaiters)
+	}
+	w.wait(context.WithoutCancel(ctx))
+
+	for i, t := range tasks {
+		if t.finished() {
+			return i
+		}
+	}
+	panic("WaitAny woken up before any task finished")
+}
+
+// event is fired once and wakes up the tasks waiting for it, it's guarded by the lock of the
+// scheduler of the evaluation that created it
+type event struct {
+	scheduler *Scheduler
+	done      chan struct{}
+	awaiters  []*waiter
+}
+
+func newEvent(ctx context.Context) *event {
+	return &event{scheduler: schedulerFor(ctx), done: make(chan struct{})}
+}
+
+// fire wakes up the tasks waiting for the event, it's called once
+func (ev *event) fire() {
+	ev.scheduler.mu.Lock()
+	defer ev.scheduler.mu.Unlock()
+
+	close(ev.done)
+	for len(ev.awaiters) > 0 {
+		ev.awaiters[0].fire()
+	}
+}
+
+// wait blocks until the event is fired, it returns false when ctx is done first
+func (ev *event) wait(ctx context.Context) bool {
+	ev.scheduler.mu.Lock()
+	select {
+	case <-ev.done:
+		ev.scheduler.mu.Unlock()
+		return true
+	default:
+	}
+
+	w := newWaiter(ctx, ev.scheduler)
+	w.register(&ev.awaiters)
+	return w.wait(ctx)
+}
+
+// Channel passes values between tasks, it holds up to its capacity values without a receiver.
+// It's guarded by the lock of the scheduler of the first evaluation that used it
+type Channel struct {
+	scheduler atomic.Pointer[Scheduler]
+	capacity  int
+	buffer    []Object
+	receivers []*waiter
+	senders   []*waiter
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{capacity: capacity}
+}
+
+func (c *Channel) Type() ObjectType { return Channel_Obj }
+func (c *Channel) Inspect() string  { return "channel" }
+
+// guard returns the scheduler guarding the channel, it's s when the channel wasn't used yet
+func (c *Channel) guard(s *Scheduler) *Scheduler {
+	c.scheduler.CompareAndSwap(nil, s)
+	return c.scheduler.Load()
+}
+
+// lock locks the scheduler guarding the channel and returns it
+func (c *Channel) lock(ctx context.Context) *Scheduler {
+	guard := c.scheduler.Load()
+	if guard == nil {
+		guard = c.guard(schedulerFor(ctx))
+	}
+
+	guard.mu.Lock()
+	return guard
+}
+
+// send hands value to a blocked receiver or buffers it, it must be called with the channel
+// locked and returns false when the channel is full
+func (c *Channel) send(value Object) bool {
+	if len(c.receivers) > 0 {
+		receiver := c.receivers[0]
+		receiver.value, receiver.channel = value, c
+		receiver.fire()
+		return true
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, value)
+		return true
+	}
+
+	return false
+}
+
+// receive takes a buffered value or the one of a blocked sender, it must be called with the
+// channel locked and returns false when there is none
+func (c *Channel) receive() (Object, bool) {
+	if len(c.buffer) > 0 {
+		value := c.buffer[0]
+		c.buffer[0] = nil
+		c.buffer = c.buffer[1:]
+		if len(c.senders) > 0 {
+			c.buffer = append(c.buffer, c.senders[0].value)
+			c.senders[0].fire()
+		}
+		return value, true
+	}
+
+	if len(c.senders) > 0 {
+		sender := c.senders[0]
+		sender.fire()
+		return sender.value, true
+	}
+
+	return nil, false
+}
+
+// Share makes the scopes reachable from the functions in obj safe to use from other
+// goroutines, it must be called before obj is handed to another task
+func Share(obj Object) {
+	switch obj := obj.(type) {
+	case *Function:
+		obj.Env.share()
+	case *Array:
+		for _, el := range obj.Elements {
+			Share(el)
+		}
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			Share(pair.Key)
+			Share(pair.Value)
+		}
+	case *Module:
+		for _, value := range obj.Exports {
+			Share(value)
+		}
+	}
+}
+
+func builtinAwait(ctx context.Context, args ...Object) Object {
+	if err := ExpectArguments("await", args, Task_Obj); err != nil {
+		return err
+	}
+
+	task := args[0].(*Task)
+	task.awaited.Store(true)
+
+	guard := task.scheduler
+	guard.mu.Lock()
+	if task.finished() {
+		guard.mu.Unlock()
+		return task.result
+	}
+
+	w := newWaiter(ctx, guard)
+	w.register(&task.awaiters)
+	if !w.wait(ctx) {
+		return NewInterruptedError(context.Cause(ctx))
+	}
+
+	return task.result
+}
+
+func builtinChannel(args ...Object) Object {
+	if err := ExpectArguments("channel", args, Integer_Obj); err != nil {
+		return err
+	}
+
+	capacity := args[0].(*Integer).Value
+	if capacity < 0 {
+		return NewTypeError("capacity of `channel` must not be negative, got %d", capacity)
+	}
+
+	return NewChannel(capacity)
+}
+
+func builtinSend(ctx context.Context, args ...Object) Object {
+	if err := ExpectArguments("send", args, Channel_Obj, AnyType); err != nil {
+		return err
+	}
+
+	Share(args[1])
+	channel := args[0].(*Channel)
+
+	guard := channel.lock(ctx)
+	if channel.send(args[1]) {
+		guard.mu.Unlock()
+		return nil
+	}
+
+	w := newWaiter(ctx, guard)
+	w.value = args[1]
+	w.register(&channel.senders)
+	if !w.wait(ctx) {
+		return NewInterruptedError(context.Cause(ctx))
+	}
+
+	return nil
+}
+
+func builtinRecv(ctx context.Context, args ...Object) Object {
+	if err := ExpectArguments("recv", args, Channel_Obj); err != nil {
+		return err
+	}
+
+	channel := args[0].(*Channel)
+
+	guard := channel.lock(ctx)
+	if value, ok := channel.receive(); ok {
+		guard.mu.Unlock()
+		return value
+	}
+
+	w := newWaiter(ctx, guard)
+	w.register(&channel.receivers)
+	if !w.wait(ctx) {
+		return NewInterruptedError(context.Cause(ctx))
+	}
+
+	return w.value
+}
+
+// builtinSelect receives from the first of an array of channels that has a value, returning
+// the index of the channel and the value. With a second argument it doesn't block, returning
+// that argument when no channel has a value
+func builtinSelect(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return NewTypeError("wrong number of arguments to `select`: want=1 or 2, got=%d", len(args))
+	}
+
+	channels, ok := args[0].(*Array)
+	if !ok {
+		return NewTypeError("argument 1 to `select` must be %s, got %s", Array_Obj, args[0].Type())
+	}
+
+	for i, el := range channels.Elements {
+		if _, ok := el.(*Channel); !ok {
+			return NewTypeError("element %d of argument 1 to `select` must be %s, got %s", i, Channel_Obj, el.Type())
+		}
+	}
+
+	selected := func(channel *Channel, value Object) Object {
+		for i, el := range channels.Elements {
+			if el == channel {
+				return &Array{Elements: []Object{NewInteger(i), value}}
+			}
+		}
+		return nil
+	}
+
+	guard := schedulerFor(ctx)
+	if len(channels.Elements) > 0 {
+		guard = channels.Elements[0].(*Channel).guard(guard)
+	}
+	for _, el := range channels.Elements {
+		if el.(*Channel).guard(guard) != guard {
+			return NewTypeError("channels passed to `select` must be used by a single evaluation")
+		}
+	}
+
+	guard.mu.Lock()
+	for _, el := range channels.Elements {
+		if value, ok := el.(*Channel).receive(); ok {
+			guard.mu.Unlock()
+			return selected(el.(*Channel), value)
+		}
+	}
+
+	if len(args) == 2 {
+		guard.mu.Unlock()
+		return args[1]
+	}
+
+	w := newWaiter(ctx, guard)
+	for _, el := range channels.Elements {
+		w.register(&el.(*Channel).receivers)
+	}
+	if !w.wait(ctx) {
+		return NewInterruptedError(context.Cause(ctx))
+	}
+
+	return selected(w.channel, w.value)
+}
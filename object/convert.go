@@ -0,0 +1,496 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ConversionError describes a Go value that can't be converted to an object or the other way
+// around, Path locates the value inside slices, maps and structs
+type ConversionError struct {
+	Path    string
+	Message string
+}
+
+func (ce *ConversionError) Error() string {
+	if ce.Path == "" {
+		return "cannot convert: " + ce.Message
+	}
+
+	return fmt.Sprintf("cannot convert %s: %s", ce.Path, ce.Message)
+}
+
+// maxExactFloat is the biggest integer a float64 holds without losing precision
+const maxExactFloat = 1 << 53
+
+var objectInterface = reflect.TypeOf((*Object)(nil)).Elem()
+
+// FromGo converts ints, bools, strings, integral floats, slices, arrays, maps and structs to
+// objects. Struct fields become hash keys named after the field or its `monkey:"name"` tag,
+// fields tagged `monkey:"-"` and unexported fields are skipped. nil becomes null and objects
+// are returned as they are
+func FromGo(value any) (Object, error) {
+	if value == nil {
+		return NULL, nil
+	}
+
+	return fromGo(reflect.ValueOf(value), "")
+}
+
+func fromGo(v reflect.Value, path string) (Object, error) {
+	return fromGoValue(v, path, map[reference]bool{})
+}
+
+// reference identifies the pointers, maps and slices being converted, reaching one of them
+// again while converting it means the value is cyclic
+type reference struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// enter marks the value v refers to as being converted, it fails when it already is
+func enter(v reflect.Value, path string, visiting map[reference]bool) (reference, error) {
+	ref := reference{ptr: v.Pointer(), typ: v.Type()}
+	if visiting[ref] {
+		return ref, &ConversionError{Path: path, Message: fmt.Sprintf("cyclic %s can't be converted", v.Type())}
+	}
+
+	visiting[ref] = true
+	return ref, nil
+}
+
+func fromGoValue(v reflect.Value, path string, visiting map[reference]bool) (Object, error) {
+	if v.Type().Implements(objectInterface) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fromGoInt(v.Int(), path)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return nil, &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows INTEGER", v.Uint())}
+		}
+		return NewInteger(int(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || math.Abs(f) > maxExactFloat {
+			return nil, &ConversionError{Path: path, Message: fmt.Sprintf("%v can't be represented as INTEGER without losing precision", f)}
+		}
+		return NewInteger(int(f)), nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Interface {
+			return fromGoValue(v.Elem(), path, visiting)
+		}
+
+		ref, err := enter(v, path, visiting)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(visiting, ref)
+		return fromGoValue(v.Elem(), path, visiting)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			ref, err := enter(v, path, visiting)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(visiting, ref)
+		}
+
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := fromGoValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		ref, err := enter(v, path, visiting)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(visiting, ref)
+
+		pairs := make(map[HashKey]HashPair, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromGoValue(iter.Key(), path, visiting)
+			if err != nil {
+				return nil, err
+			}
+
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, &ConversionError{Path: path, Message: fmt.Sprintf("%s is unusable as hash key", key.Type())}
+			}
+
+			value, err := fromGoValue(iter.Value(), fmt.Sprintf("%s[%s]", path, key.Inspect()), visiting)
+			if err != nil {
+				return nil, err
+			}
+
+			pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	case reflect.Struct:
+		pairs := make(map[HashKey]HashPair)
+		for _, field := range structFields(v.Type()) {
+			// fields promoted through a nil embedded pointer don't exist
+			fieldValue, err := v.FieldByIndexErr(field.index)
+			if err != nil {
+				continue
+			}
+
+			value, err := fromGoValue(fieldValue, joinPath(path, field.name), visiting)
+			if err != nil {
+				return nil, err
+			}
+
+			key := &String{Value: field.name}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+	}
+
+	return nil, &ConversionError{Path: path, Message: fmt.Sprintf("unsupported Go type %s", v.Type())}
+}
+
+func fromGoInt(i int64, path string) (Object, error) {
+	if i > math.MaxInt || i < math.MinInt {
+		return nil, &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows INTEGER", i)}
+	}
+
+	return NewInteger(int(i)), nil
+}
+
+// ToGo stores obj in the value target points to, converting integers, booleans, strings,
+// arrays and hashes to the Go type of the target. Conversions that would overflow the
+// target or drop hash keys fail. A target of type any receives int, bool, string, []any,
+// map[string]any (map[any]any when the hash has keys other than strings) or nil
+func ToGo(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return &ConversionError{Message: fmt.Sprintf("target must be a non nil pointer, got %T", target)}
+	}
+
+	return toGo(obj, v.Elem(), "")
+}
+
+func toGo(obj Object, v reflect.Value, path string) error {
+	if obj == nil {
+		obj = NULL
+	}
+
+	if v.Type().Implements(objectInterface) && reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if _, ok := obj.(*Null); ok {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return mismatch(obj, v, path)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch(obj, v, path)
+		}
+
+		native, err := toNative(obj, path)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+		return nil
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := toGo(obj, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		v.SetBool(b.Value)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		if v.OverflowInt(int64(i.Value)) {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows %s", i.Value, v.Type())}
+		}
+		v.SetInt(int64(i.Value))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%d overflows %s", i.Value, v.Type())}
+		}
+		v.SetUint(uint64(i.Value))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		limit := int64(maxExactFloat)
+		if v.Kind() == reflect.Float32 {
+			limit = 1 << 24
+		}
+		if int64(i.Value) > limit || int64(i.Value) < -limit {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%d can't be represented as %s without losing precision", i.Value, v.Type())}
+		}
+		v.SetFloat(float64(i.Value))
+		return nil
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		v.SetString(s.Value)
+		return nil
+	case reflect.Slice:
+		array, ok := obj.(*Array)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(array.Elements), len(array.Elements))
+		for i, element := range array.Elements {
+			if err := toGo(element, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Array:
+		array, ok := obj.(*Array)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		if len(array.Elements) != v.Len() {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("ARRAY of %d elements doesn't fit in %s", len(array.Elements), v.Type())}
+		}
+		for i, element := range array.Elements {
+			if err := toGo(element, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := toGo(pair.Key, key, path); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := toGo(pair.Value, value, fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, v, path)
+		}
+		return hashToStruct(hash, v, path)
+	}
+
+	return &ConversionError{Path: path, Message: fmt.Sprintf("unsupported Go type %s", v.Type())}
+}
+
+func hashToStruct(hash *Hash, v reflect.Value, path string) error {
+	fields := make(map[string][]int)
+	for _, field := range structFields(v.Type()) {
+		fields[field.name] = field.index
+	}
+
+	for _, pair := range hash.Pairs {
+		key, ok := pair.Key.(*String)
+		if !ok {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("%s key %s can't name a field of %s", pair.Key.Type(), pair.Key.Inspect(), v.Type())}
+		}
+
+		index, ok := fields[key.Value]
+		if !ok {
+			return &ConversionError{Path: path, Message: fmt.Sprintf("key %q doesn't match any field of %s", key.Value, v.Type())}
+		}
+
+		field, err := allocatedField(v, index, joinPath(path, key.Value))
+		if err != nil {
+			return err
+		}
+		if err := toGo(pair.Value, field, joinPath(path, key.Value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allocatedField returns the field of the struct v at index, allocating the nil embedded
+// pointers the field is promoted through
+func allocatedField(v reflect.Value, index []int, path string) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, &ConversionError{Path: path, Message: fmt.Sprintf("nil embedded %s can't be allocated", v.Type())}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, nil
+}
+
+// toNative converts obj to the Go type a value of type any receives
+func toNative(obj Object, path string) (any, error) {
+	switch obj := obj.(type) {
+	case *Null:
+		return nil, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		for i, element := range obj.Elements {
+			native, err := toNative(element, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = native
+		}
+		return elements, nil
+	case *Hash:
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != String_Obj {
+				stringKeys = false
+				break
+			}
+		}
+
+		if stringKeys {
+			m := make(map[string]any, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				key := pair.Key.(*String).Value
+				value, err := toNative(pair.Value, joinPath(path, key))
+				if err != nil {
+					return nil, err
+				}
+				m[key] = value
+			}
+			return m, nil
+		}
+
+		m := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, _ := toNative(pair.Key, path)
+			value, err := toNative(pair.Value, fmt.Sprintf("%s[%s]", path, pair.Key.Inspect()))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+
+	return obj, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields lists the exported fields of t with the name scripts use for them
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("monkey"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, structField{name: name, index: field.Index})
+	}
+
+	return fields
+}
+
+func mismatch(obj Object, v reflect.Value, path string) error {
+	return &ConversionError{Path: path, Message: fmt.Sprintf("%s can't be stored in %s", obj.Type(), v.Type())}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return path + "." + field
+}
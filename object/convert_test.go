@@ -0,0 +1,230 @@
+package object
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type person struct {
+	Name    string `monkey:"name"`
+	Age     int    `monkey:"age"`
+	Email   string
+	Secret  string `monkey:"-"`
+	private int
+}
+
+type Address struct {
+	City string `monkey:"city"`
+}
+
+type employee struct {
+	*Address
+	Name string `monkey:"name"`
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestFromGo(t *testing.T) {
+	testCases := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "null"},
+		{5, "5"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{4.0, "4"},
+		{true, "true"},
+		{"hello", "hello"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]any{1, "two", nil}, "[1, two, null]"},
+		{map[string]int{"one": 1}, "{one: 1}"},
+		{&person{Name: "Ana"}, ""},
+		{(*person)(nil), "null"},
+	}
+
+	for _, tc := range testCases {
+		obj, err := FromGo(tc.value)
+		if err != nil {
+			t.Errorf("FromGo(%#v) returned error: %s", tc.value, err)
+			continue
+		}
+
+		if tc.expected != "" && obj.Inspect() != tc.expected {
+			t.Errorf("FromGo(%#v) wrong object. got=%q, want=%q", tc.value, obj.Inspect(), tc.expected)
+		}
+	}
+
+	if obj, _ := FromGo(true); obj != TRUE {
+		t.Errorf("FromGo(true) is not TRUE. got=%p", obj)
+	}
+}
+
+func TestFromGoStruct(t *testing.T) {
+	obj, err := FromGo(person{Name: "Ana", Age: 30, Email: "ana@example.com", Secret: "x", private: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hash, ok := obj.(*Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T", obj)
+	}
+
+	expected := map[string]string{"name": "Ana", "age": "30", "Email": "ana@example.com"}
+	if len(hash.Pairs) != len(expected) {
+		t.Errorf("hash has wrong number of pairs. got=%d", len(hash.Pairs))
+	}
+
+	for key, value := range expected {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("key %q missing", key)
+			continue
+		}
+
+		if pair.Value.Inspect() != value {
+			t.Errorf("wrong value for %q. got=%q, want=%q", key, pair.Value.Inspect(), value)
+		}
+	}
+}
+
+func TestFromGoEmbeddedPointers(t *testing.T) {
+	obj, err := FromGo(employee{Name: "Ana"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj.Inspect() != "{name: Ana}" {
+		t.Errorf("fields behind a nil embedded pointer should be skipped. got=%s", obj.Inspect())
+	}
+
+	var e employee
+	hash, _ := FromGo(map[string]any{"name": "Ana", "city": "Lima"})
+	if err := ToGo(hash, &e); err != nil || e.Address == nil || e.City != "Lima" {
+		t.Errorf("ToGo should allocate nil embedded pointers. got=%+v, err=%v", e, err)
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	cyclic := &node{Value: 1}
+	cyclic.Next = &node{Value: 2, Next: cyclic}
+	nested := []any{1, nil}
+	nested[1] = nested
+	self := map[string]any{}
+	self["self"] = self
+
+	testCases := []struct {
+		value    any
+		expected string
+	}{
+		{3.5, "cannot convert: 3.5 can't be represented as INTEGER without losing precision"},
+		{uint64(1 << 63), "cannot convert: 9223372036854775808 overflows INTEGER"},
+		{[]any{1, make(chan int)}, "cannot convert [1]: unsupported Go type chan int"},
+		{map[string]float64{"pi": 3.14}, "cannot convert [pi]: 3.14 can't be represented as INTEGER without losing precision"},
+		{cyclic, "cannot convert Next.Next: cyclic *object.node can't be converted"},
+		{nested, "cannot convert [1]: cyclic []interface {} can't be converted"},
+		{self, "cannot convert [self]: cyclic map[string]interface {} can't be converted"},
+	}
+
+	for _, tc := range testCases {
+		_, err := FromGo(tc.value)
+		var convErr *ConversionError
+		if !errors.As(err, &convErr) {
+			t.Errorf("FromGo(%#v) error is not *ConversionError. got=%v", tc.value, err)
+			continue
+		}
+
+		if err.Error() != tc.expected {
+			t.Errorf("wrong error. got=%q, want=%q", err.Error(), tc.expected)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	var i int
+	if err := ToGo(&Integer{Value: 5}, &i); err != nil || i != 5 {
+		t.Errorf("ToGo int failed. got=%d, err=%v", i, err)
+	}
+
+	var f float64
+	if err := ToGo(&Integer{Value: 5}, &f); err != nil || f != 5 {
+		t.Errorf("ToGo float64 failed. got=%v, err=%v", f, err)
+	}
+
+	var s []string
+	array := &Array{Elements: []Object{&String{Value: "a"}, &String{Value: "b"}}}
+	if err := ToGo(array, &s); err != nil || !reflect.DeepEqual(s, []string{"a", "b"}) {
+		t.Errorf("ToGo []string failed. got=%v, err=%v", s, err)
+	}
+
+	var p person
+	hash, _ := FromGo(map[string]any{"name": "Ana", "age": 30})
+	if err := ToGo(hash, &p); err != nil || p.Name != "Ana" || p.Age != 30 {
+		t.Errorf("ToGo struct failed. got=%+v, err=%v", p, err)
+	}
+
+	var native any
+	nested, _ := FromGo(map[string]any{"list": []int{1, 2}, "ok": true})
+	if err := ToGo(nested, &native); err != nil {
+		t.Fatalf("ToGo any failed: %v", err)
+	}
+	expected := map[string]any{"list": []any{1, 2}, "ok": true}
+	if !reflect.DeepEqual(native, expected) {
+		t.Errorf("ToGo any wrong value. got=%#v, want=%#v", native, expected)
+	}
+
+	var ptr *int
+	if err := ToGo(NULL, &ptr); err != nil || ptr != nil {
+		t.Errorf("ToGo null pointer failed. got=%v, err=%v", ptr, err)
+	}
+
+	var obj Object
+	if err := ToGo(TRUE, &obj); err != nil || obj != TRUE {
+		t.Errorf("ToGo Object failed. got=%v, err=%v", obj, err)
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i8 int8
+	var u uint
+	var str string
+	var p person
+	var arr [2]int
+	var f32 float32
+
+	unknownKey, _ := FromGo(map[string]any{"name": "Ana", "nickname": "A"})
+	badAge, _ := FromGo(map[string]any{"age": "old"})
+
+	testCases := []struct {
+		obj      Object
+		target   any
+		expected string
+	}{
+		{&Integer{Value: 300}, &i8, "cannot convert: 300 overflows int8"},
+		{&Integer{Value: -1}, &u, "cannot convert: -1 overflows uint"},
+		{&Integer{Value: 1}, &str, "cannot convert: INTEGER can't be stored in string"},
+		{NULL, &i8, "cannot convert: NULL can't be stored in int8"},
+		{unknownKey, &p, `cannot convert: key "nickname" doesn't match any field of object.person`},
+		{badAge, &p, "cannot convert age: STRING can't be stored in int"},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &arr, "cannot convert: ARRAY of 1 elements doesn't fit in [2]int"},
+		{&Integer{Value: 1<<24 + 1}, &f32, "cannot convert: 16777217 can't be represented as float32 without losing precision"},
+		{&Integer{Value: 1}, i8, "cannot convert: target must be a non nil pointer, got int8"},
+	}
+
+	for _, tc := range testCases {
+		err := ToGo(tc.obj, tc.target)
+		if err == nil {
+			t.Errorf("ToGo(%s, %T) expected error %q", tc.obj.Inspect(), tc.target, tc.expected)
+			continue
+		}
+
+		if err.Error() != tc.expected {
+			t.Errorf("wrong error. got=%q, want=%q", err.Error(), tc.expected)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package object
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Environment binds names to values. The frames of resolved functions keep their locals in
+// slots indexed by the resolver, other bindings live in store
+type Environment struct {
+	store   map[string]Object
+	slots   []Object
+	names   []string // names of the slots
+	outer   *Environment
+	limits  *Limits
+	modules *Modules
+	file    string // script whose globals the environment holds, if known
+
+	// mu guards store and slots once the environment is reachable from several tasks, until
+	// then only the goroutine that created it uses it and no lock is taken
+	mu atomic.Pointer[sync.RWMutex]
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{
+		store:   make(map[string]Object),
+		limits:  &Limits{MaxCallDepth: DefaultMaxCallDepth},
+		modules: NewModules(),
+	}
+}
+
+// NewEnclosedEnvironment creates a scope on top of outer, sharing its limits
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{
+		store:   make(map[string]Object),
+		outer:   outer,
+		limits:  outer.limits,
+		modules: outer.modules,
+	}
+}
+
+// NewPreludeEnvironment creates a global scope on top of prelude bounded by limits instead of
+// the limits of prelude. Names not bound in the new scope are looked up in prelude but every
+// binding is made in the new scope, so a prelude that isn't modified anymore can be shared by
+// environments evaluated on several goroutines
+func NewPreludeEnvironment(prelude *Environment, limits *Limits) *Environment {
+	return &Environment{
+		store:   make(map[string]Object),
+		outer:   prelude,
+		limits:  limits,
+		modules: NewModules(),
+	}
+}
+
+// NewModuleEnvironment creates the global scope of the module loaded from file by a script
+// evaluated in importer, the module shares the limits and the modules of importer but none
+// of its bindings
+func NewModuleEnvironment(importer *Environment, file string) *Environment {
+	return &Environment{
+		store:   make(map[string]Object),
+		limits:  importer.limits,
+		modules: importer.modules,
+		file:    file,
+	}
+}
+
+// NewFrameEnvironment creates a scope on top of outer with a slot for each of names
+func NewFrameEnvironment(outer *Environment, names []string) *Environment {
+	return &Environment{
+		slots:   make([]Object, len(names)),
+		names:   names,
+		outer:   outer,
+		limits:  outer.limits,
+		modules: outer.modules,
+	}
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.lookup(name); ok {
+			return obj, true
+		}
+	}
+
+	return nil, false
+}
+
+func (e *Environment) lookup(name string) (Object, bool) {
+	if mu := e.mu.Load(); mu != nil {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	if obj, ok := e.store[name]; ok {
+		return obj, true
+	}
+
+	for i, slotName := range e.names {
+		if slotName == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func (e *Environment) Set(name string, value Object) Object {
+	if mu := e.mu.Load(); mu != nil {
+		mu.Lock()
+		defer mu.Unlock()
+	}
+
+	for i, slotName := range e.names {
+		if slotName == name {
+			e.slots[i] = value
+			return value
+		}
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
+	e.store[name] = value
+	return value
+}
+
+// GetAt returns the value of slot in the frame depth scopes up, nil while it's unset
+func (e *Environment) GetAt(depth, slot int) Object {
+	env := e
+	for i := 0; i < depth; i++ {
+		env = env.outer
+	}
+
+	if mu := env.mu.Load(); mu != nil {
+		mu.RLock()
+		value := env.slots[slot]
+		mu.RUnlock()
+		return value
+	}
+
+	return env.slots[slot]
+}
+
+// SetAt binds slot of this frame to value
+func (e *Environment) SetAt(slot int, value Object) Object {
+	if mu := e.mu.Load(); mu != nil {
+		mu.Lock()
+		e.slots[slot] = value
+		mu.Unlock()
+		return value
+	}
+
+	e.slots[slot] = value
+	return value
+}
+
+// share makes the environment and the ones enclosing it lock on every access
+func (e *Environment) share() {
+	for env := e; env != nil; env = env.outer {
+		env.mu.CompareAndSwap(nil, new(sync.RWMutex))
+	}
+}
+
+// Outer returns the enclosing scope, nil for the global one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+func (e *Environment) Limits() *Limits {
+	return e.limits
+}
+
+func (e *Environment) Modules() *Modules {
+	return e.modules
+}
+
+// File returns the script whose code runs in the environment, imports are relative to its
+// directory. It's empty for code that doesn't come from a file, like REPL inputs
+func (e *Environment) File() string {
+	for env := e; env != nil; env = env.outer {
+		if env.file != "" {
+			return env.file
+		}
+	}
+
+	return ""
+}
+
+// SetFile records the script whose globals the environment holds
+func (e *Environment) SetFile(path string) {
+	e.file = path
+}
@@ -0,0 +1,160 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorInterface    = reflect.TypeOf((*error)(nil)).Elem()
+	keywordsInterface = reflect.TypeOf((*keywordParameter)(nil)).Elem()
+)
+
+// Keywords is the type of the last parameter of a Go function that takes arguments by name,
+// see NewGoBuiltin. Value is usually a struct or a map with string keys
+type Keywords[T any] struct {
+	Value T
+}
+
+func (Keywords[T]) keywords() {}
+
+// keywordParameter is implemented by every Keywords type
+type keywordParameter interface {
+	keywords()
+}
+
+// NewGoBuiltin wraps any Go function as a builtin named name. Arguments are converted with
+// ToGo to the parameter types, variadic functions accept any number of trailing arguments.
+// The function may return nothing, a value, an error, or a value and an error; values are
+// converted with FromGo and a non nil error becomes a runtime error.
+// When the last parameter of a function that isn't variadic is a Keywords, its Value gets the
+// arguments passed by name and it's the zero value when none is passed. Struct fields are
+// matched as by ToGo, so unknown names are errors
+func NewGoBuiltin(name string, fn any) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("builtin %s: expected a function, got %T", name, fn)
+	}
+
+	t := v.Type()
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorInterface
+	values := t.NumOut()
+	if returnsError {
+		values--
+	}
+
+	if values > 1 {
+		return nil, fmt.Errorf("builtin %s: functions can return at most one value and an error, got %s", name, t)
+	}
+
+	builtin := &Builtin{Name: name, Keywords: takesKeywords(t)}
+	builtin.Fn = func(args ...Object) Object {
+		in, err := goArguments(name, t, args, builtin.Keywords)
+		if err != nil {
+			return err
+		}
+
+		out, panicErr := callGo(name, v, in)
+		if panicErr != nil {
+			return panicErr
+		}
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &Error{Message: fmt.Sprintf("%s: %s", name, err), Err: err}
+			}
+		}
+
+		if values == 0 {
+			return nil
+		}
+
+		result, convErr := fromGo(out[0], "")
+		if convErr != nil {
+			return &Error{Message: fmt.Sprintf("%s: result %s", name, convErr), Err: convErr}
+		}
+
+		return result
+	}
+
+	return builtin, nil
+}
+
+// callGo calls fn returning the panics of the host function as runtime errors, so they
+// don't crash the program embedding the interpreter
+func callGo(name string, fn reflect.Value, in []reflect.Value) (out []reflect.Value, err *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{Message: fmt.Sprintf("%s: panic: %v", name, r)}
+			if cause, ok := r.(error); ok {
+				err.Err = cause
+			}
+		}
+	}()
+
+	return fn.Call(in), nil
+}
+
+// RegisterGoFunction wraps fn with NewGoBuiltin and registers it under name for every
+// interpreter of the process, see RegisterBuiltin
+func RegisterGoFunction(name string, fn any) (*Builtin, error) {
+	builtin, err := NewGoBuiltin(name, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return registerBuiltin(builtin), nil
+}
+
+// takesKeywords reports whether the last parameter of the function type t gets the
+// arguments passed by name
+func takesKeywords(t reflect.Type) bool {
+	if t.NumIn() == 0 || t.IsVariadic() {
+		return false
+	}
+
+	return t.In(t.NumIn() - 1).Implements(keywordsInterface)
+}
+
+func goArguments(name string, t reflect.Type, args []Object, keywords bool) ([]reflect.Value, *Error) {
+	params := t.NumIn()
+	switch {
+	case t.IsVariadic():
+		if len(args) < params-1 {
+			return nil, NewError("wrong number of arguments to `%s`: want at least %d, got=%d", name, params-1, len(args))
+		}
+	case keywords && len(args) == params-1:
+	default:
+		if err := ExpectArgumentCount(name, args, params); err != nil {
+			return nil, err
+		}
+	}
+
+	in := make([]reflect.Value, 0, len(args)+1)
+	for i, arg := range args {
+		var paramType reflect.Type
+		if t.IsVariadic() && i >= params-1 {
+			paramType = t.In(params - 1).Elem()
+		} else {
+			paramType = t.In(i)
+		}
+
+		value := reflect.New(paramType).Elem()
+		target := value
+		if keywords && i == params-1 {
+			target = value.Field(0)
+		}
+		if err := toGo(arg, target, ""); err != nil {
+			if keywords && i == params-1 {
+				return nil, &Error{Message: fmt.Sprintf("named arguments to `%s`: %s", name, err), Err: err}
+			}
+			return nil, &Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err), Err: err}
+		}
+		in = append(in, value)
+	}
+
+	if len(in) < params && !t.IsVariadic() {
+		in = append(in, reflect.Zero(t.In(params-1)))
+	}
+
+	return in, nil
+}
@@ -0,0 +1,104 @@
+package object
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewGoBuiltin(t *testing.T) {
+	repeat, err := NewGoBuiltin("repeat", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result := repeat.Fn(&String{Value: "ab"}, &Integer{Value: 3})
+	if result.Inspect() != "ababab" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	testCases := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{&String{Value: "ab"}}, "wrong number of arguments to `repeat`: want=2, got=1"},
+		{[]Object{&String{Value: "ab"}, &String{Value: "3"}}, "argument 2 to `repeat`: cannot convert: STRING can't be stored in int"},
+		{[]Object{&String{Value: "ab"}, &Integer{Value: -1}}, "repeat: negative count"},
+	}
+
+	for _, tc := range testCases {
+		errObj, ok := repeat.Fn(tc.args...).(*Error)
+		if !ok {
+			t.Errorf("no error returned for %v", tc.args)
+			continue
+		}
+
+		if errObj.Message != tc.expected {
+			t.Errorf("wrong error message. got=%q, want=%q", errObj.Message, tc.expected)
+		}
+	}
+}
+
+func TestNewGoBuiltinSignatures(t *testing.T) {
+	sum, _ := NewGoBuiltin("sum", func(base int, xs ...int) int {
+		for _, x := range xs {
+			base += x
+		}
+		return base
+	})
+	if result := sum.Fn(&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}); result.Inspect() != "6" {
+		t.Errorf("wrong variadic result. got=%q", result.Inspect())
+	}
+	if result := sum.Fn(&Integer{Value: 1}); result.Inspect() != "1" {
+		t.Errorf("wrong variadic result. got=%q", result.Inspect())
+	}
+
+	called := false
+	noop, _ := NewGoBuiltin("noop", func() { called = true })
+	if result := noop.Fn(); result != nil || !called {
+		t.Errorf("function without results should return nil. got=%v", result)
+	}
+
+	failing, _ := NewGoBuiltin("fail", func() error { return errors.New("boom") })
+	errObj, ok := failing.Fn().(*Error)
+	if !ok || errObj.Message != "fail: boom" || errObj.Err.Error() != "boom" {
+		t.Errorf("wrong error. got=%v", failing.Fn())
+	}
+
+	options, _ := NewGoBuiltin("options", func(name string, opts Keywords[map[string]int]) int { return len(opts.Value) })
+	if !options.Keywords || sum.Keywords {
+		t.Errorf("only functions whose last parameter is a Keywords take named arguments")
+	}
+	if result := options.Fn(&String{Value: "a"}); result.Inspect() != "0" {
+		t.Errorf("missing named arguments should be an empty map. got=%q", result.Inspect())
+	}
+
+	// a plain map or struct is an ordinary positional parameter
+	merge, _ := NewGoBuiltin("merge", func(name string, opts map[string]int) int { return len(opts) })
+	if merge.Keywords {
+		t.Errorf("a map parameter shouldn't take named arguments")
+	}
+	errObj, ok = merge.Fn(&String{Value: "a"}).(*Error)
+	if !ok || errObj.Message != "wrong number of arguments to `merge`: want=2, got=1" {
+		t.Errorf("missing map argument should be an arity error. got=%v", merge.Fn(&String{Value: "a"}))
+	}
+
+	panicking, _ := NewGoBuiltin("explode", func(xs []int) int { return xs[3] })
+	errObj, ok = panicking.Fn(&Array{Elements: []Object{}}).(*Error)
+	if !ok || errObj.Message != "explode: panic: runtime error: index out of range [3] with length 0" || errObj.Err == nil {
+		t.Errorf("panics should become runtime errors. got=%v", panicking.Fn(&Array{}))
+	}
+
+	if _, err := NewGoBuiltin("bad", 5); err == nil {
+		t.Errorf("expected error for non function value")
+	}
+
+	if _, err := NewGoBuiltin("bad", func() (int, int) { return 1, 2 }); err == nil {
+		t.Errorf("expected error for function with two results")
+	}
+}
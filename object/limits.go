@@ -0,0 +1,124 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultMaxCallDepth is the number of nested function applications allowed when
+// an environment is created without explicit limits
+const DefaultMaxCallDepth = 1024
+
+// ErrStepBudgetExceeded is wrapped by the error returned when an evaluation goes over
+// the MaxSteps limit
+var ErrStepBudgetExceeded = errors.New("step budget exceeded")
+
+// ErrMemoryLimitExceeded is wrapped by the error returned when a script allocates more
+// bytes than the MaxAllocation limit
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// ErrCallDepthExceeded is wrapped by the error returned when a call goes over the
+// MaxCallDepth limit
+var ErrCallDepthExceeded = errors.New("maximum recursion depth exceeded")
+
+// NewInterruptedError is returned when the context of an evaluation is done before it finishes,
+// err is the error of the context
+func NewInterruptedError(err error) *Error {
+	return &Error{Message: fmt.Sprintf("evaluation interrupted: %s", err), Err: err}
+}
+
+// Limits bounds the resources available to the scripts evaluated in an environment,
+// a zero value disables the corresponding limit
+type Limits struct {
+	MaxCallDepth int
+	MaxSteps     int // number of AST nodes, or instructions in the vm, a single evaluation may run
+	// MaxAllocation is the number of bytes that can be allocated for strings, arrays and hashes
+	// over the lifetime of the environment, memory released by the garbage collector is not
+	// given back to the scripts
+	MaxAllocation int
+
+	allocated int64
+}
+
+// Allocate records size bytes as allocated and returns the total allocated by every
+// environment sharing these limits
+func (l *Limits) Allocate(size int) int {
+	return int(atomic.AddInt64(&l.allocated, int64(size)))
+}
+
+func (l *Limits) Allocated() int {
+	return int(atomic.LoadInt64(&l.allocated))
+}
+
+// Reserve accounts size bytes against MaxAllocation, the allocation is not recorded when it
+// would go over the limit so the caller must not build the object
+func (l *Limits) Reserve(size int) *Error {
+	if size == 0 {
+		return nil
+	}
+
+	total := l.Allocate(size)
+	if l.MaxAllocation > 0 && total > l.MaxAllocation {
+		l.Allocate(-size)
+		return &Error{
+			Message: fmt.Sprintf("%s: allocating %d bytes would go over the limit of %d bytes", ErrMemoryLimitExceeded, size, l.MaxAllocation),
+			Err:     ErrMemoryLimitExceeded,
+		}
+	}
+
+	return nil
+}
+
+// CheckSteps returns an error once steps goes over MaxSteps
+func (l *Limits) CheckSteps(steps int) *Error {
+	if l.MaxSteps > 0 && steps > l.MaxSteps {
+		return &Error{
+			Message: fmt.Sprintf("%s: more than %d steps evaluated", ErrStepBudgetExceeded, l.MaxSteps),
+			Err:     ErrStepBudgetExceeded,
+		}
+	}
+
+	return nil
+}
+
+// CheckCallDepth returns an error when one more function can't be applied on top of
+// callChain, the names of the functions being applied outermost first
+func (l *Limits) CheckCallDepth(callChain []string) *Error {
+	if l.MaxCallDepth > 0 && len(callChain) >= l.MaxCallDepth {
+		return &Error{
+			Message: fmt.Sprintf("%s (%d)\ncall chain: %s", ErrCallDepthExceeded, l.MaxCallDepth, FormatCallChain(callChain)),
+			Err:     ErrCallDepthExceeded,
+		}
+	}
+
+	return nil
+}
+
+// FormatCallChain renders the functions being applied collapsing consecutive calls to the
+// same function, so deep self recursion reads as "fib (x1024)" instead of a thousand names
+func FormatCallChain(calls []string) string {
+	chain := []string{}
+	for i := 0; i < len(calls); {
+		j := i
+		for j < len(calls) && calls[j] == calls[i] {
+			j++
+		}
+
+		if j-i > 1 {
+			chain = append(chain, fmt.Sprintf("%s (x%d)", calls[i], j-i))
+		} else {
+			chain = append(chain, calls[i])
+		}
+		i = j
+	}
+
+	const shown = 5
+	if len(chain) > 2*shown {
+		omitted := len(chain) - 2*shown
+		chain = append(append(chain[:shown:shown], fmt.Sprintf("... %d more ...", omitted)), chain[len(chain)-shown:]...)
+	}
+
+	return strings.Join(chain, " -> ")
+}
@@ -0,0 +1,43 @@
+package object
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatCallChain(t *testing.T) {
+	testCases := []struct {
+		calls    []string
+		expected string
+	}{
+		{[]string{"main"}, "main"},
+		{[]string{"main", "fib", "fib", "fib"}, "main -> fib (x3)"},
+		{
+			[]string{"a", "b", "a", "b", "a", "b", "a", "b", "a", "b", "a", "b"},
+			"a -> b -> a -> b -> a -> ... 2 more ... -> b -> a -> b -> a -> b",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := FormatCallChain(tc.calls); got != tc.expected {
+			t.Errorf("wrong call chain. got=%q, want=%q", got, tc.expected)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	limits := &Limits{MaxAllocation: 100}
+
+	if err := limits.Reserve(60); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := limits.Reserve(60)
+	if err == nil || !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Fatalf("error does not wrap ErrMemoryLimitExceeded. got=%v", err)
+	}
+
+	if limits.Allocated() != 60 {
+		t.Errorf("failed reservation was recorded. got=%d", limits.Allocated())
+	}
+}
@@ -0,0 +1,128 @@
+package object
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Module is the value of an import expression, it holds the bindings of the exported let
+// statements of a script once the script finished running
+type Module struct {
+	Name    string // path written in the import expression
+	Path    string // file the module was loaded from
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return Module_Obj }
+func (m *Module) Inspect() string {
+	names := make([]string, 0, len(m.Exports))
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return "module " + m.Name + " {" + strings.Join(names, ", ") + "}"
+}
+
+// Modules configures how the scripts of an environment find the modules they import and
+// caches the loaded ones, every scope created on top of an environment shares its modules
+type Modules struct {
+	// SearchPath lists the directories where imports are looked up when they aren't found
+	// relative to the importing script
+	SearchPath []string
+
+	mu     sync.Mutex
+	loaded map[string]*moduleLoad
+}
+
+// moduleLoad is a module being loaded or loaded already, done is fired once it's loaded.
+// While the script of the module imports a module being loaded by another task, waiting is
+// that load, the waits of the loads in progress must not form a cycle
+type moduleLoad struct {
+	path    string
+	done    *event
+	loading bool
+	waiting *moduleLoad
+	module  *Module
+	err     *Error
+}
+
+// ImportCycleError is the cause of the error of an import that would wait for itself, Paths
+// starts and ends with the same module
+type ImportCycleError struct {
+	Paths []string
+}
+
+func (e *ImportCycleError) Error() string {
+	return "import cycle: " + strings.Join(e.Paths, " -> ")
+}
+
+func NewModules() *Modules {
+	return &Modules{loaded: map[string]*moduleLoad{}}
+}
+
+// Load returns the module of the file at path calling load to run it the first time the file
+// is imported, tasks importing a file being loaded wait for it until ctx is done. importer is
+// the module whose script imports path, empty for the scripts that aren't modules. Waiting
+// for a load that waits for importer is an import cycle. Failed loads aren't cached
+func (m *Modules) Load(ctx context.Context, path, importer string, load func() (*Module, *Error)) (*Module, *Error) {
+	m.mu.Lock()
+	if l, ok := m.loaded[path]; ok {
+		from := m.loaded[importer]
+		if from == nil || !from.loading {
+			from = nil
+		}
+		if from != nil && l.loading {
+			if cycle := l.cycle(from); cycle != nil {
+				m.mu.Unlock()
+				err := &ImportCycleError{Paths: cycle}
+				return nil, &Error{Message: err.Error(), Err: err}
+			}
+			from.waiting = l
+		}
+		m.mu.Unlock()
+
+		ok := l.done.wait(ctx)
+		if from != nil {
+			m.mu.Lock()
+			from.waiting = nil
+			m.mu.Unlock()
+		}
+		if !ok {
+			return nil, NewInterruptedError(context.Cause(ctx))
+		}
+		return l.module, l.err
+	}
+
+	l := &moduleLoad{path: path, done: newEvent(ctx), loading: true}
+	m.loaded[path] = l
+	m.mu.Unlock()
+
+	module, err := load()
+
+	m.mu.Lock()
+	l.module, l.err, l.loading = module, err, false
+	if err != nil {
+		delete(m.loaded, path)
+	}
+	m.mu.Unlock()
+	l.done.fire()
+
+	return module, err
+}
+
+// cycle returns the paths of the loads from from to itself through l and the loads it waits
+// for, or nil when l doesn't wait for from. It must be called with the lock held
+func (l *moduleLoad) cycle(from *moduleLoad) []string {
+	paths := []string{from.path}
+	for waited := l; waited != nil; waited = waited.waiting {
+		paths = append(paths, waited.path)
+		if waited == from {
+			return paths
+		}
+	}
+
+	return nil
+}
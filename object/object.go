@@ -1,13 +1,44 @@
 package object
 
-import "fmt"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"go-interpreter.com/m/ast"
+)
 
 type ObjectType string
 
 const (
-	Integer_Obj = "INTEGER"
-	Boolean_Obj = "BOOLEAN"
-	Null_Obj
+	Integer_Obj     = "INTEGER"
+	Boolean_Obj     = "BOOLEAN"
+	Null_Obj        = "NULL"
+	ReturnValue_Obj = "RETURN_VALUE"
+	Error_Obj       = "ERROR"
+	Function_Obj    = "FUNCTION"
+	String_Obj      = "STRING"
+	Array_Obj       = "ARRAY"
+	Hash_Obj        = "HASH"
+	Builtin_Obj     = "BUILTIN"
+	Task_Obj        = "TASK"
+	Channel_Obj     = "CHANNEL"
+	Quote_Obj       = "QUOTE"
+	Macro_Obj       = "MACRO"
+	Module_Obj      = "MODULE"
+
+	CompiledFunction_Obj = "COMPILED_FUNCTION"
+)
+
+// Canonical null and boolean objects, comparing objects by identity relies on every
+// null and boolean being one of them
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
 )
 
 type Object interface {
@@ -19,6 +50,30 @@ type Integer struct {
 	Value int
 }
 
+// Integers between MinCachedInteger and MaxCachedInteger are preallocated so that counters,
+// indexes and lengths don't allocate, it's safe because integer objects are never modified
+const (
+	MinCachedInteger = -128
+	MaxCachedInteger = 1023
+)
+
+var integerCache = func() []*Integer {
+	cache := make([]*Integer, MaxCachedInteger-MinCachedInteger+1)
+	for i := range cache {
+		cache[i] = &Integer{Value: i + MinCachedInteger}
+	}
+	return cache
+}()
+
+// NewInteger returns the integer object for value, shared when value is small
+func NewInteger(value int) *Integer {
+	if value >= MinCachedInteger && value <= MaxCachedInteger {
+		return integerCache[value-MinCachedInteger]
+	}
+
+	return &Integer{Value: value}
+}
+
 func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
@@ -43,3 +98,225 @@ type Null struct{}
 
 func (n *Null) Inspect() string  { return "null" }
 func (n *Null) Type() ObjectType { return Null_Obj }
+
+// ReturnValue wraps the value of a return statement while it bubbles up to the enclosing function
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+func (rv *ReturnValue) Type() ObjectType { return ReturnValue_Obj }
+
+// Kinds of runtime errors, scripts catching an error tell them apart by kind
+const (
+	ErrorKind           = "error"      // thrown by scripts, or without a more specific kind
+	TypeErrorKind       = "type"       // a value of the wrong type for an operator, a call or an index
+	NameErrorKind       = "name"       // an identifier that isn't bound
+	ArithmeticErrorKind = "arithmetic" // a division by zero
+	MatchErrorKind      = "match"      // a value without a matching arm or pattern
+)
+
+// Error is a runtime error, it also satisfies the error interface so host programs can
+// inspect it with errors.Is and errors.As. Line and Column locate the expression that
+// failed, they are 0 until the evaluator knows it
+type Error struct {
+	Message string
+	Kind    string // ErrorKind when empty
+	Line    int
+	Column  int
+	File    string // module the error was raised in, empty for the script being run
+	Err     error  // Go error that caused the runtime error, if any
+}
+
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Type() ObjectType { return Error_Obj }
+func (e *Error) Error() string    { return e.Message }
+func (e *Error) Unwrap() error    { return e.Err }
+
+// ErrorKind returns the kind of the error, ErrorKind for errors without a specific one
+func (e *Error) ErrorKind() string {
+	if e.Kind == "" {
+		return ErrorKind
+	}
+
+	return e.Kind
+}
+
+// Catchable reports whether scripts can catch the error, going over the step budget or the
+// memory limit or interrupting the evaluation stops it whatever the script does. Going over
+// the call depth only unwinds the calls, it can be caught like any other error
+func (e *Error) Catchable() bool {
+	for _, err := range []error{ErrStepBudgetExceeded, ErrMemoryLimitExceeded, ErrDeadlock, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(e, err) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Function struct {
+	Name       string
+	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // evaluated in the frame of each call, see ast.FunctionExpression
+	Variadic   bool
+	Body       *ast.BlockStatement
+	Env        *Environment
+	Locals     []string // slots of the frame of each call, nil when the body wasn't resolved
+}
+
+// Arity returns the number of arguments the function requires and the number it accepts,
+// -1 when it's variadic
+func (f *Function) Arity() (min, max int) {
+	min, max = len(f.Parameters), len(f.Parameters)
+	if f.Variadic {
+		min, max = min-1, -1
+	}
+	for min > 0 && min <= len(f.Defaults) && f.Defaults[min-1] != nil {
+		min--
+	}
+
+	return min, max
+}
+
+func (f *Function) Type() ObjectType { return Function_Obj }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for i, p := range f.Parameters {
+		param := p.String()
+		if f.Variadic && i == len(f.Parameters)-1 {
+			param = "..." + param
+		}
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+		params = append(params, param)
+	}
+
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// Quote is the unevaluated code returned by quote, macros build the code that replaces
+// their calls with quotes
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return Quote_Obj }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return Macro_Obj }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Inspect() string  { return s.Value }
+func (s *String) Type() ObjectType { return String_Obj }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return Array_Obj }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by the objects that can be used as hash keys
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return Hash_Obj }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,27 @@
+package object
+
+import "testing"
+
+func TestNewInteger(t *testing.T) {
+	testCases := []struct {
+		value  int
+		shared bool
+	}{
+		{0, true},
+		{MinCachedInteger, true},
+		{MaxCachedInteger, true},
+		{MinCachedInteger - 1, false},
+		{MaxCachedInteger + 1, false},
+	}
+
+	for _, tc := range testCases {
+		integer := NewInteger(tc.value)
+		if integer.Value != tc.value {
+			t.Errorf("integer has wrong value. got=%d, want=%d", integer.Value, tc.value)
+		}
+
+		if shared := integer == NewInteger(tc.value); shared != tc.shared {
+			t.Errorf("integer %d shared=%t, want=%t", tc.value, shared, tc.shared)
+		}
+	}
+}
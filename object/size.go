@@ -0,0 +1,30 @@
+package object
+
+// Approximate sizes in bytes used to account the memory allocated by scripts
+const (
+	objectSize    = 16 // an Object interface value
+	hashEntrySize = 2*objectSize + 16
+)
+
+// SizeOf estimates the bytes allocated to build obj without counting the objects it refers to,
+// since those are accounted when they are created. Integers, booleans and null have a fixed
+// size and are not accounted
+func SizeOf(obj Object) int {
+	switch obj := obj.(type) {
+	case *String:
+		return len(obj.Value)
+	case *Array:
+		return len(obj.Elements) * objectSize
+	case *Hash:
+		return len(obj.Pairs) * hashEntrySize
+	}
+
+	return 0
+}
+
+// ArraySize is the size SizeOf reports for an array of n elements, it allows checking
+// the limits before allocating the array
+func ArraySize(n int) int { return n * objectSize }
+
+// HashSize is the size SizeOf reports for a hash of n pairs
+func HashSize(n int) int { return n * hashEntrySize }
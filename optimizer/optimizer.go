@@ -0,0 +1,365 @@
+// Package optimizer rewrites the AST before it's evaluated or compiled, folding the
+// expressions whose result is known from the source
+package optimizer
+
+import (
+	"strconv"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/token"
+)
+
+// Optimize folds prefix and infix expressions over literals, drops the identities x * 1,
+// x + 0 and x - 0 when x can only be an integer and prunes the branches of if expressions
+// with a literal condition. Expressions that fail at runtime, like 1 / 0 or
+// 5 + true, are left as they are so the error is still reported when they are evaluated.
+// The program is rewritten in place and returned
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = optimizeStatements(program.Statements)
+	return program
+}
+
+func optimizeStatements(statements []ast.Statement) []ast.Statement {
+	optimized := make([]ast.Statement, 0, len(statements))
+
+	for i, s := range statements {
+		s = optimizeStatement(s)
+
+		// A pruned if whose branch is run unconditionally is replaced by the statements of the
+		// branch, they already share the scope of the enclosing block
+		if branch, ok := prunedBranch(s); ok {
+			isLast := i == len(statements)-1
+			if !isLast || len(branch) > 0 {
+				optimized = append(optimized, branch...)
+				continue
+			}
+		}
+
+		optimized = append(optimized, s)
+	}
+
+	return optimized
+}
+
+func optimizeStatement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		s.Value = optimizeExpression(s.Value)
+	case *ast.ReturnStatement:
+		s.Value = optimizeExpression(s.Value)
+	case *ast.ThrowStatement:
+		s.Value = optimizeExpression(s.Value)
+	case *ast.ExpressionStatement:
+		s.Expression = optimizeExpression(s.Expression)
+	case *ast.BlockStatement:
+		optimizeBlock(s)
+	}
+
+	return s
+}
+
+func optimizeBlock(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = optimizeStatements(block.Statements)
+	}
+}
+
+func optimizeExpression(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		e.Right = optimizeExpression(e.Right)
+		if folded := foldPrefix(e); folded != nil {
+			return folded
+		}
+	case *ast.InfixExpression:
+		e.Left = optimizeExpression(e.Left)
+		e.Right = optimizeExpression(e.Right)
+		if folded := foldInfix(e); folded != nil {
+			return folded
+		}
+		if simplified := simplifyIdentity(e); simplified != nil {
+			return simplified
+		}
+	case *ast.IfExpression:
+		return optimizeIf(e)
+	case *ast.TryExpression:
+		optimizeBlock(e.Block)
+		optimizeBlock(e.CatchBlock)
+		optimizeBlock(e.Finally)
+	case *ast.FunctionExpression:
+		for i, value := range e.Defaults {
+			e.Defaults[i] = optimizeExpression(value)
+		}
+		optimizeBlock(e.Body)
+	case *ast.CallExpression:
+		if ast.IsCallTo(e, "quote") {
+			return e
+		}
+		e.Function = optimizeExpression(e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = optimizeExpression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = optimizeExpression(el)
+		}
+	case *ast.HashLiteral:
+		for i := range e.Keys {
+			e.Keys[i] = optimizeExpression(e.Keys[i])
+			e.Values[i] = optimizeExpression(e.Values[i])
+		}
+	case *ast.IndexExpression:
+		e.Left = optimizeExpression(e.Left)
+		e.Index = optimizeExpression(e.Index)
+	case *ast.SpreadExpression:
+		e.Value = optimizeExpression(e.Value)
+	case *ast.NamedArgument:
+		e.Value = optimizeExpression(e.Value)
+	case *ast.SpawnExpression:
+		e.Function = optimizeExpression(e.Function)
+	case *ast.MatchExpression:
+		e.Value = optimizeExpression(e.Value)
+		for _, arm := range e.Arms {
+			arm.Guard = optimizeExpression(arm.Guard)
+			arm.Body = optimizeExpression(arm.Body)
+		}
+	}
+
+	return e
+}
+
+// optimizeIf keeps only the branch that runs when the condition is a literal. The branch
+// stays wrapped in an if with a true condition unless it's a single expression, since it may
+// hold let and return statements
+func optimizeIf(ie *ast.IfExpression) ast.Expression {
+	ie.Condition = optimizeExpression(ie.Condition)
+	optimizeBlock(ie.Consequence)
+	optimizeBlock(ie.Alternative)
+
+	truthy, ok := literalTruthiness(ie.Condition)
+	if !ok {
+		return ie
+	}
+
+	branch := ie.Consequence
+	if !truthy {
+		if ie.Alternative == nil {
+			return ie
+		}
+		branch = ie.Alternative
+	}
+
+	if len(branch.Statements) == 1 {
+		if s, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return s.Expression
+		}
+	}
+
+	return &ast.IfExpression{
+		Token:       ie.Token,
+		Condition:   newBoolean(ie.Condition, true),
+		Consequence: branch,
+	}
+}
+
+// prunedBranch returns the statements of an if statement that always runs its consequence
+func prunedBranch(s ast.Statement) ([]ast.Statement, bool) {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok || ie.Alternative != nil {
+		return nil, false
+	}
+
+	if truthy, ok := literalTruthiness(ie.Condition); !ok || !truthy {
+		return nil, false
+	}
+
+	return ie.Consequence.Statements, true
+}
+
+// literalTruthiness follows the truthiness rules of the evaluator for literals
+func literalTruthiness(e ast.Expression) (bool, bool) {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return e.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	}
+
+	return false, false
+}
+
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	switch pe.Operator {
+	case "!":
+		switch right := pe.Right.(type) {
+		case *ast.Boolean:
+			return newBoolean(pe, !right.Value)
+		case *ast.IntegerLiteral, *ast.StringLiteral:
+			return newBoolean(pe, false)
+		}
+	case "-":
+		if right, ok := pe.Right.(*ast.IntegerLiteral); ok {
+			return newInteger(pe, -right.Value)
+		}
+	}
+
+	return nil
+}
+
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	switch left := ie.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := ie.Right.(*ast.IntegerLiteral); ok {
+			return foldIntegerInfix(ie, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := ie.Right.(*ast.StringLiteral); ok {
+			return foldStringInfix(ie, left.Value, right.Value)
+		}
+	case *ast.Boolean:
+		if right, ok := ie.Right.(*ast.Boolean); ok {
+			switch ie.Operator {
+			case "==":
+				return newBoolean(ie, left.Value == right.Value)
+			case "!=":
+				return newBoolean(ie, left.Value != right.Value)
+			}
+		}
+	}
+
+	return nil
+}
+
+func foldIntegerInfix(ie *ast.InfixExpression, left, right int) ast.Expression {
+	switch ie.Operator {
+	case "+":
+		return newInteger(ie, left+right)
+	case "-":
+		return newInteger(ie, left-right)
+	case "*":
+		return newInteger(ie, left*right)
+	case "/":
+		// division by zero is a runtime error
+		if right != 0 {
+			return newInteger(ie, left/right)
+		}
+	case "<":
+		return newBoolean(ie, left < right)
+	case ">":
+		return newBoolean(ie, left > right)
+	case "==":
+		return newBoolean(ie, left == right)
+	case "!=":
+		return newBoolean(ie, left != right)
+	}
+
+	return nil
+}
+
+func foldStringInfix(ie *ast.InfixExpression, left, right string) ast.Expression {
+	switch ie.Operator {
+	case "+":
+		value := left + right
+		return &ast.StringLiteral{Token: literalToken(ie, token.STRING, value), Value: value}
+	case "==":
+		return newBoolean(ie, left == right)
+	case "!=":
+		return newBoolean(ie, left != right)
+	}
+
+	return nil
+}
+
+// simplifyIdentity returns the operand of a multiplication by one or an addition or
+// subtraction of zero. The operand must be an integer expression, "a" + 0 is a type error
+func simplifyIdentity(ie *ast.InfixExpression) ast.Expression {
+	switch ie.Operator {
+	case "*":
+		if isIntegerLiteral(ie.Right, 1) && isIntegerExpression(ie.Left) {
+			return ie.Left
+		}
+		if isIntegerLiteral(ie.Left, 1) && isIntegerExpression(ie.Right) {
+			return ie.Right
+		}
+	case "+":
+		if isIntegerLiteral(ie.Right, 0) && isIntegerExpression(ie.Left) {
+			return ie.Left
+		}
+		if isIntegerLiteral(ie.Left, 0) && isIntegerExpression(ie.Right) {
+			return ie.Right
+		}
+	case "-":
+		if isIntegerLiteral(ie.Right, 0) && isIntegerExpression(ie.Left) {
+			return ie.Left
+		}
+	}
+
+	return nil
+}
+
+func isIntegerLiteral(e ast.Expression, value int) bool {
+	literal, ok := e.(*ast.IntegerLiteral)
+	return ok && literal.Value == value
+}
+
+// isIntegerExpression reports whether e evaluates to an integer unless it fails, since
+// -, * and / are only defined on integers and + of an integer needs another one
+func isIntegerExpression(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "-"
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "-", "*", "/":
+			return true
+		case "+":
+			return isIntegerExpression(e.Left) || isIntegerExpression(e.Right)
+		}
+	}
+
+	return false
+}
+
+func newInteger(original ast.Expression, value int) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: literalToken(original, token.INT, strconv.Itoa(value)), Value: value}
+}
+
+func newBoolean(original ast.Expression, value bool) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: literalToken(original, token.True, "true"), Value: true}
+	}
+
+	return &ast.Boolean{Token: literalToken(original, token.False, "false"), Value: false}
+}
+
+// literalToken is the token of a folded literal, positioned where the folded expression starts
+func literalToken(original ast.Expression, tokenType token.TokenType, literal string) token.Token {
+	line, column := position(original)
+	return token.Token{Type: tokenType, Literal: literal, Line: line, Column: column}
+}
+
+func position(e ast.Expression) (int, int) {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return position(e.Left)
+	case *ast.PrefixExpression:
+		return e.Token.Line, e.Token.Column
+	case *ast.IntegerLiteral:
+		return e.Token.Line, e.Token.Column
+	case *ast.StringLiteral:
+		return e.Token.Line, e.Token.Column
+	case *ast.Boolean:
+		return e.Token.Line, e.Token.Column
+	case *ast.IfExpression:
+		return e.Token.Line, e.Token.Column
+	}
+
+	return 0, 0
+}
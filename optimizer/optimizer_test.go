@@ -0,0 +1,163 @@
+package optimizer
+
+import (
+	"testing"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/evaluator"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/parser"
+)
+
+func TestConstantFolding(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(10 - 4) / 3", "2"},
+		{"-5 + 2", "-3"},
+		{"-(2 * 3)", "-6"},
+		{"!true", "false"},
+		{"!!5", "true"},
+		{`!"a"`, "false"},
+		{"1 < 2 == true", "true"},
+		{"3 > 4 != false", "false"},
+		{`"a" + "b" + "c"`, "abc"},
+		{`"a" == "a"`, "true"},
+		{"x + 1 * 2", "(x + 2)"},
+		{"f(1 + 1, [2 * 2], {1 + 1: 3 - 3})[0 + 0]", "(f(2, [4], {2:0})[0])"},
+		{"let a = fn(x) { return x * (2 + 2); };", "let a = fn(x){\nreturn (x * 4);\n};"},
+	}
+
+	for _, tc := range testCases {
+		optimized := Optimize(parse(t, tc.input))
+		if optimized.String() != tc.expected {
+			t.Errorf("wrong optimization of %q. want=%q, got=%q", tc.input, tc.expected, optimized.String())
+		}
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"(x - y) * 1", "(x - y)"},
+		{"1 * -x", "(-x)"},
+		{"(x * y) + 0", "(x * y)"},
+		{"0 + (x + 1)", "(x + 1)"},
+		{"(x / 2) - 0", "(x / 2)"},
+		{"fn(x) { (x - 1) * (2 - 1) }", "fn(x){\n(x - 1)\n}"},
+		// x may be a string or an array, where the identities are type errors
+		{"x * 1", "(x * 1)"},
+		{"x + 0", "(x + 0)"},
+		{"f() - 0", "(f() - 0)"},
+		{"0 - (x - y)", "(0 - (x - y))"},
+	}
+
+	for _, tc := range testCases {
+		optimized := Optimize(parse(t, tc.input))
+		if optimized.String() != tc.expected {
+			t.Errorf("wrong optimization of %q. want=%q, got=%q", tc.input, tc.expected, optimized.String())
+		}
+	}
+}
+
+func TestRuntimeErrorsAreNotFolded(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "(1 / 0)"},
+		{"(2 + 2) / (1 - 1)", "(4 / 0)"},
+		{"5 + true", "(5 + true)"},
+		{"-true", "(-true)"},
+		{"true + false", "(true + false)"},
+		{`"a" - "b"`, `(a - b)`},
+		{`("a" - "b") * 1`, `(a - b)`},
+		{`0 + -"a"`, `(-a)`},
+	}
+
+	for _, tc := range testCases {
+		optimized := Optimize(parse(t, tc.input))
+		if optimized.String() != tc.expected {
+			t.Errorf("wrong optimization of %q. want=%q, got=%q", tc.input, tc.expected, optimized.String())
+		}
+
+		evaluated := evaluator.Eval(optimized, object.NewEnvironment())
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("optimized %q doesn't fail at runtime. got=%T (%+v)", tc.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestIfPruning(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (1 > 2) { 1 } else { 2 }", "2"},
+		{`if ("yes") { 1 }`, "1"},
+		{"if (x) { 1 } else { 2 }", "ifx 1else 2"},
+		{"if (false) { 1 }", "iffalse 1"},
+		{"let a = if (2 > 1) { let b = 1; b } else { 2 };", "let a = iftrue let b = 1;b;"},
+		{"if (true) { let b = 1; } b", "let b = 1;b"},
+		{"fn() { if (true) { let b = 2; return b; } 3 }", "fn(){\nlet b = 2;\nreturn b;\n3\n}"},
+		{"if (true) { }", "iftrue "},
+	}
+
+	for _, tc := range testCases {
+		optimized := Optimize(parse(t, tc.input))
+		if optimized.String() != tc.expected {
+			t.Errorf("wrong optimization of %q. want=%q, got=%q", tc.input, tc.expected, optimized.String())
+		}
+	}
+}
+
+func TestOptimizedResults(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(x) { if (1 < 2) { let y = x * (3 + 4); } y }; f(2)", "14"},
+		{"let f = fn() { 5; if (false) { 1 } }; f()", "null"},
+		{"if (true) { return 2 * 3; } 10", "6"},
+		{`let greet = fn(name) { "hello " + name }; greet("wor" + "ld")`, "hello world"},
+		{"[1 + 1, 10 / 0]", "ERROR: division by zero: 10 / 0"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evaluator.Eval(Optimize(parse(t, tc.input)), object.NewEnvironment())
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("wrong result for optimized %q. want=%s, got=%s", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFoldedTokenPositions(t *testing.T) {
+	program := Optimize(parse(t, "let a = 1;\nlet b =  2 * 3;"))
+
+	folded, ok := program.Statements[1].(*ast.LetStatement).Value.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expression not folded. got=%T", program.Statements[1].(*ast.LetStatement).Value)
+	}
+
+	if folded.Token.Line != 2 || folded.Token.Column != 10 {
+		t.Errorf("folded literal has wrong position. got=%d:%d", folded.Token.Line, folded.Token.Column)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors)
+	}
+
+	return program
+}
@@ -13,12 +13,14 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	PIPE
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
 var precendences = map[token.TokenType]int{
@@ -31,6 +33,8 @@ var precendences = map[token.TokenType]int{
 	token.Plus:       SUM,
 	token.Minus:      SUM,
 	token.LParen:     CALL,
+	token.LBracket:   INDEX,
+	token.Pipe:       PIPE,
 }
 
 type prefixParseFn func() ast.Expression
@@ -44,6 +48,10 @@ type Parser struct {
 
 	Errors []error
 
+	// Warnings are positioned reports about code that is valid but most likely wrong, like
+	// unreachable match arms
+	Warnings []error
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -64,16 +72,26 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LParen, p.parseGroupedExpression)
 	p.registerPrefix(token.IfConditional, p.parseIfExpression)
 	p.registerPrefix(token.Function, p.parseFunction)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.LBracket, p.parseArrayLiteral)
+	p.registerPrefix(token.LBrace, p.parseHashLiteral)
+	p.registerPrefix(token.Spawn, p.parseSpawnExpression)
+	p.registerPrefix(token.Macro, p.parseMacroLiteral)
+	p.registerPrefix(token.Match, p.parseMatchExpression)
+	p.registerPrefix(token.Import, p.parseImportExpression)
+	p.registerPrefix(token.Try, p.parseTryExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	for tokenType := range precendences {
-		if tokenType == token.LParen {
+		if tokenType == token.LParen || tokenType == token.LBracket || tokenType == token.Pipe {
 			continue
 		}
 
 		p.registerInfix(tokenType, p.parseInfixExpression)
 	}
 	p.registerInfix(token.LParen, p.parseCallExpressionArguments)
+	p.registerInfix(token.LBracket, p.parseIndexExpression)
+	p.registerInfix(token.Pipe, p.parsePipeExpression)
 
 	return p
 }
@@ -99,6 +117,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.ReturnStatement:
 		return p.parseReturnStatement()
+	case token.Export:
+		return p.parseExportStatement()
+	case token.Throw:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -139,14 +161,61 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stm := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token.Ident) {
-		return nil
+
+	if p.peekIsToken(token.LBracket) || p.peekIsToken(token.LBrace) {
+		p.nextToken()
+		if stm.Pattern = p.parsePattern(); stm.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.Ident) {
+			return nil
+		}
+		stm.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if p.peekIsToken(token.Colon) {
+		p.nextToken()
+		p.nextToken()
+		if stm.Type = p.parseType(); stm.Type == nil {
+			return nil
+		}
 	}
 
-	stm.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.Assign) {
 		return nil
 	}
+	p.nextToken()
+	stm.Value = p.parseExpression(LOWEST)
+	if fn, ok := stm.Value.(*ast.FunctionExpression); ok && stm.Name != nil {
+		fn.Name = stm.Name.Value
+	}
+
+	if p.peekIsToken(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stm
+}
+
+// parseExportStatement parses `export let ...`, the let statement is marked as exported
+func (p *Parser) parseExportStatement() ast.Statement {
+	if !p.expectPeek(token.Let) {
+		return nil
+	}
+
+	stm := p.parseLetStatement()
+	if stm == nil {
+		return nil
+	}
+	stm.Exported = true
+
+	return stm
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stm := &ast.ThrowStatement{Token: p.curToken}
+
 	p.nextToken()
 	stm.Value = p.parseExpression(LOWEST)
 
@@ -206,41 +275,251 @@ func (p *Parser) parseFunction() ast.Expression {
 		return nil
 	}
 
-	fnExpression.Parameters = p.parseFunctionParameters()
+	params := p.parseFunctionParameters()
+	if params == nil {
+		return nil
+	}
+	fnExpression.Parameters, fnExpression.ParameterTypes = params.names, params.types
+	fnExpression.Defaults, fnExpression.Variadic = params.defaults, params.variadic
+
+	if p.peekIsToken(token.ReturnArrow) {
+		p.nextToken()
+		p.nextToken()
+		if fnExpression.ReturnType = p.parseType(); fnExpression.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LBrace) {
 		return nil
 	}
 
 	fnExpression.Body = p.parseBlockStatement()
+	fnExpression.Body.Statements = append(params.patterns, fnExpression.Body.Statements...)
 	return fnExpression
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	tokens := make([]*ast.Identifier, 0)
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LParen) {
+		return nil
+	}
+
+	params := p.parseFunctionParameters()
+	switch {
+	case params == nil:
+		return nil
+	case len(params.patterns) != 0:
+		p.Errors = append(p.Errors, fmt.Errorf("macro parameters can't be destructured"))
+		return nil
+	case params.types != nil:
+		p.Errors = append(p.Errors, fmt.Errorf("macro parameters can't be annotated"))
+		return nil
+	case params.defaults != nil || params.variadic:
+		p.Errors = append(p.Errors, fmt.Errorf("macro parameters can't have default values or be variadic"))
+		return nil
+	}
+	macro.Parameters = params.names
+
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+
+	macro.Body = p.parseBlockStatement()
+	return macro
+}
+
+// parameterList is the parameter list of a function or macro literal
+type parameterList struct {
+	names    []*ast.Identifier
+	types    []ast.TypeAnnotation // nil when no parameter is annotated
+	defaults []ast.Expression     // nil when no parameter has a default value
+	variadic bool
+
+	// patterns destructure the parameters written as array or hash patterns, those parameters
+	// are bound to names that can't be written in the source so the statements can read them
+	patterns []ast.Statement
+}
+
+// parseFunctionParameters parses the parameters up to the closing parenthesis, each one is
+// a name or a pattern, optionally annotated and followed by `= default`. The last one can be
+// written `...name` to collect the remaining arguments. It returns nil on syntax errors
+func (p *Parser) parseFunctionParameters() *parameterList {
+	params := &parameterList{names: []*ast.Identifier{}, patterns: []ast.Statement{}}
 
 	if p.peekIsToken(token.RParent) {
 		p.nextToken()
-		return tokens
+		return params
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	tokens = append(tokens, ident)
+		if p.curIsToken(token.Ellipsis) {
+			if !p.expectPeek(token.Ident) {
+				return nil
+			}
+			params.variadic = true
+		}
 
-	for p.peekIsToken(token.Comma) {
-		p.nextToken()
+		switch p.curToken.Type {
+		case token.LBracket, token.LBrace:
+			tok := p.curToken
+			pattern := p.parsePattern()
+			if pattern == nil {
+				return nil
+			}
+
+			param := &ast.Identifier{Token: tok, Value: fmt.Sprintf("@%d", len(params.names))}
+			params.names = append(params.names, param)
+			params.patterns = append(params.patterns, &ast.LetStatement{
+				Token:   token.Token{Type: token.Let, Literal: "let", Line: tok.Line, Column: tok.Column},
+				Pattern: pattern,
+				Value:   &ast.Identifier{Token: tok, Value: param.Value},
+			})
+		default:
+			params.names = append(params.names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		}
+		name := params.names[len(params.names)-1]
+
+		if p.peekIsToken(token.Colon) {
+			p.nextToken()
+			p.nextToken()
+			annotation := p.parseType()
+			if annotation == nil {
+				return nil
+			}
+
+			if params.types == nil {
+				params.types = make([]ast.TypeAnnotation, len(params.names)-1, len(params.names))
+			}
+			params.types = append(params.types, annotation)
+		} else if params.types != nil {
+			params.types = append(params.types, nil)
+		}
+
+		if p.peekIsToken(token.Assign) {
+			if params.variadic {
+				p.errorf(name.Token, "rest parameter %s can't have a default value", name.Value)
+				return p.skipParameters(params)
+			}
+
+			p.nextToken()
+			p.nextToken()
+			value := p.parseExpression(LOWEST)
+			if value == nil {
+				return nil
+			}
+
+			if params.defaults == nil {
+				params.defaults = make([]ast.Expression, len(params.names)-1, len(params.names))
+			}
+			params.defaults = append(params.defaults, value)
+		} else if params.defaults != nil {
+			params.defaults = append(params.defaults, nil)
+			if !params.variadic {
+				p.errorf(name.Token, "parameter %s without default value follows parameters with one", name.Value)
+				return p.skipParameters(params)
+			}
+		}
+
+		if !p.peekIsToken(token.Comma) {
+			break
+		}
+		if params.variadic {
+			p.errorf(name.Token, "rest parameter %s must be the last one", name.Value)
+			return p.skipParameters(params)
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		tokens = append(tokens, ident)
 	}
 
 	if !p.expectPeek(token.RParent) {
 		return nil
 	}
 
-	return tokens
+	return params
+}
+
+// skipParameters moves past the closing paren of a parameter list that already reported
+// an error, so the function body is still parsed
+func (p *Parser) skipParameters(params *parameterList) *parameterList {
+	for depth := 0; ; {
+		p.nextToken()
+		switch p.curToken.Type {
+		case token.EOF:
+			return nil
+		case token.LParen:
+			depth++
+		case token.RParent:
+			if depth == 0 {
+				return params
+			}
+			depth--
+		}
+	}
+}
+
+// parseType parses the annotation starting at the current token: a name, `[element]`,
+// `{key: value}` or `fn(parameters) -> result`
+func (p *Parser) parseType() ast.TypeAnnotation {
+	switch p.curToken.Type {
+	case token.Ident:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBracket:
+		array := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if array.Element = p.parseType(); array.Element == nil || !p.expectPeek(token.RBracket) {
+			return nil
+		}
+		return array
+	case token.LBrace:
+		hash := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if hash.Key = p.parseType(); hash.Key == nil || !p.expectPeek(token.Colon) {
+			return nil
+		}
+		p.nextToken()
+		if hash.Value = p.parseType(); hash.Value == nil || !p.expectPeek(token.RBrace) {
+			return nil
+		}
+		return hash
+	case token.Function:
+		return p.parseFunctionType()
+	}
+
+	p.Errors = append(p.Errors, fmt.Errorf("expected a type, got %s instead", p.curToken.Type))
+	return nil
+}
+
+func (p *Parser) parseFunctionType() ast.TypeAnnotation {
+	fn := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeAnnotation{}}
+	if !p.expectPeek(token.LParen) {
+		return nil
+	}
+
+	for !p.peekIsToken(token.RParent) {
+		p.nextToken()
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		fn.Parameters = append(fn.Parameters, param)
+
+		if !p.peekIsToken(token.RParent) && !p.expectPeek(token.Comma) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	if p.peekIsToken(token.ReturnArrow) {
+		p.nextToken()
+		p.nextToken()
+		if fn.Result = p.parseType(); fn.Result == nil {
+			return nil
+		}
+	}
+
+	return fn
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
@@ -276,6 +555,41 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return ifExp
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	try := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	try.Block = p.parseBlockStatement()
+
+	if p.peekIsToken(token.Catch) {
+		p.nextToken()
+		if !p.expectPeek(token.LParen) || !p.expectPeek(token.Ident) {
+			return nil
+		}
+		try.Catch = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RParent) || !p.expectPeek(token.LBrace) {
+			return nil
+		}
+		try.CatchBlock = p.parseBlockStatement()
+	}
+
+	if p.peekIsToken(token.Finally) {
+		p.nextToken()
+		if !p.expectPeek(token.LBrace) {
+			return nil
+		}
+		try.Finally = p.parseBlockStatement()
+	}
+
+	if try.CatchBlock == nil && try.Finally == nil {
+		p.Errors = append(p.Errors, fmt.Errorf("try without catch or finally"))
+		return nil
+	}
+
+	return try
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -299,20 +613,51 @@ func (p *Parser) parseCallExpressionArguments(left ast.Expression) ast.Expressio
 	return exp
 }
 
+// parseCallArguments parses the arguments up to the closing parenthesis, an argument written
+// `...array` passes the elements of the array as separate arguments and one written
+// `name: value` is passed by name. Arguments passed by name go after the rest
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
+	named := map[string]bool{}
 
 	if p.peekIsToken(token.RParent) {
 		p.nextToken()
 		return args
 	}
 
-	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
-	for p.peekIsToken(token.Comma) {
+	for {
 		p.nextToken()
+		// misplaced arguments are still parsed, so the errors after them are the real ones
+		isNamed := p.curIsToken(token.Ident) && p.peekIsToken(token.Colon)
+		if !isNamed && len(named) != 0 {
+			p.errorf(p.curToken, "positional argument after named arguments")
+		}
+
+		switch {
+		case isNamed:
+			arg := &ast.NamedArgument{Token: p.curToken, Name: p.curToken.Literal}
+			if named[arg.Name] {
+				p.errorf(arg.Token, "duplicate named argument %s", arg.Name)
+			}
+			named[arg.Name] = true
+
+			p.nextToken()
+			p.nextToken()
+			arg.Value = p.parseExpression(LOWEST)
+			args = append(args, arg)
+		case p.curIsToken(token.Ellipsis):
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			args = append(args, spread)
+		default:
+			args = append(args, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekIsToken(token.Comma) {
+			break
+		}
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
 	}
 
 	if !p.expectPeek(token.RParent) {
@@ -322,6 +667,81 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
+// parseExpressionList parses comma separated expressions until the end token
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekIsToken(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+	for p.peekIsToken(token.Comma) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBracket)
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBracket) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	for !p.peekIsToken(token.RBrace) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.Colon) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Keys = append(hash.Keys, key)
+		hash.Values = append(hash.Values, value)
+
+		if !p.peekIsToken(token.RBrace) && !p.expectPeek(token.Comma) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBrace) {
+		return nil
+	}
+
+	return hash
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	prefixExpression := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
 	p.nextToken()
@@ -330,6 +750,256 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return prefixExpression
 }
 
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	spawn := &ast.SpawnExpression{Token: p.curToken}
+	p.nextToken()
+	spawn.Function = p.parseExpression(PREFIX)
+
+	return spawn
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	imp := &ast.ImportExpression{Token: p.curToken}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	imp.Path = p.curToken.Literal
+
+	return imp
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	match := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LParen) {
+		return nil
+	}
+
+	p.nextToken()
+	match.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RParent) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+
+	for !p.peekIsToken(token.RBrace) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		match.Arms = append(match.Arms, arm)
+
+		if !p.peekIsToken(token.RBrace) && !p.expectPeek(token.Comma) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBrace) {
+		return nil
+	}
+
+	if len(match.Arms) == 0 {
+		p.Errors = append(p.Errors, fmt.Errorf("match expression without arms"))
+		return nil
+	}
+
+	p.checkUnreachableArms(match)
+	return match
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken, Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekIsToken(token.IfConditional) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.Arrow) {
+		return nil
+	}
+
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	return arm
+}
+
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.Ident:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.INT, token.STRING, token.True, token.False:
+		value := p.prefixParseFns[p.curToken.Type]()
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Token: p.curToken, Value: value}
+	case token.Minus:
+		tok := p.curToken
+		if !p.peekIsToken(token.INT) {
+			p.peekErrors(token.INT)
+			return nil
+		}
+		return &ast.LiteralPattern{Token: tok, Value: p.parsePrefixExpression()}
+	case token.LBracket:
+		return p.parseArrayPattern()
+	case token.LBrace:
+		return p.parseHashPattern()
+	}
+
+	p.Errors = append(p.Errors, fmt.Errorf("expected a pattern, got %s instead", p.curToken.Type))
+	return nil
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekIsToken(token.RBracket) {
+		p.nextToken()
+
+		// the rest of the elements must be the last element of the pattern
+		if p.curIsToken(token.Ellipsis) {
+			if !p.expectPeek(token.Ident) {
+				return nil
+			}
+			pattern.Rest = p.parsePattern()
+
+			if !p.expectPeek(token.RBracket) {
+				return nil
+			}
+			return pattern
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekIsToken(token.RBracket) && !p.expectPeek(token.Comma) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	return pattern
+}
+
+// parseHashPattern parses `{"key": pattern}` pairs, a name alone like `{name}` is short for
+// `{"name": name}`
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekIsToken(token.RBrace) {
+		p.nextToken()
+
+		switch p.curToken.Type {
+		case token.Ident:
+			if p.peekIsToken(token.Colon) {
+				p.Errors = append(p.Errors, fmt.Errorf("hash pattern keys must be literals, got %s, quote it to match the key", p.curToken.Literal))
+				return nil
+			}
+
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			key := p.curToken
+			key.Type = token.STRING
+
+			pattern.Keys = append(pattern.Keys, &ast.StringLiteral{Token: key, Value: name.Value})
+			pattern.Values = append(pattern.Values, name)
+		case token.STRING, token.INT, token.True, token.False:
+			key := p.prefixParseFns[p.curToken.Type]()
+			if key == nil || !p.expectPeek(token.Colon) {
+				return nil
+			}
+
+			p.nextToken()
+			value := p.parsePattern()
+			if value == nil {
+				return nil
+			}
+
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, value)
+		default:
+			p.Errors = append(p.Errors, fmt.Errorf("hash pattern keys must be literals, got %s", p.curToken.Type))
+			return nil
+		}
+
+		if !p.peekIsToken(token.RBrace) && !p.expectPeek(token.Comma) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	return pattern
+}
+
+// checkUnreachableArms warns about the arms that follow an arm without guard matching every
+// value, or an arm without guard matching the same literal
+func (p *Parser) checkUnreachableArms(match *ast.MatchExpression) {
+	var catchAll *ast.MatchArm
+	literals := map[string]bool{}
+
+	for _, arm := range match.Arms {
+		literal, isLiteral := arm.Pattern.(*ast.LiteralPattern)
+		key := ""
+		if isLiteral {
+			key = fmt.Sprintf("%T %s", literal.Value, literal.Value.String())
+		}
+
+		switch {
+		case catchAll != nil:
+			p.warnf(arm.Token, "unreachable match arm, the arm at %d:%d matches every value", catchAll.Token.Line, catchAll.Token.Column)
+			continue
+		case isLiteral && literals[key]:
+			p.warnf(arm.Token, "unreachable match arm, an earlier arm matches %s", literal.String())
+			continue
+		}
+
+		if arm.Guard != nil {
+			continue
+		}
+
+		switch arm.Pattern.(type) {
+		case *ast.Identifier, *ast.WildcardPattern:
+			catchAll = arm
+		case *ast.LiteralPattern:
+			literals[key] = true
+		}
+	}
+}
+
+// parsePipeExpression rewrites `x |> f(a)` to the call `f(x, a)`, and `x |> f` to `f(x)`.
+// Pipes don't remain in the tree, so every pass and backend handles them as calls
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
+		return nil
+	}
+
+	call, ok := right.(*ast.CallExpression)
+	if !ok {
+		return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{left}}
+	}
+
+	piped := *call
+	piped.Arguments = append([]ast.Expression{left}, call.Arguments...)
+	return &piped
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	infixExpression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -399,6 +1069,16 @@ func (p *Parser) Error() string {
 	return b.String()
 }
 
+func (p *Parser) errorf(tok token.Token, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	p.Errors = append(p.Errors, fmt.Errorf("%d:%d: %s", tok.Line, tok.Column, msg))
+}
+
+func (p *Parser) warnf(tok token.Token, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	p.Warnings = append(p.Warnings, fmt.Errorf("%d:%d: %s", tok.Line, tok.Column, msg))
+}
+
 func (p *Parser) peekErrors(expectedToken token.TokenType) {
 	msg := fmt.Errorf("expected next token to be %s, got %s instead",
 		expectedToken, p.peekToken.Type)
@@ -406,6 +1086,11 @@ func (p *Parser) peekErrors(expectedToken token.TokenType) {
 }
 
 func (p *Parser) prefixErrors(t token.Token) {
+	if t.Type == token.Illegal && strings.HasPrefix(t.Literal, `"`) {
+		p.Errors = append(p.Errors, fmt.Errorf("%d:%d: unterminated string", t.Line, t.Column))
+		return
+	}
+
 	msg := fmt.Errorf("no prefix parse function for %s found", t.Type)
 	p.Errors = append(p.Errors, msg)
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
 	"go-interpreter.com/m/ast"
@@ -401,6 +402,102 @@ func TestFunctionParametersParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(a, b = 10) { a + b }`, "fn(a,b = 10){\n(a + b)\n}"},
+		{`fn(a, b: int = 1 + 2, ...rest: [int]) { rest }`, "fn(a,b: int = (1 + 2),...rest: [int]){\nrest\n}"},
+		{`fn(...args) { args }`, "fn(...args){\nargs\n}"},
+		{`f(...arr)`, "f(...arr)"},
+		{`f(1, ...xs, ...[2, 3])`, "f(1, ...xs, ...[2, 3])"},
+		{`f(x, timeout: 30, retries: n + 1)`, "f(x, timeout: 30, retries: (n + 1))"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("expected=%q, got=%q", tc.expected, program.String())
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...rest, a) { 1 }", "1:7: rest parameter rest must be the last one"},
+		{"fn(...rest = []) { 1 }", "1:7: rest parameter rest can't have a default value"},
+		{"fn(a = 1, b) { 1 }", "1:11: parameter b without default value follows parameters with one"},
+		{"fn(...[a, b]) { 1 }", "expected next token to be IDENT, got [ instead"},
+		{"macro(a = 1) { a }", "macro parameters can't have default values or be variadic"},
+		{"f(a: 1, a: 2)", "1:9: duplicate named argument a"},
+		{"f(a: 1, 2)", "1:9: positional argument after named arguments"},
+		{"f(a: 1, ...xs)", "1:9: positional argument after named arguments"},
+	}
+
+	for _, tc := range errorCases {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Fatalf("expected errors for %q", tc.input)
+		}
+		if p.Errors[0].Error() != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, p.Errors[0].Error())
+		}
+	}
+
+	// a malformed parameter or argument list is reported once, and the rest of the program
+	// parses cleanly
+	resyncCases := []string{
+		"let f = fn(...rest, a, b) { rest }; let g = fn(x) { x };",
+		"let f = fn(...rest = g(1, 2)) { rest }; let g = fn(x) { x };",
+		"let f = fn(a = 1, b, c = 2) { a }; let g = fn(x) { x };",
+		"let x = f(a: 1, a: g(2)); let y = f(x);",
+		"let x = f(a: 1, ...xs, b: 2); let y = f(x);",
+	}
+
+	for _, input := range resyncCases {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors) != 1 {
+			t.Errorf("expected one error for %q, got=%v", input, p.Errors)
+		}
+		if len(program.Statements) != 2 {
+			t.Errorf("expected 2 statements for %q, got=%d", input, len(program.Statements))
+		}
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionExpression)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionExpression. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2*3, 4+5);`
 
@@ -439,6 +536,468 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+
+	p = New(lexer.New("let s = 1;\nputs(\"hello);"))
+	p.ParseProgram()
+	if len(p.Errors) == 0 || p.Errors[0].Error() != "2:6: unterminated string" {
+		t.Errorf("wrong errors for an unterminated string. got=%v", p.Errors)
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected map[string]int
+	}{
+		{`{}`, map[string]int{}},
+		{`{"one": 1, "two": 2, "three": 3}`, map[string]int{"one": 1, "two": 2, "three": 3}},
+	}
+
+	for _, tc := range testCases {
+		l := lexer.New(tc.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(hash.Keys) != len(tc.expected) {
+			t.Errorf("hash.Keys has wrong length. got=%d", len(hash.Keys))
+		}
+
+		for i, key := range hash.Keys {
+			literal, ok := key.(*ast.StringLiteral)
+			if !ok {
+				t.Errorf("key is not ast.StringLiteral. got=%T", key)
+				continue
+			}
+
+			testIntegerLiteral(t, hash.Values[i], tc.expected[literal.Value])
+		}
+	}
+}
+
+func TestIndexPrecedence(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+	}
+
+	for _, tc := range testCases {
+		l := lexer.New(tc.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("expected=%q, got=%q", tc.expected, program.String())
+		}
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f", "f(x)"},
+		{"x |> f(a, b)", "f(x, a, b)"},
+		{"xs |> filter(even) |> map(square) |> sum", "sum(map(filter(xs, even), square))"},
+		{"a + b * 2 |> f", "f((a + (b * 2)))"},
+		{"x |> f(a) == y", "(f(a) == y)(x)"},
+		{"x |> fn(n) { n * 2 }", "fn(n){\n(n * 2)\n}(x)"},
+		{"x |> m[\"f\"](1)", "(m[f])(x, 1)"},
+		{"x |> f(timeout: 1)", "f(x, timeout: 1)"},
+		{"let y = x |> f;", "let y = f(x);"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("expected=%q, got=%q", tc.expected, program.String())
+		}
+	}
+}
+
+func TestSpawnExpression(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"spawn f(1, 2 * 3)", "spawn f(1, (2 * 3))"},
+		{"spawn fn() { x }", "spawn fn(){\nx\n}"},
+		{"let t = spawn worker;", "let t = spawn worker;"},
+		{"await(spawn f(a)[0])", "await(spawn (f(a)[0]))"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("expected=%q, got=%q", tc.expected, program.String())
+		}
+	}
+}
+
+func TestImportExportParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`let math = import "lib/math";`, `let math = import "lib/math";`},
+		{`import "./util"["f"](1)`, `(import "./util"[f])(1)`},
+		{`export let add = fn(a, b) { a + b };`, "export let add = fn(a,b){\n(a + b)\n};"},
+		{`export let [a, b] = [1, 2];`, "export let [a, b] = [1, 2];"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("expected=%q, got=%q", tc.expected, program.String())
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"import math", "expected next token to be STRING, got IDENT instead"},
+		{"export fn() {}", "expected next token to be LET, got FUNCTION instead"},
+	}
+
+	for _, tc := range errorCases {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Fatalf("expected errors for %q", tc.input)
+		}
+		if p.Errors[0].Error() != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, p.Errors[0].Error())
+		}
+	}
+}
+
+func TestTryExpressionParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`try { f(1) } catch (e) { e["message"] }`, `try { f(1) } catch (e) { (e[message]) }`},
+		{`try { f() } finally { close() }`, `try { f() } finally { close() }`},
+		{`let x = try { 1 } catch (err) { 2 } finally { 3 };`, `let x = try { 1 } catch (err) { 2 } finally { 3 };`},
+		{`throw "boom";`, `throw boom;`},
+		{`if (x < 0) { throw {"message": "negative", "kind": "range"} }`, `if(x < 0) throw {message:negative, kind:range};`},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("expected=%q, got=%q", tc.expected, program.String())
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 }", "try without catch or finally"},
+		{"try { 1 } catch { 2 }", "expected next token to be (, got { instead"},
+		{"try { 1 } catch (1) { 2 }", "expected next token to be IDENT, got INT instead"},
+		{"try 1 catch (e) { 2 }", "expected next token to be {, got INT instead"},
+	}
+
+	for _, tc := range errorCases {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Fatalf("expected errors for %q", tc.input)
+		}
+		if p.Errors[0].Error() != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, p.Errors[0].Error())
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 => true, _ => false }", "match (x) { 1 => true, _ => false }"},
+		{"match (x) { -1 => a, n if n > 0 => b, }", "match (x) { (-1) => a, n if (n > 0) => b }"},
+		{`match (x) { "a" => 1, true => 2 }`, "match (x) { a => 1, true => 2 }"},
+		{"match (x) { [] => 0, [a, _] => a, [head, ...tail] => tail }", "match (x) { [] => 0, [a, _] => a, [head, ...tail] => tail }"},
+		{"match (x) { [first, ..._] => first }", "match (x) { [first, ..._] => first }"},
+		{`match (p) { {name, "age": [a, b]} => name + a, {} => 0 }`, "match (p) { {name, age:[a, b]} => (name + a), {} => 0 }"},
+		{"match (x) { y => y }", "match (x) { y => y }"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		if _, ok := stmt.Expression.(*ast.MatchExpression); !ok {
+			t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tc.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tc.input, tc.expected, program.String())
+		}
+	}
+}
+
+func TestDestructuringParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;"},
+		{"let {name, age} = person;", "let {name, age} = person;"},
+		{`let {"pos": [x, _], 1: one} = h;`, "let {pos:[x, _], 1:one} = h;"},
+		{"let f = fn(a, [b, c], {d}) { a };", "let f = fn(a,@1,@2){\nlet [b, c] = @1;\nlet {d} = @2;\na\n};"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tc.input, tc.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("macro([a]) { a }"))
+	p.ParseProgram()
+	if len(p.Errors) == 0 || p.Errors[0].Error() != "macro parameters can't be destructured" {
+		t.Errorf("wrong errors for destructured macro parameters. got=%v", p.Errors)
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"let [a, b]: [int] = xs;", "let [a, b]: [int] = xs;"},
+		{"fn(a: int, b) -> bool { a }", "fn(a: int,b) -> bool{\na\n}"},
+		{"fn(a, b: string) { a }", "fn(a,b: string){\na\n}"},
+		{"fn(f: fn(int, int) -> int, g: fn()) { f }", "fn(f: fn(int, int) -> int,g: fn()){\nf\n}"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tc.input, tc.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("fn(a, b: int, c) { a }"))
+	fn := p.ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionExpression)
+	checkErrors(t, p)
+	if len(fn.ParameterTypes) != 3 || fn.ParameterTypes[0] != nil || fn.ParameterTypes[1] == nil || fn.ParameterTypes[2] != nil {
+		t.Errorf("wrong parameter types. got=%v", fn.ParameterTypes)
+	}
+
+	p = New(lexer.New("let x: = 5;"))
+	p.ParseProgram()
+	if len(p.Errors) == 0 || p.Errors[0].Error() != "expected a type, got = instead" {
+		t.Errorf("wrong errors for a missing type. got=%v", p.Errors)
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { }", "match expression without arms"},
+		{"match (x) { 1 + 1 => 2 }", "expected next token to be =>, got + instead"},
+		{"match (x) { a(1) => 2 }", "expected next token to be =>, got ( instead"},
+		{"match (x) { [...rest, a] => 2 }", "expected next token to be ], got , instead"},
+		{"match (x) { {name: n} => n }", "hash pattern keys must be literals, got name, quote it to match the key"},
+		{"match (x) { fn => 1 }", "expected a pattern, got FUNCTION instead"},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+
+		if len(p.Errors) == 0 {
+			t.Fatalf("expected errors for %q", tc.input)
+		}
+
+		if p.Errors[0].Error() != tc.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tc.input, tc.expected, p.Errors[0].Error())
+		}
+	}
+}
+
+func TestUnreachableMatchArms(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"match (x) { 1 => 1, _ => 2 }", nil},
+		{"match (x) { n if n > 1 => 1, _ => 2 }", nil},
+		{"match (x) { _ => 1, 2 => 2, y => 3 }", []string{
+			"1:21: unreachable match arm, the arm at 1:13 matches every value",
+			"1:29: unreachable match arm, the arm at 1:13 matches every value",
+		}},
+		{"match (x) { 1 => 1, 2 => 2, 1 => 3 }", []string{"1:29: unreachable match arm, an earlier arm matches 1"}},
+		{`match (x) { "1" => 1, 1 => 2 }`, nil},
+		{"match (x) { 1 if x => 1, 1 => 2 }", nil},
+	}
+
+	for _, tc := range testCases {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+		checkErrors(t, p)
+
+		warnings := []string{}
+		for _, w := range p.Warnings {
+			warnings = append(warnings, w.Error())
+		}
+
+		if strings.Join(warnings, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("wrong warnings for %q.\nwant=%q\ngot=%q", tc.input, tc.expected, warnings)
+		}
+	}
+}
+
 func checkErrors(t *testing.T, p *Parser) {
 	if len(p.Errors) == 0 {
 		return
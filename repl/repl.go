@@ -2,15 +2,31 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"io"
+	"os"
+	"path/filepath"
 
 	"go-interpreter.com/m/evaluator"
 	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
 	"go-interpreter.com/m/parser"
+	"go-interpreter.com/m/resolver"
+	"go-interpreter.com/m/types"
 )
 
-func Start(in io.Reader, out io.Writer) {
+// ModulePathVariable names the environment variable listing the directories where imports
+// are looked up, separated like the directories of PATH
+const ModulePathVariable = "MONKEYPATH"
+
+// Start reads and evaluates lines of in until it's exhausted. With typeCheck the type
+// annotations of each line are checked before it's evaluated, see types.Check
+func Start(in io.Reader, out io.Writer, typeCheck bool) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
+	env.Modules().SearchPath = filepath.SplitList(os.Getenv(ModulePathVariable))
+	macroEnv := object.NewEnclosedEnvironment(env)
+
 	for {
 		io.WriteString(out, ">> ")
 		scanned := scanner.Scan()
@@ -23,9 +39,36 @@ func Start(in io.Reader, out io.Writer) {
 		program := p.ParseProgram()
 		if len(p.Errors) != 0 {
 			printParserErrors(out, p.Errors)
+			continue
+		}
+		for _, warning := range p.Warnings {
+			io.WriteString(out, "\twarning: "+warning.Error()+"\n")
+		}
+
+		evaluator.DefineMacros(program, macroEnv)
+		program, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			printParserErrors(out, []error{err})
+			continue
+		}
+
+		isDefined := func(name string) bool {
+			_, ok := env.Get(name)
+			return ok
+		}
+		if errs := resolver.Resolve(program, isDefined); len(errs) != 0 {
+			printParserErrors(out, errs)
+			continue
+		}
+
+		if typeCheck {
+			if errs := types.Check(program); len(errs) != 0 {
+				printParserErrors(out, errs)
+				continue
+			}
 		}
 
-		evaluated := evaluator.Eval(program)
+		evaluated := evaluator.EvalContext(object.WithOutput(context.Background(), out), program, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
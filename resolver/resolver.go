@@ -0,0 +1,282 @@
+// Package resolver binds the identifiers of a program to the function scope that declares
+// them before it's evaluated, reporting the scoping errors that can be found without running it
+package resolver
+
+import (
+	"fmt"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/token"
+)
+
+// Error is a scoping error found in the source, positioned at the offending token
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+// functionScope holds the slots of the frame of a function, every name bound by a let
+// statement of the body gets its slot before the body is resolved so closures can refer to
+// names bound after them. Match arms and catch blocks get a frame of their own too
+type functionScope struct {
+	slots    map[string]int
+	declared map[string]bool // names whose let statement or parameter was already resolved
+	locals   []string
+	block    bool // the frame of a match arm or a catch block, run where it's written unlike a function body
+}
+
+func newFunctionScope() *functionScope {
+	return &functionScope{slots: map[string]int{}, declared: map[string]bool{}, locals: []string{}}
+}
+
+func (s *functionScope) define(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+
+	s.slots[name] = len(s.locals)
+	s.locals = append(s.locals, name)
+	return s.slots[name]
+}
+
+type resolver struct {
+	scopes []*functionScope
+
+	// top-level names of the program, they stay globals looked up by name
+	globals         map[string]bool
+	declaredGlobals map[string]bool
+	isDefined       func(name string) bool
+
+	errors []error
+}
+
+// Resolve annotates the identifiers and function literals of program with the slots used by
+// the evaluator. It reports identifiers used before the let statement that declares them in
+// the same scope and duplicate parameters. isDefined tells whether a global was bound before
+// the program runs, like the ones of previous REPL inputs, it may be nil
+func Resolve(program *ast.Program, isDefined func(name string) bool) []error {
+	if isDefined == nil {
+		isDefined = func(string) bool { return false }
+	}
+
+	r := &resolver{globals: map[string]bool{}, declaredGlobals: map[string]bool{}, isDefined: isDefined}
+	for _, name := range ast.DeclaredNames(program.Statements) {
+		r.globals[name] = true
+	}
+
+	r.resolveStatements(program.Statements)
+	return r.errors
+}
+
+func (r *resolver) resolveStatements(statements []ast.Statement) {
+	for _, s := range statements {
+		r.resolveStatement(s)
+	}
+}
+
+func (r *resolver) resolveStatement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		if s.Exported && len(r.scopes) > 0 {
+			r.errorf(s.Token, "export is only allowed at the top level of a script")
+		}
+		r.resolveExpression(s.Value)
+		if s.Pattern != nil {
+			r.declarePattern(s.Pattern)
+		} else {
+			r.declare(s.Name)
+		}
+	case *ast.ReturnStatement:
+		r.resolveExpression(s.Value)
+	case *ast.ThrowStatement:
+		r.resolveExpression(s.Value)
+	case *ast.ExpressionStatement:
+		r.resolveExpression(s.Expression)
+	case *ast.BlockStatement:
+		r.resolveStatements(s.Statements)
+	}
+}
+
+func (r *resolver) resolveExpression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		r.resolveIdentifier(e)
+	case *ast.PrefixExpression:
+		r.resolveExpression(e.Right)
+	case *ast.InfixExpression:
+		r.resolveExpression(e.Left)
+		r.resolveExpression(e.Right)
+	case *ast.IfExpression:
+		r.resolveExpression(e.Condition)
+		r.resolveStatement(e.Consequence)
+		if e.Alternative != nil {
+			r.resolveStatement(e.Alternative)
+		}
+	case *ast.TryExpression:
+		r.resolveStatement(e.Block)
+		if e.CatchBlock != nil {
+			r.resolveCatch(e)
+		}
+		if e.Finally != nil {
+			r.resolveStatement(e.Finally)
+		}
+	case *ast.FunctionExpression:
+		r.resolveFunction(e)
+	case *ast.CallExpression:
+		// quoted code is data, the unquoted expressions in it are looked up by name
+		if ast.IsCallTo(e, "quote") {
+			return
+		}
+		r.resolveExpression(e.Function)
+		for _, arg := range e.Arguments {
+			r.resolveExpression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			r.resolveExpression(el)
+		}
+	case *ast.HashLiteral:
+		for i := range e.Keys {
+			r.resolveExpression(e.Keys[i])
+			r.resolveExpression(e.Values[i])
+		}
+	case *ast.IndexExpression:
+		r.resolveExpression(e.Left)
+		r.resolveExpression(e.Index)
+	case *ast.SpreadExpression:
+		r.resolveExpression(e.Value)
+	case *ast.NamedArgument:
+		r.resolveExpression(e.Value)
+	case *ast.SpawnExpression:
+		r.resolveExpression(e.Function)
+	case *ast.MatchExpression:
+		r.resolveExpression(e.Value)
+		for _, arm := range e.Arms {
+			r.resolveMatchArm(arm)
+		}
+	}
+}
+
+// resolveMatchArm declares the names bound by the pattern in the frame of the arm before
+// resolving the guard and the body, the names aren't visible after the match expression
+func (r *resolver) resolveMatchArm(arm *ast.MatchArm) {
+	scope := r.enterBlock(ast.ArmNames(arm))
+	defer r.leaveBlock()
+
+	r.declarePattern(arm.Pattern)
+
+	if arm.Guard != nil {
+		r.resolveExpression(arm.Guard)
+	}
+	r.resolveExpression(arm.Body)
+	arm.Locals = scope.locals
+}
+
+// resolveCatch declares the caught error in the frame of the catch block, like the names
+// bound by the block it isn't visible after the try expression
+func (r *resolver) resolveCatch(te *ast.TryExpression) {
+	scope := r.enterBlock(ast.CatchNames(te))
+	defer r.leaveBlock()
+
+	r.declare(te.Catch)
+	r.resolveStatement(te.CatchBlock)
+	te.CatchLocals = scope.locals
+}
+
+// enterBlock pushes the frame of a match arm or a catch block with a slot for each of names
+func (r *resolver) enterBlock(names []string) *functionScope {
+	scope := newFunctionScope()
+	scope.block = true
+	for _, name := range names {
+		scope.define(name)
+	}
+
+	r.scopes = append(r.scopes, scope)
+	return scope
+}
+
+func (r *resolver) leaveBlock() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *resolver) resolveFunction(fn *ast.FunctionExpression) {
+	scope := newFunctionScope()
+	r.scopes = append(r.scopes, scope)
+	defer func() { r.scopes = r.scopes[:len(r.scopes)-1] }()
+
+	// defaults are evaluated in the frame of the call, they see the parameters before them
+	for i, param := range fn.Parameters {
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			r.resolveExpression(fn.Defaults[i])
+		}
+		if _, ok := scope.slots[param.Value]; ok {
+			r.errorf(param.Token, "duplicate parameter: %s", param.Value)
+		}
+		r.declare(param)
+	}
+
+	for _, name := range ast.DeclaredNames(fn.Body.Statements) {
+		scope.define(name)
+	}
+
+	r.resolveStatements(fn.Body.Statements)
+	fn.Locals = scope.locals
+}
+
+func (r *resolver) declarePattern(pattern ast.Pattern) {
+	bound := map[string]bool{}
+	for _, name := range ast.PatternIdentifiers(pattern) {
+		if bound[name.Value] {
+			r.errorf(name.Token, "duplicate binding in pattern: %s", name.Value)
+		}
+		bound[name.Value] = true
+		r.declare(name)
+	}
+}
+
+// declare marks the name bound by a let statement, parameter or pattern as usable from here on
+func (r *resolver) declare(name *ast.Identifier) {
+	if len(r.scopes) == 0 {
+		r.declaredGlobals[name.Value] = true
+		name.Local = false
+		return
+	}
+
+	scope := r.scopes[len(r.scopes)-1]
+	scope.declared[name.Value] = true
+	name.Local, name.Depth, name.Slot = true, 0, scope.define(name.Value)
+}
+
+// resolveIdentifier binds ident to the innermost function scope that declares it, names that
+// aren't declared by any function are globals. Uses from nested functions may come before the
+// declaration since the function body runs later
+func (r *resolver) resolveIdentifier(ident *ast.Identifier) {
+	runsNow := true // no function scope was crossed, the use runs when it's reached
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		slot, ok := r.scopes[i].slots[ident.Value]
+		if !ok {
+			runsNow = runsNow && r.scopes[i].block
+			continue
+		}
+
+		if runsNow && !r.scopes[i].declared[ident.Value] {
+			r.errorf(ident.Token, "identifier used before declaration: %s", ident.Value)
+		}
+
+		ident.Local, ident.Depth, ident.Slot = true, len(r.scopes)-1-i, slot
+		return
+	}
+
+	ident.Local = false
+	if runsNow && r.globals[ident.Value] && !r.declaredGlobals[ident.Value] && !r.isDefined(ident.Value) {
+		r.errorf(ident.Token, "identifier used before declaration: %s", ident.Value)
+	}
+}
+
+func (r *resolver) errorf(tok token.Token, format string, args ...interface{}) {
+	r.errors = append(r.errors, &Error{Token: tok, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,165 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/parser"
+)
+
+func TestResolveSlots(t *testing.T) {
+	input := `
+let g = 1;
+let outer = fn(a, b) {
+  let c = a;
+  if (b) { let d = c; d }
+  fn(e) { a + e + g + len }
+};`
+
+	program := parse(t, input)
+	if errs := Resolve(program, nil); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	outer := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionExpression)
+	if strings.Join(outer.Locals, ",") != "a,b,c,d" {
+		t.Errorf("wrong locals for outer function. got=%v", outer.Locals)
+	}
+
+	identifiers := map[string]*ast.Identifier{}
+	collectIdentifiers(outer.Body, identifiers)
+
+	inner := outer.Body.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionExpression)
+	if strings.Join(inner.Locals, ",") != "e" {
+		t.Errorf("wrong locals for inner function. got=%v", inner.Locals)
+	}
+
+	testCases := []struct {
+		name  string
+		local bool
+		depth int
+		slot  int
+	}{
+		{"c", true, 0, 2},
+		{"d", true, 0, 3},
+		{"a", true, 1, 0},
+		{"e", true, 0, 0},
+		{"g", false, 0, 0},
+		{"len", false, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		ident, ok := identifiers[tc.name]
+		if !ok {
+			t.Fatalf("identifier %s not found", tc.name)
+		}
+
+		if ident.Local != tc.local || ident.Depth != tc.depth || ident.Slot != tc.slot {
+			t.Errorf("identifier %s resolved to local=%t depth=%d slot=%d, want local=%t depth=%d slot=%d",
+				tc.name, ident.Local, ident.Depth, ident.Slot, tc.local, tc.depth, tc.slot)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn(a, b, a) { a };", []string{"1:18: duplicate parameter: a"}},
+		{"let f = fn(a, b = a + 1, ...a) { b };", []string{"1:29: duplicate parameter: a"}},
+		{"let f = fn() { x; let x = 1; };", []string{"1:16: identifier used before declaration: x"}},
+		{"let f = fn() { let x = x + 1; };", []string{"1:24: identifier used before declaration: x"}},
+		{"let f = fn() { if (true) { y } let y = 1; };", []string{"1:28: identifier used before declaration: y"}},
+		{"let a = b; let b = 1;", []string{"1:9: identifier used before declaration: b"}},
+		{"x; let x = 1; x;", []string{"1:1: identifier used before declaration: x"}},
+		{"let f = fn(a, a) { b; let b = 1; };", []string{
+			"1:15: duplicate parameter: a",
+			"1:20: identifier used before declaration: b",
+		}},
+		// functions run after the names they use are bound
+		{"let f = fn() { g() }; let g = fn() { 1 };", nil},
+		{"let f = fn() { let h = fn() { k }; let k = 1; h() };", nil},
+		{"let f = fn(n) { f(n - 1) };", nil},
+		// globals that aren't declared by the program are looked up when evaluated
+		{"unknown; let f = fn() { unknown };", nil},
+		{"let f = fn(a) { let a = a + 1; a };", nil},
+		{"let f = fn(x) { match (x) { [a, {a}] => a } };", []string{"1:34: duplicate binding in pattern: a"}},
+		{"let f = fn(x) { match (x) { [a, b] => a + b, c => c } };", nil},
+		// match arms bind their names in a scope of their own
+		{"let x = 10; let f = fn() { let y = x; match (1) { x => x } };", nil},
+		{"let f = fn() { match (1) { _ => y }; let y = 2; };", []string{"1:33: identifier used before declaration: y"}},
+		{"match (1) { _ => y }; let y = 2;", []string{"1:18: identifier used before declaration: y"}},
+		{"let f = fn([a, b]) { let {c, d} = a; b + c + d };", nil},
+		{"let [a, a] = [1, 2];", []string{"1:9: duplicate binding in pattern: a"}},
+		{"let f = fn() { c; let [c] = [1]; };", []string{"1:16: identifier used before declaration: c"}},
+		{"export let x = 1; if (x) { export let y = 2; }", nil},
+		{"let f = fn() { try { 1 } catch (e) { e } };", nil},
+		{"let f = fn() { e; try { 1 } catch (e) { e } };", nil},
+		{"let f = fn() { try { 1 } catch (e) { y; let y = 1; } };", []string{"1:38: identifier used before declaration: y"}},
+		{"let f = fn() { export let x = 1; };", []string{"1:23: export is only allowed at the top level of a script"}},
+	}
+
+	for _, tc := range testCases {
+		errs := Resolve(parse(t, tc.input), nil)
+
+		messages := []string{}
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+
+		if strings.Join(messages, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot=%q", tc.input, tc.expected, messages)
+		}
+	}
+}
+
+func TestResolveDefinedGlobals(t *testing.T) {
+	isDefined := func(name string) bool { return name == "x" }
+
+	if errs := Resolve(parse(t, "let x = x + 1;"), isDefined); len(errs) != 0 {
+		t.Errorf("globals bound before the program are reported. got=%v", errs)
+	}
+
+	if errs := Resolve(parse(t, "let y = y + 1;"), isDefined); len(errs) != 1 {
+		t.Errorf("expected one error for y. got=%v", errs)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors)
+	}
+
+	return program
+}
+
+// collectIdentifiers keeps the last use of each name in the expressions of node
+func collectIdentifiers(node ast.Node, identifiers map[string]*ast.Identifier) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			collectIdentifiers(s, identifiers)
+		}
+	case *ast.LetStatement:
+		collectIdentifiers(node.Value, identifiers)
+	case *ast.ExpressionStatement:
+		collectIdentifiers(node.Expression, identifiers)
+	case *ast.IfExpression:
+		collectIdentifiers(node.Condition, identifiers)
+		collectIdentifiers(node.Consequence, identifiers)
+	case *ast.FunctionExpression:
+		collectIdentifiers(node.Body, identifiers)
+	case *ast.InfixExpression:
+		collectIdentifiers(node.Left, identifiers)
+		collectIdentifiers(node.Right, identifiers)
+	case *ast.Identifier:
+		identifiers[node.Value] = node
+	}
+}
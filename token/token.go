@@ -6,20 +6,28 @@ const (
 	Illegal = TokenType("ILLEGAL")
 	EOF     = TokenType("EOF")
 
-	Ident = TokenType("IDENT")
-	INT   = TokenType("INT")
+	Ident  = TokenType("IDENT")
+	INT    = TokenType("INT")
+	STRING = TokenType("STRING")
 
 	Assign = TokenType("=")
 	Plus   = TokenType("+")
 	Minus  = TokenType("-")
 
-	Comma     = TokenType(",")
-	Semicolon = TokenType(";")
+	Comma       = TokenType(",")
+	Semicolon   = TokenType(";")
+	Colon       = TokenType(":")
+	Arrow       = TokenType("=>")
+	ReturnArrow = TokenType("->")
+	Ellipsis    = TokenType("...")
+	Pipe        = TokenType("|>")
 
-	LParen  = TokenType("(")
-	RParent = TokenType(")")
-	LBrace  = TokenType("{")
-	RBrace  = TokenType("}")
+	LParen   = TokenType("(")
+	RParent  = TokenType(")")
+	LBrace   = TokenType("{")
+	RBrace   = TokenType("}")
+	LBracket = TokenType("[")
+	RBracket = TokenType("]")
 
 	Function = TokenType("FUNCTION")
 	Let      = TokenType("LET")
@@ -37,21 +45,42 @@ const (
 	IfConditional   = TokenType("if")
 	ElseConditional = TokenType("else")
 	ReturnStatement = TokenType("return")
+	Spawn           = TokenType("spawn")
+	Macro           = TokenType("MACRO")
+	Match           = TokenType("MATCH")
+	Import          = TokenType("IMPORT")
+	Export          = TokenType("EXPORT")
+	Throw           = TokenType("THROW")
+	Try             = TokenType("TRY")
+	Catch           = TokenType("CATCH")
+	Finally         = TokenType("FINALLY")
 )
 
+// Token is a lexeme of the source, Line and Column are 1-based and point to its first character
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
 }
 
 var keywords = map[string]TokenType{
-	"fn":     Function,
-	"let":    Let,
-	"true":   True,
-	"false":  False,
-	"if":     IfConditional,
-	"else":   ElseConditional,
-	"return": ReturnStatement,
+	"fn":      Function,
+	"let":     Let,
+	"true":    True,
+	"false":   False,
+	"if":      IfConditional,
+	"else":    ElseConditional,
+	"return":  ReturnStatement,
+	"spawn":   Spawn,
+	"macro":   Macro,
+	"match":   Match,
+	"import":  Import,
+	"export":  Export,
+	"throw":   Throw,
+	"try":     Try,
+	"catch":   Catch,
+	"finally": Finally,
 }
 
 func LookupIdentifier(identifier string) TokenType {
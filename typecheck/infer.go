@@ -0,0 +1,576 @@
+// Package typecheck infers the types of programs without annotations, let bindings get the
+// most general type their value allows and can be used with different types, like an
+// identity function applied to an int and to a string
+package typecheck
+
+import (
+	"errors"
+	"fmt"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/token"
+)
+
+// Error is a type error found in the source, positioned at the offending expression
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+// Result holds the types of the top level let bindings of a program, Names lists them in
+// source order, a name bound twice keeps the type of its last binding
+type Result struct {
+	Names []string
+	Types map[string]*Scheme
+}
+
+// scope holds the types of the names bound in a function, if blocks share the scope of the
+// function like they do when the program runs
+type scope struct {
+	names map[string]*Scheme
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: map[string]*Scheme{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) (*Scheme, bool) {
+	for ; s != nil; s = s.outer {
+		if scheme, ok := s.names[name]; ok {
+			return scheme, true
+		}
+	}
+
+	return nil, false
+}
+
+type inferer struct {
+	scope    *scope
+	builtins *scope
+	level    int
+	nextID   int
+
+	results  []Type // result types of the functions whose bodies are being inferred
+	branches int    // if blocks being inferred
+	errors   []error
+}
+
+// Infer returns the principal types of the top level let bindings of program and the
+// errors of the expressions whose types conflict. Builtins have their usual types, except
+// for puts that accepts any number of arguments of any type
+func Infer(program *ast.Program) (*Result, []error) {
+	builtins := builtinScope()
+	in := &inferer{scope: newScope(builtins), builtins: builtins}
+	result := &Result{Types: map[string]*Scheme{}}
+
+	for _, s := range program.Statements {
+		in.inferStatement(s)
+
+		let, ok := s.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+		if _, seen := result.Types[let.Name.Value]; !seen {
+			result.Names = append(result.Names, let.Name.Value)
+		}
+		result.Types[let.Name.Value] = in.scope.names[let.Name.Value]
+	}
+
+	return result, in.errors
+}
+
+// builtinScope holds the schemes of the builtins, their variables are never generalized by
+// the program since level 0 is never entered
+func builtinScope() *scope {
+	s := newScope(nil)
+	generic := func(allowed []string, build func(a *Variable) Type) *Scheme {
+		a := &Variable{id: -1, level: 1, allowed: allowed}
+		return &Scheme{Variables: []*Variable{a}, Type: build(a)}
+	}
+
+	s.names["len"] = generic([]string{"string", "array", "hash"}, func(a *Variable) Type {
+		return &Function{Parameters: []Type{a}, Result: Int}
+	})
+	s.names["first"] = generic(nil, func(a *Variable) Type {
+		return &Function{Parameters: []Type{Array(a)}, Result: a}
+	})
+	s.names["last"] = s.names["first"]
+	s.names["rest"] = generic(nil, func(a *Variable) Type {
+		return &Function{Parameters: []Type{Array(a)}, Result: Array(a)}
+	})
+	s.names["push"] = generic(nil, func(a *Variable) Type {
+		return &Function{Parameters: []Type{Array(a), a}, Result: Array(a)}
+	})
+	s.names["type"] = generic(nil, func(a *Variable) Type {
+		return &Function{Parameters: []Type{a}, Result: String}
+	})
+	s.names["puts"] = generic(nil, func(a *Variable) Type {
+		return &Function{Parameters: []Type{a}, Result: Null}
+	})
+
+	return s
+}
+
+func (in *inferer) fresh(allowed []string) *Variable {
+	in.nextID++
+	return &Variable{id: in.nextID, level: in.level, allowed: allowed}
+}
+
+// generalize quantifies the variables of t created inside the let being left
+func (in *inferer) generalize(t Type) *Scheme {
+	scheme := &Scheme{Type: t}
+	seen := map[*Variable]bool{}
+
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch t := prune(t).(type) {
+		case *Variable:
+			if t.level > in.level && !seen[t] {
+				seen[t] = true
+				scheme.Variables = append(scheme.Variables, t)
+			}
+		case *Constructor:
+			for _, arg := range t.Arguments {
+				walk(arg)
+			}
+		case *Function:
+			for _, param := range t.Parameters {
+				walk(param)
+			}
+			walk(t.Result)
+		}
+	}
+	walk(t)
+
+	return scheme
+}
+
+// instantiate replaces the quantified variables of scheme with fresh ones
+func (in *inferer) instantiate(scheme *Scheme) Type {
+	if len(scheme.Variables) == 0 {
+		return scheme.Type
+	}
+
+	fresh := map[*Variable]Type{}
+	for _, v := range scheme.Variables {
+		fresh[v] = in.fresh(v.allowed)
+	}
+
+	var copyType func(t Type) Type
+	copyType = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Variable:
+			if v, ok := fresh[t]; ok {
+				return v
+			}
+			return t
+		case *Constructor:
+			if len(t.Arguments) == 0 {
+				return t
+			}
+			args := make([]Type, 0, len(t.Arguments))
+			for _, arg := range t.Arguments {
+				args = append(args, copyType(arg))
+			}
+			return &Constructor{Name: t.Name, Arguments: args}
+		case *Function:
+			params := make([]Type, 0, len(t.Parameters))
+			for _, param := range t.Parameters {
+				params = append(params, copyType(param))
+			}
+			return &Function{Parameters: params, Result: copyType(t.Result)}
+		}
+		return t
+	}
+
+	return copyType(scheme.Type)
+}
+
+func (in *inferer) errorf(node ast.Node, format string, a ...interface{}) {
+	in.errors = append(in.errors, &Error{Token: ast.Position(node), Message: fmt.Sprintf(format, a...)})
+}
+
+// expect unifies the type got of node with want, context is the expression node is part of
+func (in *inferer) expect(node ast.Node, got, want Type, context string) {
+	err := unify(got, want)
+	if err == nil {
+		return
+	}
+
+	n := newNamer()
+	var infinite *infiniteTypeError
+	if errors.As(err, &infinite) {
+		in.errorf(node, "%s has infinite type %s = %s in %s",
+			node, n.typeString(infinite.variable), n.typeString(infinite.t), context)
+		return
+	}
+
+	in.errorf(node, "%s has type %s, want %s in %s", node, n.typeString(got), expected(n, want), context)
+}
+
+// expected formats the type wanted by an operator, a constrained variable is written as the
+// types it accepts
+func expected(n *namer, t Type) string {
+	if v, ok := prune(t).(*Variable); ok && v.allowed != nil {
+		return allowedString(v.allowed)
+	}
+
+	return n.typeString(t)
+}
+
+func (in *inferer) unsupported(node ast.Node, what string) Type {
+	in.errorf(node, "type inference doesn't support %s", what)
+	return in.fresh(nil)
+}
+
+// inferStatements returns the type of the value of the last statement
+func (in *inferer) inferStatements(statements []ast.Statement) Type {
+	var result Type = Null
+	for _, s := range statements {
+		result = in.inferStatement(s)
+	}
+
+	return result
+}
+
+func (in *inferer) inferStatement(s ast.Statement) Type {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		in.inferLet(s)
+		return Null
+	case *ast.ReturnStatement:
+		t := in.inferExpression(s.Value)
+		if n := len(in.results); n > 0 {
+			in.expect(s.Value, t, in.results[n-1], s.String())
+		}
+		// the statement has no value, the code after it never runs
+		return in.fresh(nil)
+	case *ast.ExpressionStatement:
+		return in.inferExpression(s.Expression)
+	case *ast.BlockStatement:
+		return in.inferStatements(s.Statements)
+	case *ast.ThrowStatement:
+		return in.unsupported(s, "throw statements")
+	}
+
+	return in.unsupported(s, s.String())
+}
+
+func (in *inferer) inferLet(s *ast.LetStatement) {
+	if s.Pattern != nil {
+		in.unsupported(s, "destructuring")
+		return
+	}
+
+	in.level++
+
+	// bound before the body is inferred so the function can call itself, without being
+	// generalized so every recursive call has the same type
+	var self Type
+	if _, ok := s.Value.(*ast.FunctionExpression); ok {
+		self = in.fresh(nil)
+		in.scope.names[s.Name.Value] = &Scheme{Type: self}
+	}
+
+	t := in.inferExpression(s.Value)
+	if self != nil {
+		in.expect(s.Value, t, self, s.String())
+	}
+	if s.Type != nil {
+		in.expect(s.Value, t, in.annotation(s.Type), s.String())
+	}
+
+	in.level--
+
+	// a name bound again by an if block keeps its type, the block may not run
+	if in.branches > 0 && self == nil {
+		if previous, ok := in.scope.names[s.Name.Value]; ok && len(previous.Variables) == 0 {
+			in.expect(s.Value, t, previous.Type, s.String())
+			return
+		}
+	}
+
+	in.scope.names[s.Name.Value] = in.generalize(t)
+}
+
+// annotation returns the type written by a, any is a fresh variable
+func (in *inferer) annotation(a ast.TypeAnnotation) Type {
+	switch a := a.(type) {
+	case *ast.NamedType:
+		switch a.Name {
+		case "int":
+			return Int
+		case "string":
+			return String
+		case "bool":
+			return Bool
+		case "null":
+			return Null
+		case "any":
+			return in.fresh(nil)
+		}
+		in.errors = append(in.errors, &Error{Token: a.Token, Message: fmt.Sprintf("unknown type: %s", a.Name)})
+		return in.fresh(nil)
+	case *ast.ArrayType:
+		return Array(in.annotation(a.Element))
+	case *ast.HashType:
+		return Hash(in.annotation(a.Key), in.annotation(a.Value))
+	case *ast.FunctionType:
+		fn := &Function{Parameters: make([]Type, 0, len(a.Parameters))}
+		for _, param := range a.Parameters {
+			fn.Parameters = append(fn.Parameters, in.annotation(param))
+		}
+		if a.Result != nil {
+			fn.Result = in.annotation(a.Result)
+		} else {
+			fn.Result = in.fresh(nil)
+		}
+		return fn
+	}
+
+	return in.fresh(nil)
+}
+
+func (in *inferer) inferExpression(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		scheme, ok := in.scope.lookup(e.Value)
+		if !ok {
+			in.errorf(e, "undefined: %s", e.Value)
+			return in.fresh(nil)
+		}
+		return in.instantiate(scheme)
+	case *ast.PrefixExpression:
+		return in.inferPrefix(e)
+	case *ast.InfixExpression:
+		return in.inferInfix(e)
+	case *ast.IfExpression:
+		return in.inferIf(e)
+	case *ast.FunctionExpression:
+		return in.inferFunction(e)
+	case *ast.CallExpression:
+		return in.inferCall(e)
+	case *ast.ArrayLiteral:
+		element := in.fresh(nil)
+		for _, el := range e.Elements {
+			in.expect(el, in.inferExpression(el), element, "array literal "+e.String())
+		}
+		return Array(element)
+	case *ast.HashLiteral:
+		key, value := in.fresh([]string{"int", "string", "bool"}), in.fresh(nil)
+		for i := range e.Keys {
+			in.expect(e.Keys[i], in.inferExpression(e.Keys[i]), key, "hash literal "+e.String())
+			in.expect(e.Values[i], in.inferExpression(e.Values[i]), value, "hash literal "+e.String())
+		}
+		return Hash(key, value)
+	case *ast.IndexExpression:
+		return in.inferIndex(e)
+	case *ast.MatchExpression:
+		return in.unsupported(e, "match expressions")
+	case *ast.SpawnExpression:
+		return in.unsupported(e, "spawn")
+	case *ast.SpreadExpression:
+		return in.unsupported(e, "spread arguments")
+	case *ast.NamedArgument:
+		return in.unsupported(e, "named arguments")
+	case *ast.ImportExpression:
+		return in.unsupported(e, "imports")
+	case *ast.TryExpression:
+		return in.unsupported(e, "try expressions")
+	case *ast.MacroLiteral:
+		return in.unsupported(e, "macros")
+	}
+
+	return in.unsupported(e, e.String())
+}
+
+func (in *inferer) inferPrefix(e *ast.PrefixExpression) Type {
+	right := in.inferExpression(e.Right)
+	if e.Operator == "-" {
+		in.expect(e.Right, right, Int, e.String())
+		return Int
+	}
+
+	// ! negates the truthiness of any value
+	return Bool
+}
+
+func (in *inferer) inferInfix(e *ast.InfixExpression) Type {
+	left := in.inferExpression(e.Left)
+	right := in.inferExpression(e.Right)
+
+	switch e.Operator {
+	case "+":
+		operand := in.fresh([]string{"int", "string"})
+		in.expect(e.Left, left, operand, e.String())
+		in.expect(e.Right, right, operand, e.String())
+		return operand
+	case "==", "!=":
+		operand := in.fresh([]string{"int", "string", "bool"})
+		in.expect(e.Left, left, operand, e.String())
+		in.expect(e.Right, right, operand, e.String())
+		return Bool
+	case "<", ">":
+		in.expect(e.Left, left, Int, e.String())
+		in.expect(e.Right, right, Int, e.String())
+		return Bool
+	}
+
+	in.expect(e.Left, left, Int, e.String())
+	in.expect(e.Right, right, Int, e.String())
+	return Int
+}
+
+// inferIf accepts conditions of any type since every value is truthy or falsy, without an
+// alternative the expression is null when the condition is falsy
+func (in *inferer) inferIf(e *ast.IfExpression) Type {
+	in.inferExpression(e.Condition)
+
+	in.branches++
+	defer func() { in.branches-- }()
+
+	consequence := in.inferStatements(e.Consequence.Statements)
+	if e.Alternative == nil {
+		return Null
+	}
+
+	alternative := in.inferStatements(e.Alternative.Statements)
+	if err := unify(consequence, alternative); err != nil {
+		n := newNamer()
+		in.errorf(e, "branches of if have different types: %s and %s",
+			n.typeString(consequence), n.typeString(alternative))
+	}
+
+	return consequence
+}
+
+func (in *inferer) inferFunction(fn *ast.FunctionExpression) Type {
+	if fn.Defaults != nil || fn.Variadic {
+		return in.unsupported(fn, "default and rest parameters")
+	}
+
+	enclosing := in.scope
+	in.scope = newScope(enclosing)
+	defer func() { in.scope = enclosing }()
+
+	t := &Function{Parameters: make([]Type, 0, len(fn.Parameters)), Result: in.fresh(nil)}
+	for i, param := range fn.Parameters {
+		var pt Type = in.fresh(nil)
+		if i < len(fn.ParameterTypes) && fn.ParameterTypes[i] != nil {
+			pt = in.annotation(fn.ParameterTypes[i])
+		}
+		t.Parameters = append(t.Parameters, pt)
+		in.scope.names[param.Value] = &Scheme{Type: pt}
+	}
+	if fn.ReturnType != nil {
+		in.expect(fn, t.Result, in.annotation(fn.ReturnType), "result of "+fn.String())
+	}
+
+	// the if blocks of the enclosing function don't contain the body
+	branches := in.branches
+	in.branches = 0
+	in.results = append(in.results, t.Result)
+
+	last := in.inferStatements(fn.Body.Statements)
+	var lastNode ast.Node = fn
+	if n := len(fn.Body.Statements); n > 0 {
+		lastNode = fn.Body.Statements[n-1]
+	}
+	in.expect(lastNode, last, t.Result, "result of "+functionName(fn))
+
+	in.results = in.results[:len(in.results)-1]
+	in.branches = branches
+
+	return t
+}
+
+func functionName(fn *ast.FunctionExpression) string {
+	if fn.Name != "" {
+		return fn.Name
+	}
+
+	return "function literal"
+}
+
+func (in *inferer) inferCall(e *ast.CallExpression) Type {
+	// puts prints any number of values of any type
+	if ident, ok := e.Function.(*ast.Identifier); ok && ident.Value == "puts" {
+		if scheme, _ := in.scope.lookup("puts"); scheme == in.builtins.names["puts"] {
+			for _, arg := range e.Arguments {
+				in.inferExpression(arg)
+			}
+			return Null
+		}
+	}
+
+	callee := in.inferExpression(e.Function)
+	args := make([]Type, 0, len(e.Arguments))
+	for _, arg := range e.Arguments {
+		args = append(args, in.inferExpression(arg))
+	}
+
+	switch fn := prune(callee).(type) {
+	case *Variable:
+		result := in.fresh(nil)
+		in.expect(e.Function, callee, &Function{Parameters: args, Result: result}, e.String())
+		return result
+	case *Function:
+		return in.checkArguments(e, fn, args)
+	}
+
+	in.errorf(e.Function, "cannot call non-function %s (type %s)", e.Function, callee)
+	return in.fresh(nil)
+}
+
+func (in *inferer) checkArguments(e *ast.CallExpression, fn *Function, args []Type) Type {
+	if len(fn.Parameters) != len(args) {
+		in.errorf(e, "wrong number of arguments to %s: want=%d, got=%d", e.Function, len(fn.Parameters), len(args))
+		return fn.Result
+	}
+
+	for i, arg := range e.Arguments {
+		in.expect(arg, args[i], fn.Parameters[i], fmt.Sprintf("argument %d of %s", i+1, e))
+	}
+
+	return fn.Result
+}
+
+func (in *inferer) inferIndex(e *ast.IndexExpression) Type {
+	left := in.inferExpression(e.Left)
+	index := in.inferExpression(e.Index)
+
+	switch l := prune(left).(type) {
+	case *Constructor:
+		switch l.Name {
+		case "array":
+			in.expect(e.Index, index, Int, e.String())
+			return l.Arguments[0]
+		case "hash":
+			in.expect(e.Index, index, l.Arguments[0], e.String())
+			return l.Arguments[1]
+		}
+	case *Variable:
+		// a value only indexed is an array when the index is an int, a hash otherwise
+		value := in.fresh(nil)
+		container := Hash(index, value)
+		if prune(index) == Int {
+			container = Array(value)
+		}
+		in.expect(e.Left, left, container, e.String())
+		return value
+	}
+
+	in.errorf(e.Left, "cannot index %s (type %s)", e.Left, left)
+	return in.fresh(nil)
+}
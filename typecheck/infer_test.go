@@ -0,0 +1,126 @@
+package typecheck
+
+import (
+	"strings"
+	"testing"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parse errors for %q: %v", input, p.Errors)
+	}
+
+	return program
+}
+
+func TestInfer(t *testing.T) {
+	testCases := []struct {
+		input    string
+		name     string
+		expected string
+	}{
+		{`let x = 5;`, "x", "int"},
+		{`let s = "a" + "b";`, "s", "string"},
+		{`let b = 1 < 2;`, "b", "bool"},
+		{`let id = fn(x) { x };`, "id", "fn(a) -> a"},
+		{`let k = fn(x, y) { x };`, "k", "fn(a, b) -> a"},
+		{`let id = fn(x) { x }; let pair = [id(1), id(2)];`, "pair", "[int]"},
+		{`let id = fn(x) { x }; let s = id("a"); let n = id(1);`, "s", "string"},
+		{`let add = fn(a, b) { a + b };`, "add", "fn(a, a) -> a where a: int | string"},
+		{`let add = fn(a, b) { a + b }; let n = add(1, 2);`, "n", "int"},
+		{`let eq = fn(a, b) { a == b };`, "eq", "fn(a, a) -> bool where a: bool | int | string"},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };`, "fib", "fn(int) -> int"},
+		{`let fact = fn(n) { if (n == 0) { return 1; } n * fact(n - 1) };`, "fact", "fn(int) -> int"},
+		{`let compose = fn(f, g) { fn(x) { f(g(x)) } };`, "compose", "fn(fn(a) -> b, fn(c) -> a) -> fn(c) -> b"},
+		{`let map = fn(arr, f) { if (len(arr) == 0) { [] } else { push(map(rest(arr), f), f(first(arr))) } };`,
+			"map", "fn([a], fn(a) -> b) -> [b]"},
+		{`let get = fn(h) { h["key"] };`, "get", "fn({string: a}) -> a"},
+		{`let second = fn(arr) { arr[1] + 1 };`, "second", "fn([int]) -> int"},
+		{`let size = fn(x) { len(x) };`, "size", "fn(a) -> int where a: array | hash | string"},
+		{`let h = {"a": 1, "b": 2};`, "h", "{string: int}"},
+		{`let f = fn(x: int) -> any { x };`, "f", "fn(int) -> int"},
+		{`let f = fn(x) { puts(x, 1); x };`, "f", "fn(a) -> a"},
+		{`let f = fn(x) { if (x) { 1 } };`, "f", "fn(a) -> null"},
+		{`let f = fn(x) { let y = x; y };`, "f", "fn(a) -> a"},
+	}
+
+	for _, tc := range testCases {
+		result, errs := Infer(parse(t, tc.input))
+		if len(errs) != 0 {
+			t.Errorf("unexpected errors for %q: %v", tc.input, errs)
+			continue
+		}
+
+		scheme, ok := result.Types[tc.name]
+		if !ok {
+			t.Errorf("no type for %s in %q", tc.name, tc.input)
+			continue
+		}
+		if got := scheme.String(); got != tc.expected {
+			t.Errorf("wrong type of %s in %q, got=%q, want=%q", tc.name, tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestInferNames(t *testing.T) {
+	result, errs := Infer(parse(t, `let a = 1; let b = "b"; puts(a); let a = true;`))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if strings.Join(result.Names, ",") != "a,b" {
+		t.Errorf("wrong names, got=%v", result.Names)
+	}
+	if got := result.Types["a"].String(); got != "bool" {
+		t.Errorf("wrong type of rebound a, got=%q", got)
+	}
+}
+
+func TestInferErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`1 + "a"`, []string{`1:5: a has type string, want int in (1 + a)`}},
+		{`true + 1`, []string{`1:1: true has type bool, want int | string in (true + 1)`}},
+		{`-"a"`, []string{`1:2: a has type string, want int in (-a)`}},
+		{`let id = fn(x) { x }; id(1) - "a"`, []string{`1:31: a has type string, want int in (id(1) - a)`}},
+		{`let f = fn(x) { x + 1 }; f("a")`, []string{`1:28: a has type string, want int in argument 1 of f(a)`}},
+		{`let f = fn(x) { x }; f(1, 2)`, []string{"1:22: wrong number of arguments to f: want=1, got=2"}},
+		{`let x = 5; x(1)`, []string{"1:12: cannot call non-function x (type int)"}},
+		{`let f = fn(x) { x(x) };`, []string{"1:17: x has infinite type a = fn(a) -> b in x(x)"}},
+		{`let f = fn(x) { if (x) { 1 } else { "a" } };`, []string{"1:17: branches of if have different types: int and string"}},
+		{`[1, "a"]`, []string{`1:5: a has type string, want int in array literal [1, a]`}},
+		{`let a = [1]; a["x"]`, []string{`1:16: x has type string, want int in (a[x])`}},
+		{`5[0]`, []string{"1:1: cannot index 5 (type int)"}},
+		{`y + 1`, []string{"1:1: undefined: y"}},
+		{`let x: string = 5;`, []string{"1:17: 5 has type int, want string in let x: string = 5;"}},
+		{`let x: number = 5;`, []string{"1:8: unknown type: number"}},
+		{`let f = fn(n) { if (n > 0) { return "a"; } n };`, []string{"1:44: n has type int, want string in result of f"}},
+		{`let f = fn(h) { h["a"] + h[1] };`, []string{"1:28: 1 has type int, want string in (h[1])"}},
+		{`let x = 1; if (c) { let x = "a"; }`, []string{"1:16: undefined: c", "1:29: a has type string, want int in let x = a;"}},
+		{`match (1) { _ => 1 }`, []string{"1:1: type inference doesn't support match expressions"}},
+		{`let [a, b] = [1, 2];`, []string{"1:1: type inference doesn't support destructuring"}},
+		{`let f = fn(a, b = 1) { a + b };`, []string{"1:9: type inference doesn't support default and rest parameters"}},
+	}
+
+	for _, tc := range testCases {
+		_, errs := Infer(parse(t, tc.input))
+
+		got := make([]string, 0, len(errs))
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("wrong errors for %q\ngot=%q\nwant=%q", tc.input, got, tc.expected)
+		}
+	}
+}
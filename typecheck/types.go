@@ -0,0 +1,310 @@
+package typecheck
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a monotype, type variables bound by unification stand for the type they are bound to
+type Type interface {
+	typeNode()
+}
+
+// Variable is a type not known yet, allowed limits the constructors it can be bound to, like
+// the operands of + that must be ints or strings. A nil allowed accepts every type
+type Variable struct {
+	id       int
+	level    int // let nesting where the variable was created, deeper ones are generalized
+	instance Type
+	allowed  []string
+}
+
+// Constructor is a named type, int, string, bool and null have no arguments, array has the
+// type of its elements and hash the types of its keys and values
+type Constructor struct {
+	Name      string
+	Arguments []Type
+}
+
+type Function struct {
+	Parameters []Type
+	Result     Type
+}
+
+func (v *Variable) typeNode()    {}
+func (c *Constructor) typeNode() {}
+func (f *Function) typeNode()    {}
+
+func (v *Variable) String() string    { return newNamer().format(v) }
+func (c *Constructor) String() string { return newNamer().format(c) }
+func (f *Function) String() string    { return newNamer().format(f) }
+
+var (
+	Int    = &Constructor{Name: "int"}
+	String = &Constructor{Name: "string"}
+	Bool   = &Constructor{Name: "bool"}
+	Null   = &Constructor{Name: "null"}
+)
+
+func Array(element Type) *Constructor { return &Constructor{Name: "array", Arguments: []Type{element}} }
+
+func Hash(key, value Type) *Constructor {
+	return &Constructor{Name: "hash", Arguments: []Type{key, value}}
+}
+
+// Scheme is a polytype, the type of a let binding generalized over the variables that don't
+// depend on the enclosing scope
+type Scheme struct {
+	Variables []*Variable
+	Type      Type
+}
+
+// String names the variables a, b, c... in order of appearance, followed by the types the
+// constrained ones accept
+func (s *Scheme) String() string {
+	return newNamer().format(s.Type)
+}
+
+// prune returns the type t stands for, following the variables bound by unification
+func prune(t Type) Type {
+	v, ok := t.(*Variable)
+	if !ok || v.instance == nil {
+		return t
+	}
+
+	v.instance = prune(v.instance)
+	return v.instance
+}
+
+var errMismatch = errors.New("mismatched types")
+
+// infiniteTypeError is returned when a variable would be bound to a type containing it
+type infiniteTypeError struct {
+	variable *Variable
+	t        Type
+}
+
+func (e *infiniteTypeError) Error() string { return "infinite type" }
+
+func unify(a, b Type) error {
+	a, b = prune(a), prune(b)
+
+	if v, ok := a.(*Variable); ok {
+		return bind(v, b)
+	}
+	if v, ok := b.(*Variable); ok {
+		return bind(v, a)
+	}
+
+	switch a := a.(type) {
+	case *Constructor:
+		b, ok := b.(*Constructor)
+		if !ok || a.Name != b.Name || len(a.Arguments) != len(b.Arguments) {
+			return errMismatch
+		}
+		for i := range a.Arguments {
+			if err := unify(a.Arguments[i], b.Arguments[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Parameters) != len(b.Parameters) {
+			return errMismatch
+		}
+		for i := range a.Parameters {
+			if err := unify(a.Parameters[i], b.Parameters[i]); err != nil {
+				return err
+			}
+		}
+		return unify(a.Result, b.Result)
+	}
+
+	return errMismatch
+}
+
+func bind(v *Variable, t Type) error {
+	if other, ok := t.(*Variable); ok {
+		if other == v {
+			return nil
+		}
+
+		allowed := intersect(v.allowed, other.allowed)
+		if allowed != nil && len(allowed) == 0 {
+			return errMismatch
+		}
+		other.allowed = allowed
+		other.level = min(other.level, v.level)
+		v.instance = other
+		return nil
+	}
+
+	if occurs(v, t) {
+		return &infiniteTypeError{variable: v, t: t}
+	}
+
+	if v.allowed != nil && !contains(v.allowed, constructorName(t)) {
+		return errMismatch
+	}
+
+	adjustLevels(t, v.level)
+	v.instance = t
+	return nil
+}
+
+func occurs(v *Variable, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Variable:
+		return t == v
+	case *Constructor:
+		for _, arg := range t.Arguments {
+			if occurs(v, arg) {
+				return true
+			}
+		}
+	case *Function:
+		for _, param := range t.Parameters {
+			if occurs(v, param) {
+				return true
+			}
+		}
+		return occurs(v, t.Result)
+	}
+
+	return false
+}
+
+// adjustLevels keeps the variables of a type bound to a variable from being generalized
+// before that variable is
+func adjustLevels(t Type, level int) {
+	switch t := prune(t).(type) {
+	case *Variable:
+		t.level = min(t.level, level)
+	case *Constructor:
+		for _, arg := range t.Arguments {
+			adjustLevels(arg, level)
+		}
+	case *Function:
+		for _, param := range t.Parameters {
+			adjustLevels(param, level)
+		}
+		adjustLevels(t.Result, level)
+	}
+}
+
+func constructorName(t Type) string {
+	switch t := t.(type) {
+	case *Constructor:
+		return t.Name
+	case *Function:
+		return "fn"
+	}
+
+	return ""
+}
+
+// intersect returns the constructors allowed by both lists, nil allows every constructor
+func intersect(a, b []string) []string {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	both := []string{}
+	for _, name := range a {
+		if contains(b, name) {
+			both = append(both, name)
+		}
+	}
+
+	return both
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namer formats types naming their unbound variables a, b, c... in order of appearance, types
+// formatted by the same namer share the names
+type namer struct {
+	names map[*Variable]string
+	order []*Variable
+}
+
+func newNamer() *namer {
+	return &namer{names: map[*Variable]string{}}
+}
+
+func (n *namer) name(v *Variable) string {
+	if name, ok := n.names[v]; ok {
+		return name
+	}
+
+	i := len(n.order)
+	name := string(rune('a' + i%26))
+	if i >= 26 {
+		name += strconv.Itoa(i / 26)
+	}
+
+	n.names[v] = name
+	n.order = append(n.order, v)
+	return name
+}
+
+// format returns the type followed by the constructors accepted by its constrained variables
+func (n *namer) format(t Type) string {
+	out := n.typeString(t)
+
+	constraints := []string{}
+	for _, v := range n.order {
+		if v.allowed != nil && v.instance == nil {
+			constraints = append(constraints, n.names[v]+": "+allowedString(v.allowed))
+		}
+	}
+
+	if len(constraints) > 0 {
+		out += " where " + strings.Join(constraints, ", ")
+	}
+
+	return out
+}
+
+func (n *namer) typeString(t Type) string {
+	switch t := prune(t).(type) {
+	case *Variable:
+		return n.name(t)
+	case *Constructor:
+		switch t.Name {
+		case "array":
+			return "[" + n.typeString(t.Arguments[0]) + "]"
+		case "hash":
+			return "{" + n.typeString(t.Arguments[0]) + ": " + n.typeString(t.Arguments[1]) + "}"
+		}
+		return t.Name
+	case *Function:
+		params := make([]string, 0, len(t.Parameters))
+		for _, param := range t.Parameters {
+			params = append(params, n.typeString(param))
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + n.typeString(t.Result)
+	}
+
+	return "?"
+}
+
+func allowedString(allowed []string) string {
+	sorted := append([]string{}, allowed...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " | ")
+}
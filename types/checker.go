@@ -0,0 +1,677 @@
+package types
+
+import (
+	"fmt"
+
+	"go-interpreter.com/m/ast"
+	"go-interpreter.com/m/token"
+)
+
+// Error is a type error found in the source, positioned at the offending token
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+// scope holds the types of the names bound in a function, if blocks share the scope of the
+// function like they do when the program runs
+type scope struct {
+	names map[string]Type
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: map[string]Type{}, outer: outer}
+}
+
+// lookup returns Any for the names bound outside of the program, like builtins
+func (s *scope) lookup(name string) Type {
+	for ; s != nil; s = s.outer {
+		if t, ok := s.names[name]; ok {
+			return t
+		}
+	}
+
+	return Any
+}
+
+// function is the function whose body is being checked, result is nil when its result type
+// is inferred from the returned values
+type function struct {
+	result  Type
+	returns Type
+}
+
+type checker struct {
+	scope     *scope
+	functions []*function
+	errors    []error
+}
+
+// Check reports the operations of program that fail whatever values it runs with, like
+// "a" - 1, and the values that don't have the type of the annotation of their binding,
+// parameter or function result. Names bound outside of program are Any
+func Check(program *ast.Program) []error {
+	c := &checker{scope: newScope(nil)}
+	c.checkStatements(program.Statements)
+	return c.errors
+}
+
+// checkStatements returns the type of the value of the last statement
+func (c *checker) checkStatements(statements []ast.Statement) Type {
+	var result Type = Null
+	for _, s := range statements {
+		result = c.checkStatement(s)
+	}
+
+	return result
+}
+
+// checkStatement returns the type of the value of s, nil when it returns from the function
+func (c *checker) checkStatement(s ast.Statement) Type {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.checkLet(s)
+		return Null
+	case *ast.ReturnStatement:
+		c.checkReturn(s.Value, c.checkExpression(s.Value))
+		return nil
+	case *ast.ThrowStatement:
+		c.checkExpression(s.Value)
+		return nil
+	case *ast.ExpressionStatement:
+		return c.checkExpression(s.Expression)
+	case *ast.BlockStatement:
+		return c.checkStatements(s.Statements)
+	}
+
+	return Any
+}
+
+func (c *checker) checkLet(s *ast.LetStatement) {
+	var declared Type
+	if s.Type != nil {
+		declared = c.annotation(s.Type)
+	}
+
+	// bound before the body is checked so the function can call itself
+	if fn, ok := s.Value.(*ast.FunctionExpression); ok && s.Name != nil {
+		if declared != nil {
+			c.scope.names[s.Name.Value] = declared
+		} else {
+			c.scope.names[s.Name.Value] = signature(fn)
+		}
+	}
+
+	var t Type
+	if declared != nil {
+		t = c.checkAs(s.Value, declared)
+	} else {
+		t = c.checkExpression(s.Value)
+	}
+	if declared != nil {
+		if !Assignable(declared, t) {
+			name := ""
+			if s.Name != nil {
+				name = s.Name.Value
+			} else {
+				name = s.Pattern.String()
+			}
+			c.errorf(ast.Position(s.Value), "cannot use %s as %s in let %s", t, declared, name)
+		}
+		t = declared
+	}
+
+	if s.Pattern != nil {
+		c.bindPattern(s.Pattern, t)
+		return
+	}
+	c.scope.names[s.Name.Value] = t
+}
+
+func (c *checker) checkReturn(value ast.Node, t Type) {
+	if len(c.functions) == 0 {
+		return
+	}
+
+	fn := c.functions[len(c.functions)-1]
+	if fn.result == nil {
+		fn.returns = join(fn.returns, t)
+		return
+	}
+
+	if t != nil && !Assignable(fn.result, t) {
+		c.errorf(ast.Position(value), "cannot return %s from a function returning %s", t, fn.result)
+	}
+}
+
+// bindPattern binds the names of pattern to the types of the parts of a value of type t
+func (c *checker) bindPattern(pattern ast.Pattern, t Type) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		c.scope.names[pattern.Value] = t
+	case *ast.ArrayPattern:
+		element, rest := Type(Any), Type(Any)
+		if array, ok := t.(*Array); ok {
+			element, rest = array.Element, array
+		}
+		for _, el := range pattern.Elements {
+			c.bindPattern(el, element)
+		}
+		if pattern.Rest != nil {
+			c.bindPattern(pattern.Rest, rest)
+		}
+	case *ast.HashPattern:
+		value := Type(Any)
+		if hash, ok := t.(*Hash); ok {
+			value = hash.Value
+		}
+		for _, v := range pattern.Values {
+			c.bindPattern(v, value)
+		}
+	}
+}
+
+func (c *checker) checkExpression(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		return c.scope.lookup(e.Value)
+	case *ast.PrefixExpression:
+		return c.checkPrefix(e)
+	case *ast.InfixExpression:
+		return c.checkInfix(e)
+	case *ast.IfExpression:
+		return c.checkIf(e)
+	case *ast.FunctionExpression:
+		return c.checkFunction(e)
+	case *ast.CallExpression:
+		return c.checkCall(e)
+	case *ast.ArrayLiteral:
+		var element Type
+		for _, el := range e.Elements {
+			element = join(element, c.checkExpression(el))
+		}
+		if element == nil {
+			element = Any
+		}
+		return &Array{Element: element}
+	case *ast.HashLiteral:
+		return c.checkHash(e)
+	case *ast.IndexExpression:
+		return c.checkIndex(e)
+	case *ast.SpreadExpression:
+		t := c.checkExpression(e.Value)
+		if _, ok := t.(*Array); !ok && t != Any {
+			c.errorf(ast.Position(e.Value), "cannot spread %s", t)
+		}
+		return t
+	case *ast.NamedArgument:
+		return c.checkExpression(e.Value)
+	case *ast.SpawnExpression:
+		c.checkExpression(e.Function)
+	case *ast.MatchExpression:
+		return c.checkMatch(e)
+	case *ast.TryExpression:
+		return c.checkTry(e)
+	}
+
+	return Any
+}
+
+func (c *checker) checkPrefix(e *ast.PrefixExpression) Type {
+	right := c.checkExpression(e.Right)
+
+	switch e.Operator {
+	case "!":
+		return Bool
+	case "-":
+		if !Assignable(Int, right) {
+			c.errorf(e.Token, "invalid operation: -%s", right)
+		}
+		return Int
+	}
+
+	return Any
+}
+
+// checkInfix follows the operators supported by the evaluator, the operands of an operator
+// are only checked when both types are known
+func (c *checker) checkInfix(e *ast.InfixExpression) Type {
+	left, right := c.checkExpression(e.Left), c.checkExpression(e.Right)
+	known := left != Any && right != Any
+
+	switch e.Operator {
+	case "+":
+		switch {
+		case left == Any && (right == Int || right == String):
+			return right
+		case right == Any && (left == Int || left == String):
+			return left
+		case known && left == right && (left == Int || left == String):
+			return left
+		}
+		if known {
+			c.invalidOperation(e, left, right)
+		}
+		return Any
+	case "-", "*", "/":
+		if known && (left != Int || right != Int) {
+			c.invalidOperation(e, left, right)
+		}
+		return Int
+	case "<", ">":
+		if known && (left != Int || right != Int) {
+			c.invalidOperation(e, left, right)
+		}
+		return Bool
+	case "==", "!=":
+		if known && (left != right || left != Int && left != String && left != Bool) {
+			c.invalidOperation(e, left, right)
+		}
+		return Bool
+	}
+
+	return Any
+}
+
+func (c *checker) invalidOperation(e *ast.InfixExpression, left, right Type) {
+	c.errorf(e.Token, "invalid operation: %s %s %s", left, e.Operator, right)
+}
+
+func (c *checker) checkIf(e *ast.IfExpression) Type {
+	c.checkExpression(e.Condition)
+
+	before := c.scope.names
+	c.scope.names = copyNames(before)
+	consequence := c.checkStatement(e.Consequence)
+	afterConsequence := c.scope.names
+
+	alternative := Type(Null)
+	c.scope.names = copyNames(before)
+	if e.Alternative != nil {
+		alternative = c.checkStatement(e.Alternative)
+	}
+
+	c.scope.names = mergeNames(afterConsequence, c.scope.names)
+	return join(consequence, alternative)
+}
+
+func (c *checker) checkMatch(e *ast.MatchExpression) Type {
+	value := c.checkExpression(e.Value)
+
+	// the names bound by an arm are only visible in the arm
+	before := c.scope.names
+	var result Type
+	for _, arm := range e.Arms {
+		c.scope.names = copyNames(before)
+		c.bindPattern(arm.Pattern, value)
+		if arm.Guard != nil {
+			c.checkExpression(arm.Guard)
+		}
+		result = join(result, c.checkExpression(arm.Body))
+	}
+
+	c.scope.names = before
+	if result == nil {
+		return Any
+	}
+	return result
+}
+
+// checkTry merges the names bound by the try block like the branches of an if, the block may
+// fail before binding them. The names bound by the catch block are only visible in it, caught
+// errors are hashes of their fields
+func (c *checker) checkTry(e *ast.TryExpression) Type {
+	before := c.scope.names
+	c.scope.names = copyNames(before)
+	result := c.checkStatement(e.Block)
+	merged := mergeNames(before, c.scope.names)
+
+	if e.CatchBlock != nil {
+		c.scope.names = copyNames(merged)
+		c.scope.names[e.Catch.Value] = &Hash{Key: String, Value: Any}
+		result = join(result, c.checkStatement(e.CatchBlock))
+	}
+
+	c.scope.names = merged
+	if e.Finally != nil {
+		c.checkStatement(e.Finally)
+	}
+
+	if result == nil {
+		return Any
+	}
+	return result
+}
+
+// copyNames lets a branch bind names without changing the types seen by the other branches
+func copyNames(names map[string]Type) map[string]Type {
+	copied := make(map[string]Type, len(names))
+	for name, t := range names {
+		copied[name] = t
+	}
+
+	return copied
+}
+
+// mergeNames returns the types of the names after either branch ran, names bound to
+// different types by the branches, or only by one of them, are Any
+func mergeNames(a, b map[string]Type) map[string]Type {
+	merged := make(map[string]Type, len(a))
+	for name, t := range a {
+		if other, ok := b[name]; ok && t.String() == other.String() {
+			merged[name] = t
+		} else {
+			merged[name] = Any
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			merged[name] = Any
+		}
+	}
+
+	return merged
+}
+
+func (c *checker) checkFunction(fn *ast.FunctionExpression) Type {
+	for i, annotation := range fn.ParameterTypes {
+		if annotation == nil {
+			continue
+		}
+		t := c.annotation(annotation)
+		if _, ok := t.(*Array); fn.Variadic && i == len(fn.Parameters)-1 && !ok && t != Any {
+			c.errorf(ast.Position(fn.Parameters[i]), "rest parameter %s must be an array, got %s", fn.Parameters[i].Value, t)
+		}
+	}
+
+	sig := signature(fn)
+	f := &function{}
+	if fn.ReturnType != nil {
+		f.result = c.annotation(fn.ReturnType)
+	}
+
+	outer := c.scope
+	c.scope = newScope(outer)
+	for i, param := range fn.Parameters {
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			if t := c.checkExpression(fn.Defaults[i]); !Assignable(sig.Parameters[i], t) {
+				c.errorf(ast.Position(fn.Defaults[i]), "cannot use %s as %s in default value of %s", t, sig.Parameters[i], param.Value)
+			}
+		}
+		c.scope.names[param.Value] = sig.Parameters[i]
+	}
+
+	c.functions = append(c.functions, f)
+	last := c.checkStatements(fn.Body.Statements)
+	c.functions = c.functions[:len(c.functions)-1]
+	c.scope = outer
+
+	if f.result != nil {
+		if last != nil && !Assignable(f.result, last) {
+			var lastNode ast.Node = fn
+			if n := len(fn.Body.Statements); n > 0 {
+				lastNode = fn.Body.Statements[n-1]
+			}
+			c.errorf(ast.Position(lastNode), "cannot return %s from a function returning %s", last, f.result)
+		}
+		sig.Result = f.result
+		return sig
+	}
+
+	if result := join(f.returns, last); result != nil {
+		sig.Result = result
+	}
+	return sig
+}
+
+func (c *checker) checkCall(call *ast.CallExpression) Type {
+	// quoted code is data
+	if ast.IsCallTo(call, "quote") {
+		return Any
+	}
+
+	callee := c.checkExpression(call.Function)
+	// the position of the arguments is unknown from the first spread array on, only the
+	// arguments before it are checked. The arguments passed by name follow the others
+	args := make([]Type, 0, len(call.Arguments))
+	var named []*ast.NamedArgument
+	var namedTypes []Type
+	spread := false
+	for _, arg := range call.Arguments {
+		t := c.checkExpression(arg)
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			spread = true
+		case *ast.NamedArgument:
+			named, namedTypes = append(named, arg), append(namedTypes, t)
+			continue
+		}
+		if !spread {
+			args = append(args, t)
+		}
+	}
+
+	switch fn := callee.(type) {
+	case *Function:
+		// without the parameter names, the arguments passed by name could go anywhere
+		partial := spread || named != nil && fn.Names == nil
+		if min, max := fn.Arity(); !spread && named == nil && !fn.accepts(len(args)) || max >= 0 && len(args) > max {
+			c.errorf(ast.Position(call.Function), "wrong number of arguments to %s: want=%s, got=%d",
+				call.Function.String(), arity(min, max), len(args))
+			return fn.Result
+		}
+		for i, arg := range args {
+			if !Assignable(fn.Argument(i), arg) {
+				c.errorf(ast.Position(call.Arguments[i]), "cannot use %s as %s in argument %d of %s",
+					arg, fn.Argument(i), i+1, call.Function.String())
+			}
+		}
+		if fn.Names != nil {
+			c.checkNamedArguments(call, fn, len(args), named, namedTypes, partial)
+		}
+		return fn.Result
+	case *Basic:
+		if fn != Any {
+			c.errorf(ast.Position(call.Function), "cannot call non-function %s (type %s)", call.Function.String(), fn)
+		}
+	default:
+		c.errorf(ast.Position(call.Function), "cannot call non-function %s (type %s)", call.Function.String(), fn)
+	}
+
+	return Any
+}
+
+// checkNamedArguments checks the arguments passed by name to fn against the names and the
+// types of its parameters, the first positional parameters got positional arguments. When
+// the number of positional arguments is unknown only the names and the types are checked
+func (c *checker) checkNamedArguments(call *ast.CallExpression, fn *Function, positional int,
+	named []*ast.NamedArgument, namedTypes []Type, partial bool) {
+	passed := make(map[int]bool, len(named))
+	for j, arg := range named {
+		i := fn.Parameter(arg.Name)
+		switch {
+		case i < 0:
+			c.errorf(arg.Token, "unknown argument %s to %s", arg.Name, call.Function.String())
+			continue
+		case !partial && i < positional:
+			c.errorf(arg.Token, "argument %s to %s passed twice", arg.Name, call.Function.String())
+			continue
+		}
+		passed[i] = true
+
+		if !Assignable(fn.Parameters[i], namedTypes[j]) {
+			c.errorf(ast.Position(arg.Value), "cannot use %s as %s in argument %s of %s",
+				namedTypes[j], fn.Parameters[i], arg.Name, call.Function.String())
+		}
+	}
+
+	if partial {
+		return
+	}
+	min, _ := fn.Arity()
+	for i := positional; i < min; i++ {
+		if !passed[i] {
+			c.errorf(ast.Position(call.Function), "missing argument %s to %s", fn.Names[i], call.Function.String())
+		}
+	}
+}
+
+// arity describes the number of arguments a function accepts for error messages
+func arity(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("%d..", min)
+	case min == max:
+		return fmt.Sprint(min)
+	}
+
+	return fmt.Sprintf("%d..%d", min, max)
+}
+
+// checkAs checks an expression whose type is declared. The elements of array and hash
+// literals are checked one by one, the type of a literal mixing types would be [any]
+func (c *checker) checkAs(e ast.Expression, declared Type) Type {
+	switch e := e.(type) {
+	case *ast.ArrayLiteral:
+		array, ok := declared.(*Array)
+		if !ok {
+			break
+		}
+		for i, el := range e.Elements {
+			if t := c.checkAs(el, array.Element); !Assignable(array.Element, t) {
+				c.errorf(ast.Position(el), "cannot use %s as %s in array element %d", t, array.Element, i)
+			}
+		}
+		return declared
+	case *ast.HashLiteral:
+		hash, ok := declared.(*Hash)
+		if !ok {
+			break
+		}
+		for i, keyNode := range e.Keys {
+			if k := c.checkAs(keyNode, hash.Key); !hashable(k) {
+				c.errorf(ast.Position(keyNode), "unusable as hash key: %s", k)
+			} else if !Assignable(hash.Key, k) {
+				c.errorf(ast.Position(keyNode), "cannot use %s as %s in hash key", k, hash.Key)
+			}
+			if v := c.checkAs(e.Values[i], hash.Value); !Assignable(hash.Value, v) {
+				c.errorf(ast.Position(e.Values[i]), "cannot use %s as %s in hash value", v, hash.Value)
+			}
+		}
+		return declared
+	}
+
+	return c.checkExpression(e)
+}
+
+func (c *checker) checkHash(e *ast.HashLiteral) Type {
+	var key, value Type
+	for i, keyNode := range e.Keys {
+		k := c.checkExpression(keyNode)
+		if !hashable(k) {
+			c.errorf(ast.Position(keyNode), "unusable as hash key: %s", k)
+		}
+		key = join(key, k)
+		value = join(value, c.checkExpression(e.Values[i]))
+	}
+
+	if key == nil {
+		key, value = Any, Any
+	}
+	return &Hash{Key: key, Value: value}
+}
+
+// checkIndex reports the indexes that fail when the program runs, indexes out of range and
+// missing keys evaluate to null
+func (c *checker) checkIndex(e *ast.IndexExpression) Type {
+	left, index := c.checkExpression(e.Left), c.checkExpression(e.Index)
+
+	switch l := left.(type) {
+	case *Array:
+		if !Assignable(Int, index) {
+			c.errorf(ast.Position(e.Index), "cannot index %s with %s", left, index)
+		}
+		return l.Element
+	case *Hash:
+		if !hashable(index) {
+			c.errorf(ast.Position(e.Index), "unusable as hash key: %s", index)
+		}
+		if !Assignable(l.Key, index) {
+			return Any
+		}
+		return l.Value
+	}
+
+	if left != Any {
+		c.errorf(ast.Position(e.Left), "cannot index %s", left)
+	}
+	return Any
+}
+
+func hashable(t Type) bool {
+	return t == Any || t == Int || t == String || t == Bool
+}
+
+// annotation returns the type written by a, unknown types are reported and checked as Any
+func (c *checker) annotation(a ast.TypeAnnotation) Type {
+	t, err := FromAnnotation(a)
+	if err != nil {
+		c.errors = append(c.errors, err)
+		return Any
+	}
+
+	return t
+}
+
+// signature is the type of fn according to its annotations, parameters and results that
+// aren't annotated are Any
+func signature(fn *ast.FunctionExpression) *Function {
+	sig := &Function{Parameters: make([]Type, 0, len(fn.Parameters)), Result: Any, Variadic: fn.Variadic}
+	sig.Names = make([]string, 0, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		sig.Names = append(sig.Names, param.Value)
+		t := Type(Any)
+		if i < len(fn.ParameterTypes) && fn.ParameterTypes[i] != nil {
+			if annotated, err := FromAnnotation(fn.ParameterTypes[i]); err == nil {
+				t = annotated
+			}
+		}
+		if fn.Variadic && i == len(fn.Parameters)-1 {
+			if _, ok := t.(*Array); !ok {
+				t = &Array{Element: Any}
+			}
+		}
+		sig.Parameters = append(sig.Parameters, t)
+	}
+
+	for i := len(fn.Defaults) - 1; i >= 0; i-- {
+		if fn.Defaults[i] != nil {
+			sig.Optional++
+		} else if !fn.Variadic || i != len(fn.Defaults)-1 {
+			break
+		}
+	}
+
+	if fn.ReturnType != nil {
+		if result, err := FromAnnotation(fn.ReturnType); err == nil {
+			sig.Result = result
+		}
+	}
+
+	return sig
+}
+
+func (c *checker) errorf(tok token.Token, format string, args ...interface{}) {
+	c.errors = append(c.errors, &Error{Token: tok, Message: fmt.Sprintf(format, args...)})
+}
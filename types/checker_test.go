@@ -0,0 +1,132 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/parser"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`"a" - 1`, []string{"1:5: invalid operation: string - int"}},
+		{`1 + "a"`, []string{"1:3: invalid operation: int + string"}},
+		{`"a" + "b"; 1 + 2 * 3; -5; !true`, nil},
+		{`-"a"`, []string{"1:1: invalid operation: -string"}},
+		{`1 == true`, []string{"1:3: invalid operation: int == bool"}},
+		{`[1] == [1]`, []string{"1:5: invalid operation: [int] == [int]"}},
+		{`let x = "a"; let y = x; y * 2`, []string{"1:27: invalid operation: string * int"}},
+		{`let x: int = 5; x + 1`, nil},
+		{`let x: int = "5";`, []string{`1:14: cannot use string as int in let x`}},
+		{`let x: [int] = [1, 2]; let y: [string] = x;`, []string{"1:42: cannot use [int] as [string] in let y"}},
+		{`let h: {string: int} = {"a": 1}; h["a"] - 1`, nil},
+		{`let x: number = 5;`, []string{"1:8: unknown type: number"}},
+		{`let f = fn(a: int, b: string) -> bool { a > 0 }; f(1, "b")`, nil},
+		{`let f = fn(a: int) { a }; f("1")`, []string{`1:29: cannot use string as int in argument 1 of f`}},
+		{`let f = fn(a: int) { a }; f(1, 2)`, []string{"1:27: wrong number of arguments to f: want=1, got=2"}},
+		{`let f = fn(a: int) { a }; f(1) + "a"`, []string{"1:32: invalid operation: int + string"}},
+		{`let f = fn() -> int { "a" }`, []string{"1:23: cannot return string from a function returning int"}},
+		{`let f = fn(n: int) -> int { if (n > 0) { return "a" } n }`, []string{"1:49: cannot return string from a function returning int"}},
+		{`let f = fn(n: int) -> int { if (n > 0) { return 1 } else { return 2 } }`, nil},
+		{`let fib = fn(n: int) -> int { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; fib("a")`, []string{`1:86: cannot use string as int in argument 1 of fib`}},
+		{`let x = 5; x()`, []string{"1:12: cannot call non-function x (type int)"}},
+		{`let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(x) { x * 2 }, 1)`, nil},
+		{`let apply = fn(f: fn(int) -> int) { f(1) }; apply(5)`, []string{"1:51: cannot use int as fn(int) -> int in argument 1 of apply"}},
+		{`let a = [1, 2]; a["x"]`, []string{"1:19: cannot index [int] with string"}},
+		{`5[0]`, []string{"1:1: cannot index int"}},
+		{`{[1]: 2}`, []string{"1:2: unusable as hash key: [int]"}},
+		{`let [a, b]: [int] = [1, 2]; a + "b"`, []string{"1:31: invalid operation: int + string"}},
+		{`let f = fn([a, b]: [string]) { a - b }`, []string{"1:34: invalid operation: string - string"}},
+		{`match (1) { n => n + "a" }`, []string{"1:20: invalid operation: int + string"}},
+		{`let e = "a"; try { 1 } catch (e) { 0 }; e - 1`, []string{"1:43: invalid operation: string - int"}},
+		{`let n = "a"; match (1) { n => n }; n - 1`, []string{"1:38: invalid operation: string - int"}},
+		// unknown names and values only known at runtime aren't checked
+		{`unknown - 1; len("a") + "b"; let f = fn(x) { x - 1 }; f("a")`, nil},
+		{`let x = 1; if (c) { let x = "a"; } x - 1`, nil},
+		{`let x = 1; if (c) { let x = 2; } else { let x = 3; } x - 1`, nil},
+		{`let x = 1; if (c) { let x = "a"; } else { let x = "b"; } x - 1`, []string{"1:60: invalid operation: string - int"}},
+		{`let a = [1, "a"]; a[0] - 1`, nil},
+		{`let x: [int] = [1, "a"]`, []string{`1:20: cannot use string as int in array element 1`}},
+		{`let x: [[int]] = [[1], ["a"]]`, []string{`1:25: cannot use string as int in array element 0`}},
+		{`let h: {string: int} = {"a": 1, "b": "c", 2: 3}`, []string{
+			`1:38: cannot use string as int in hash value`,
+			`1:43: cannot use int as string in hash key`,
+		}},
+		{`let x: [any] = [1, "a"]`, nil},
+		{`let f = fn(x) { if (x) { 1 } }; f(true) - 1`, nil},
+		{`let x = try { 1 } catch (e) { 2 }; x + "a"`, []string{"1:38: invalid operation: int + string"}},
+		{`let x = try { f() } catch (e) { e["message"] }; x - 1`, nil},
+		{`try { 1 } catch (e) { e["message"] - 1 }`, nil},
+		{`let f = fn(a: int, b: int = 2) { a + b }; f(1); f(1, 2); f(1, 2, 3)`, []string{"1:58: wrong number of arguments to f: want=1..2, got=3"}},
+		{`let f = fn(a: int = "x") { a }`, []string{`1:21: cannot use string as int in default value of a`}},
+		{`let f = fn(...xs: [int]) { xs }; f(1, 2, "3")`, []string{`1:42: cannot use string as int in argument 3 of f`}},
+		{`let f = fn(a, ...xs) { xs }; f()`, []string{"1:30: wrong number of arguments to f: want=1.., got=0"}},
+		{`let f = fn(...xs: int) { xs }`, []string{"1:15: rest parameter xs must be an array, got int"}},
+		{`let f = fn(a: int, b: int) { a }; f(...[1, 2]); f(...5)`, []string{"1:54: cannot spread int"}},
+		{`let f = fn(a: int) { a }; f(1, 2, ...[3])`, []string{"1:27: wrong number of arguments to f: want=1, got=2"}},
+		{`let f = fn(a: int, b: int = 1) { a }; f("x", b: 2 - "y")`, []string{
+			`1:51: invalid operation: int - string`,
+			`1:41: cannot use string as int in argument 1 of f`,
+		}},
+		{`let f = fn(x: int, y: int = 0) { x + y }; f(1, y: "s")`, []string{`1:51: cannot use string as int in argument y of f`}},
+		{`let f = fn(x: int, y: int = 0) { x + y }; f(y: 2, x: 1); f(1, y: 2)`, nil},
+		{`let f = fn(x: int, y: int = 0) { x + y }; f(1, z: 2)`, []string{"1:48: unknown argument z to f"}},
+		{`let f = fn(x: int, y: int = 0) { x + y }; f(1, x: 2)`, []string{"1:48: argument x to f passed twice"}},
+		{`let f = fn(x: int, y: int) { x + y }; f(y: 2)`, []string{"1:39: missing argument x to f"}},
+		{`let f = fn(x: int, ...xs: [int]) { x }; f(1, xs: [2])`, []string{"1:46: unknown argument xs to f"}},
+		{`let f = fn(x: int, y: int = 0) { x + y }; f(...[1], y: "s")`, []string{`1:56: cannot use string as int in argument y of f`}},
+		{`let apply = fn(f: fn(int, int) -> int) { f(1, y: "s") }`, nil},
+	}
+
+	for _, tc := range testCases {
+		p := parser.New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		if len(p.Errors) != 0 {
+			t.Fatalf("parse errors for %q: %v", tc.input, p.Errors)
+		}
+
+		messages := []string{}
+		for _, err := range Check(program) {
+			messages = append(messages, err.Error())
+		}
+
+		if strings.Join(messages, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot=%q", tc.input, tc.expected, messages)
+		}
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	intToInt := &Function{Parameters: []Type{Int}, Result: Int}
+
+	testCases := []struct {
+		to, from Type
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, String, false},
+		{Int, Any, true},
+		{Any, String, true},
+		{&Array{Element: Int}, &Array{Element: Any}, true},
+		{&Array{Element: Int}, &Array{Element: String}, false},
+		{&Hash{Key: String, Value: Int}, &Hash{Key: String, Value: Bool}, false},
+		{intToInt, &Function{Parameters: []Type{Any}, Result: Any}, true},
+		{intToInt, &Function{Parameters: []Type{Int, Int}, Result: Int}, false},
+		{intToInt, &Function{Parameters: []Type{String}, Result: Int}, false},
+		{intToInt, Int, false},
+		{intToInt, &Function{Parameters: []Type{Int, Int}, Result: Int, Optional: 1}, true},
+		{intToInt, &Function{Parameters: []Type{&Array{Element: Int}}, Result: Int, Variadic: true}, true},
+		{&Function{Parameters: []Type{Int, Int}, Result: Int, Optional: 1}, intToInt, false},
+		{&Function{Parameters: []Type{&Array{Element: Int}}, Result: Int, Variadic: true}, intToInt, false},
+	}
+
+	for _, tc := range testCases {
+		if got := Assignable(tc.to, tc.from); got != tc.expected {
+			t.Errorf("Assignable(%s, %s) = %t, want %t", tc.to, tc.from, got, tc.expected)
+		}
+	}
+}
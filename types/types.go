@@ -0,0 +1,222 @@
+// Package types checks the type annotations of a program before it runs. Types of unannotated
+// bindings are inferred from their values when possible, everything else is Any and is only
+// checked when the program runs
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"go-interpreter.com/m/ast"
+)
+
+// Type is the static type of an expression
+type Type interface {
+	String() string
+}
+
+// Basic is a type without components, like int
+type Basic struct {
+	name string
+}
+
+func (b *Basic) String() string { return b.name }
+
+var (
+	Int    = &Basic{"int"}
+	String = &Basic{"string"}
+	Bool   = &Basic{"bool"}
+	Null   = &Basic{"null"}
+
+	// Any is the type of the values only known when the program runs, it's compatible with
+	// every other type
+	Any = &Basic{"any"}
+)
+
+var basicTypes = map[string]Type{"int": Int, "string": String, "bool": Bool, "null": Null, "any": Any}
+
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+// Function is the type of functions, the last Optional parameters have default values and
+// the last parameter of a Variadic function is the array of the remaining arguments.
+// Names are the names of the parameters when they're known, for the arguments passed by name
+type Function struct {
+	Parameters []Type
+	Names      []string
+	Result     Type
+	Optional   int
+	Variadic   bool
+}
+
+func (f *Function) String() string {
+	params := make([]string, 0, len(f.Parameters))
+	min, _ := f.Arity()
+	for i, param := range f.Parameters {
+		switch {
+		case f.Variadic && i == len(f.Parameters)-1:
+			params = append(params, "..."+param.String())
+		case i >= min:
+			params = append(params, param.String()+"?")
+		default:
+			params = append(params, param.String())
+		}
+	}
+
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Result.String()
+}
+
+// Arity returns the number of arguments the function requires and the number it accepts,
+// -1 when it's variadic
+func (f *Function) Arity() (min, max int) {
+	min, max = len(f.Parameters), len(f.Parameters)
+	if f.Variadic {
+		min, max = min-1, -1
+	}
+
+	return min - f.Optional, max
+}
+
+// Argument returns the type of the argument at index i of a call, the arguments collected
+// by the rest parameter have the type of its elements
+func (f *Function) Argument(i int) Type {
+	if f.Variadic && i >= len(f.Parameters)-1 {
+		if rest, ok := f.Parameters[len(f.Parameters)-1].(*Array); ok {
+			return rest.Element
+		}
+		return Any
+	}
+
+	return f.Parameters[i]
+}
+
+// Parameter returns the index of the parameter called name that can be passed by name, or
+// -1 when there's none or the names are unknown. The rest parameter can't be passed by name
+func (f *Function) Parameter(name string) int {
+	fixed := len(f.Names)
+	if f.Variadic {
+		fixed--
+	}
+	for i := 0; i < fixed; i++ {
+		if f.Names[i] == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// accepts reports whether the function can be called with n arguments
+func (f *Function) accepts(n int) bool {
+	min, max := f.Arity()
+	return n >= min && (max < 0 || n <= max)
+}
+
+// Assignable reports whether a value of type from can be used where a value of type to is
+// expected, Any is assignable to and from every type
+func Assignable(to, from Type) bool {
+	if to == Any || from == Any {
+		return true
+	}
+
+	switch to := to.(type) {
+	case *Basic:
+		return to == from
+	case *Array:
+		from, ok := from.(*Array)
+		return ok && Assignable(to.Element, from.Element)
+	case *Hash:
+		from, ok := from.(*Hash)
+		return ok && Assignable(to.Key, from.Key) && Assignable(to.Value, from.Value)
+	case *Function:
+		// from must accept every call to a function of type to
+		from, ok := from.(*Function)
+		if !ok {
+			return false
+		}
+		toMin, toMax := to.Arity()
+		fromMin, fromMax := from.Arity()
+		if fromMin > toMin || (fromMax >= 0 && (toMax < 0 || toMax > fromMax)) {
+			return false
+		}
+		for i := 0; i < len(to.Parameters) || i < len(from.Parameters); i++ {
+			if to.accepts(i+1) && !Assignable(from.Argument(i), to.Argument(i)) {
+				return false
+			}
+		}
+		return Assignable(to.Result, from.Result)
+	}
+
+	return false
+}
+
+// join is the type of a value that has either type a or type b, nil stands for no value, like
+// the one of a block that always returns
+func join(a, b Type) Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.String() == b.String():
+		return a
+	}
+
+	return Any
+}
+
+// FromAnnotation returns the type written by annotation
+func FromAnnotation(annotation ast.TypeAnnotation) (Type, error) {
+	switch annotation := annotation.(type) {
+	case *ast.NamedType:
+		if t, ok := basicTypes[annotation.Name]; ok {
+			return t, nil
+		}
+		return nil, &Error{Token: annotation.Token, Message: fmt.Sprintf("unknown type: %s", annotation.Name)}
+	case *ast.ArrayType:
+		element, err := FromAnnotation(annotation.Element)
+		if err != nil {
+			return nil, err
+		}
+		return &Array{Element: element}, nil
+	case *ast.HashType:
+		key, err := FromAnnotation(annotation.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := FromAnnotation(annotation.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Hash{Key: key, Value: value}, nil
+	case *ast.FunctionType:
+		fn := &Function{Parameters: make([]Type, 0, len(annotation.Parameters)), Result: Any}
+		for _, param := range annotation.Parameters {
+			t, err := FromAnnotation(param)
+			if err != nil {
+				return nil, err
+			}
+			fn.Parameters = append(fn.Parameters, t)
+		}
+		if annotation.Result != nil {
+			result, err := FromAnnotation(annotation.Result)
+			if err != nil {
+				return nil, err
+			}
+			fn.Result = result
+		}
+		return fn, nil
+	}
+
+	return Any, nil
+}
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/object"
+)
+
+// Frame is a function call, locals live outside the stack so closures can keep referring
+// to them after the call returns
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+	locals      []object.Object
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+		locals:      make([]object.Object, cl.Fn.NumLocals),
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,541 @@
+// Package vm executes the bytecode produced by the compiler with a value stack and call frames,
+// following the semantics of the evaluator
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"go-interpreter.com/m/code"
+	"go-interpreter.com/m/compiler"
+	"go-interpreter.com/m/object"
+)
+
+const (
+	StackSize   = 2048 // initial size, the stack grows with deep recursion
+	GlobalsSize = 65536
+
+	// cancelCheckInterval is the number of executed instructions between checks of the context
+	cancelCheckInterval = 1024
+)
+
+var (
+	True  = object.TRUE
+	False = object.FALSE
+	Null  = object.NULL
+)
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	limits      *object.Limits
+
+	stack []object.Object
+	sp    int // Always points to the next free slot, the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	steps int
+	ctx   context.Context // of the running RunContext, it interrupts the builtins that block
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithLimits(bytecode, &object.Limits{MaxCallDepth: object.DefaultMaxCallDepth})
+}
+
+// NewWithLimits creates a vm bounded by limits, MaxSteps counts executed instructions
+func NewWithLimits(bytecode *compiler.Bytecode, limits *object.Limits) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Lines: bytecode.Lines}
+	mainFrame := NewFrame(&object.Closure{Fn: mainFn}, 0)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+		limits:      limits,
+		stack:       make([]object.Object, StackSize),
+		frames:      []*Frame{mainFrame},
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore creates a vm that keeps the globals of previous runs, as a REPL needs
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = globals
+	return vm
+}
+
+// LastPoppedStackElem returns the value of the last expression statement executed, or the
+// value returned by a return statement of the program
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Line returns the source line of the instruction being executed, after a runtime error
+// it's the line of the instruction that failed. It's 0 when the bytecode has no line table
+func (vm *VM) Line() int {
+	frame := vm.currentFrame()
+	return frame.cl.Fn.Lines.Line(frame.ip)
+}
+
+func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext executes the bytecode, runtime errors are returned as *object.Error
+func (vm *VM) RunContext(ctx context.Context) error {
+	ctx, cancel := object.WithScheduler(ctx)
+	defer cancel()
+
+	vm.ctx = ctx
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		if vm.steps%cancelCheckInterval == 0 {
+			select {
+			case <-ctx.Done():
+				return object.NewInterruptedError(ctx.Err())
+			default:
+			}
+		}
+
+		vm.steps++
+		if err := vm.limits.CheckSteps(vm.steps); err != nil {
+			return err
+		}
+
+		vm.currentFrame().ip++
+
+		ip := vm.currentFrame().ip
+		ins := vm.currentFrame().Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err error
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[constIndex])
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			err = vm.executeBinaryOperation(op)
+		case code.OpTrue:
+			err = vm.push(True)
+		case code.OpFalse:
+			err = vm.push(False)
+		case code.OpNull:
+			err = vm.push(Null)
+		case code.OpBang:
+			err = vm.push(negate(vm.pop()))
+		case code.OpMinus:
+			err = vm.executeMinusOperator()
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if !isTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.pushVariable(vm.globals[globalIndex], vm.globalNames, int(globalIndex))
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.currentFrame().locals[localIndex] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			err = vm.pushVariable(frame.locals[localIndex], frame.cl.Fn.LocalNames, int(localIndex))
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			cl := vm.currentFrame().cl
+			err = vm.pushVariable(cl.Free[freeIndex].Get(), cl.Fn.FreeNames, int(freeIndex))
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			err = vm.buildArray(numElements)
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			err = vm.buildHash(numElements)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.executeIndexExpression(left, index)
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.executeCall(int(numArgs))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				// a return statement of the program ends it, its value is the result
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer
+			err = vm.push(returnValue)
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer
+			err = vm.push(Null)
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.pushClosure(int(constIndex))
+		default:
+			def, lookupErr := code.Lookup(byte(op))
+			if lookupErr != nil {
+				return lookupErr
+			}
+			return fmt.Errorf("opcode %s not supported", def.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// pushVariable pushes the value of a variable, variables that were never set fall back to
+// the builtin of the same name as identifiers do in the evaluator
+func (vm *VM) pushVariable(value object.Object, names []string, index int) error {
+	if value != nil {
+		return vm.push(value)
+	}
+
+	name := ""
+	if index < len(names) {
+		name = names[index]
+	}
+
+	if builtin, ok := object.LookupBuiltin(name); ok {
+		return vm.push(builtin)
+	}
+
+	return &object.Error{Message: fmt.Sprintf("identifier not found: %s", name), Kind: object.NameErrorKind}
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex >= len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+	operator := binaryOperators[op]
+
+	switch {
+	case left.Type() == object.Integer_Obj && right.Type() == object.Integer_Obj:
+		return vm.executeIntegerOperation(operator, left, right)
+	case left.Type() == object.String_Obj && right.Type() == object.String_Obj:
+		return vm.executeStringOperation(operator, left, right)
+	case left.Type() != right.Type():
+		return object.NewError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case left.Type() == object.Boolean_Obj && operator == "==":
+		return vm.push(nativeBoolToBooleanObject(left.(*object.Boolean).Value == right.(*object.Boolean).Value))
+	case left.Type() == object.Boolean_Obj && operator == "!=":
+		return vm.push(nativeBoolToBooleanObject(left.(*object.Boolean).Value != right.(*object.Boolean).Value))
+	}
+
+	return object.NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func (vm *VM) executeIntegerOperation(operator string, left, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return vm.push(object.NewInteger(leftValue + rightValue))
+	case "-":
+		return vm.push(object.NewInteger(leftValue - rightValue))
+	case "*":
+		return vm.push(object.NewInteger(leftValue * rightValue))
+	case "/":
+		if rightValue == 0 {
+			return object.NewError("division by zero: %d / %d", leftValue, rightValue)
+		}
+		return vm.push(object.NewInteger(leftValue / rightValue))
+	case ">":
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case "<":
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case "==":
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case "!=":
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	}
+
+	return object.NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func (vm *VM) executeStringOperation(operator string, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		if err := vm.limits.Reserve(len(leftValue) + len(rightValue)); err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: leftValue + rightValue})
+	case "==":
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case "!=":
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	}
+
+	return object.NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	if operand.Type() != object.Integer_Obj {
+		return object.NewError("unknown operator: -%s", operand.Type())
+	}
+
+	return vm.push(object.NewInteger(-operand.(*object.Integer).Value))
+}
+
+func (vm *VM) buildArray(numElements int) error {
+	if err := vm.limits.Reserve(object.ArraySize(numElements)); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, numElements)
+	copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+	vm.sp -= numElements
+
+	return vm.push(&object.Array{Elements: elements})
+}
+
+func (vm *VM) buildHash(numElements int) error {
+	pairs := make(map[object.HashKey]object.HashPair, numElements/2)
+
+	for i := vm.sp - numElements; i < vm.sp; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	vm.sp -= numElements
+
+	if err := vm.limits.Reserve(object.HashSize(len(pairs))); err != nil {
+		return err
+	}
+
+	return vm.push(&object.Hash{Pairs: pairs})
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.Array_Obj && index.Type() == object.Integer_Obj:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= len(elements) {
+			return vm.push(Null)
+		}
+		return vm.push(elements[i])
+	case left.Type() == object.Hash_Obj:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", index.Type())
+		}
+		pair, ok := left.(*object.Hash).Pairs[key.HashKey()]
+		if !ok {
+			return vm.push(Null)
+		}
+		return vm.push(pair.Value)
+	}
+
+	return object.NewError("index operator not supported: %s", left.Type())
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	}
+
+	return &object.Error{Message: fmt.Sprintf("not a function: %s", callee.Type()), Kind: object.TypeErrorKind}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return object.NewArityError(cl.Fn.Name, cl.Fn.NumParameters, cl.Fn.NumParameters, numArgs)
+	}
+
+	if max := vm.limits.MaxCallDepth; max > 0 && vm.framesIndex-1 >= max {
+		return vm.limits.CheckCallDepth(vm.callChain())
+	}
+
+	basePointer := vm.sp - numArgs - 1
+	frame := NewFrame(cl, basePointer)
+	copy(frame.locals, vm.stack[vm.sp-numArgs:vm.sp])
+
+	vm.sp = basePointer
+	vm.pushFrame(frame)
+
+	return nil
+}
+
+// callBuiltin accounts the objects returned by builtins once they are built, as the evaluator does
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp = vm.sp - numArgs - 1
+
+	result := builtin.Call(vm.ctx, args...)
+	if result == nil {
+		return vm.push(Null)
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		return errObj
+	}
+
+	for _, arg := range args {
+		if arg == result {
+			return vm.push(result)
+		}
+	}
+
+	if err := vm.limits.Reserve(object.SizeOf(result)); err != nil {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex int) error {
+	function, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+
+	frame := vm.currentFrame()
+	free := make([]object.FreeVariable, len(function.Captures))
+	for i, capture := range function.Captures {
+		if capture.Local {
+			free[i] = object.FreeVariable{Locals: frame.locals, Index: capture.Index}
+		} else {
+			free[i] = frame.cl.Free[capture.Index]
+		}
+	}
+
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
+
+// callChain names the functions being called, outermost first
+func (vm *VM) callChain() []string {
+	chain := make([]string, 0, vm.framesIndex-1)
+	for _, frame := range vm.frames[1:vm.framesIndex] {
+		name := frame.cl.Fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		chain = append(chain, name)
+	}
+
+	return chain
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+
+	return False
+}
+
+func negate(operand object.Object) object.Object {
+	switch operand {
+	case True:
+		return False
+	case False:
+		return True
+	case Null:
+		return True
+	default:
+		return False
+	}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return true
+	}
+}
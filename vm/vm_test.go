@@ -0,0 +1,718 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-interpreter.com/m/compiler"
+	"go-interpreter.com/m/evaluator"
+	"go-interpreter.com/m/lexer"
+	"go-interpreter.com/m/object"
+	"go-interpreter.com/m/parser"
+)
+
+func TestIntegerArithmetic(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"5", 5},
+		{"-10", -10},
+		{"5+5", 10},
+		{"17-5", 12},
+		{"40/2", 20},
+		{"30*2", 60},
+		{"40/2 + 21", 41},
+		{"5+2*10", 25},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	for _, tc := range testCases {
+		testIntegerObject(t, executeVM(tc.input), tc.expected)
+	}
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"5 == 5", true},
+		{"4 == 6", false},
+		{"5 > 4", true},
+		{"5 > 10", false},
+		{"5 < 10", true},
+		{"5 < 4", false},
+		{"5 != 5", false},
+		{"5 != 4", true},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+	}
+
+	for _, tc := range testCases {
+		testBooleanObject(t, executeVM(tc.input), tc.expected)
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeVM(tc.input)
+		integer, ok := tc.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, integer)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, tc := range testCases {
+		testIntegerObject(t, executeVM(tc.input), tc.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	testCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"let f = fn() { later }; f()", "identifier not found: later"},
+		{"let f = fn() { later }; f(); let later = 1;", "identifier not found: later"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"let f = fn(x) { x }; f(1, 2)", "f: expected 1 argument, got 2"},
+		{"5()", "not a function: INTEGER"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{"1[0]", "index operator not supported: INTEGER"},
+	}
+
+	for _, tc := range testCases {
+		testErrorObject(t, executeVM(tc.input), tc.expectedMessage)
+	}
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5; let a = a + 1; a;", 6},
+	}
+
+	for _, tc := range testCases {
+		testIntegerObject(t, executeVM(tc.input), tc.expected)
+	}
+}
+
+func TestStringExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"Hello" == "Hello"`, true},
+		{`"Hello" != "World"`, true},
+		{`"Hello" == "World"`, false},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeVM(tc.input)
+		switch expected := tc.expected.(type) {
+		case string:
+			testStringObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := executeVM("[1, 2 * 2, 3 + 3]")
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+
+	evaluated := executeVM(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		True.HashKey():                             5,
+		False.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestIndexExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeVM(tc.input)
+		integer, ok := tc.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, integer)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestFunctionCalls(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let early = fn() { return 99; 100; }; early();", 99},
+		{"let f = fn() { let a = 1; let b = 2; a + b }; f();", 3},
+		{"let f = fn(x) { if (x > 1) { let y = x; } y }; f(5);", 5},
+		{"let noReturn = fn() { }; noReturn();", nil},
+		{"let onlyLet = fn() { let a = 1; }; onlyLet();", nil},
+		{"let f = fn() { g() }; let g = fn() { 7 }; f();", 7},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeVM(tc.input)
+		integer, ok := tc.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, integer)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{`
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`, 4},
+		{`
+let newAdderOuter = fn(a, b) {
+  let c = a + b;
+  fn(d) {
+    let e = d + c;
+    fn(f) { e + f; };
+  };
+};
+let newAdderInner = newAdderOuter(1, 2);
+let adder = newAdderInner(3);
+adder(8);`, 14},
+		{`
+let counter = fn() {
+  let make = fn() { fn() { count } };
+  let get = make();
+  let count = 10;
+  get()
+};
+counter();`, 10},
+		{`
+let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+if (isEven(10)) { 1 } else { 0 }`, 1},
+		{`
+let wrapper = fn() {
+  let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+  countDown(3);
+};
+wrapper();`, 0},
+	}
+
+	for _, tc := range testCases {
+		testIntegerObject(t, executeVM(tc.input), tc.expected)
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument 1 to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])[0]`, 2},
+		{`rest([])`, nil},
+		{`push([], 1)[0]`, 1},
+		{`push(1, 1)`, "argument 1 to `push` must be ARRAY, got INTEGER"},
+		{`puts("hello")`, nil},
+		{`type(1) == "INTEGER"`, true},
+		{`type(len)`, "BUILTIN"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`let len = fn(x) { 42 }; len("shadowed")`, 42},
+		{`let f = fn(len) { len }; f(3)`, 3},
+		{`let c = channel(1); send(c, 5); recv(c)`, 5},
+		{`select([channel(1)], 7)`, 7},
+		{`recv(channel(0))`, "evaluation interrupted: deadlock: all tasks are blocked"},
+		{`spawn fn() { 1 }`, "1:1: spawn is only supported by the evaluator"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := executeVM(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestRecursionDepthLimit(t *testing.T) {
+	input := `
+let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } };
+countdown(50);`
+
+	bytecode := compile(t, input)
+	testIntegerObject(t, run(New(bytecode)), 0)
+
+	evaluated := run(NewWithLimits(bytecode, &object.Limits{MaxCallDepth: 10}))
+	testErrorObject(t, evaluated,
+		"maximum recursion depth exceeded (10)\ncall chain: countdown (x10)")
+
+	evaluated = executeVM("let loop = fn() { loop() }; loop();")
+	testErrorObject(t, evaluated, fmt.Sprintf(
+		"maximum recursion depth exceeded (%d)\ncall chain: loop (x%d)",
+		object.DefaultMaxCallDepth, object.DefaultMaxCallDepth))
+
+	testIntegerObject(t, run(NewWithLimits(bytecode, &object.Limits{})), 0)
+}
+
+func TestRunContextCancellation(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(40);`
+
+	bytecode := compile(t, input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := New(bytecode).RunContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap context.DeadlineExceeded. got=%v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = New(bytecode).RunContext(canceled)
+	testErrorObject(t, err, "evaluation interrupted: context canceled")
+}
+
+func TestStepBudget(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(15);`
+
+	bytecode := compile(t, input)
+
+	err := NewWithLimits(bytecode, &object.Limits{MaxSteps: 100}).Run()
+	if !errors.Is(err, object.ErrStepBudgetExceeded) {
+		t.Errorf("error does not wrap ErrStepBudgetExceeded. got=%v", err)
+	}
+
+	testIntegerObject(t, run(NewWithLimits(bytecode, &object.Limits{MaxSteps: 1000000})), 610)
+}
+
+func TestMemoryLimit(t *testing.T) {
+	input := `
+let grow = fn(s, n) { if (n == 0) { s } else { grow(s + s, n - 1) } };
+grow("0123456789", 40);`
+
+	limits := &object.Limits{MaxAllocation: 1 << 20}
+	err := NewWithLimits(compile(t, input), limits).Run()
+	if !errors.Is(err, object.ErrMemoryLimitExceeded) {
+		t.Errorf("error does not wrap ErrMemoryLimitExceeded. got=%v", err)
+	}
+
+	if allocated := limits.Allocated(); allocated > 1<<20 {
+		t.Errorf("allocated more than the limit. got=%d", allocated)
+	}
+
+	limits = &object.Limits{MaxAllocation: 1 << 10}
+	evaluated := run(NewWithLimits(compile(t, "let a = [1, 2, 3]; let h = {1: a}; [a, a]"), limits))
+	if _, ok := evaluated.(*object.Array); !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := object.ArraySize(3) + object.HashSize(1) + object.ArraySize(2)
+	if allocated := limits.Allocated(); allocated != expected {
+		t.Errorf("wrong number of bytes allocated. got=%d, want=%d", allocated, expected)
+	}
+}
+
+// TestEvaluatorParity runs the same programs through the evaluator and the vm
+func TestEvaluatorParity(t *testing.T) {
+	inputs := []string{
+		"1 + 2 * 3 - 4 / 2",
+		"!(1 < 2) == false",
+		`"a" + "b" == "ab"`,
+		"[1, 2 + 3, [4]]",
+		`{"a": [1, 2], 3: true}["a"][1]`,
+		"if (0) { 1 } else { 2 }",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)",
+		`let map = fn(arr, f) {
+  let iter = fn(arr, acc) { if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) } };
+  iter(arr, []);
+};
+map([1, 2, 3], fn(x) { x * x })`,
+		"let x = 1; let f = fn() { x }; let x = 2; f()",
+		"let f = fn(a) { let a = a + 1; a }; f(1)",
+		"if (1 > 2) { 1 }",
+		"true + 1",
+		"-\"a\"",
+		"unknown(1)",
+		"[1, 2][true]",
+		"rest([1])",
+		"let add = fn(a, b) { a + b }; [1, 2, 3] |> rest |> len |> add(10)",
+		"let f = fn(a, b) { a + b }; f(1)",
+		"fn(a) { a }(1, 2)",
+		"5()",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		expected := evaluator.Eval(program, object.NewEnvironment())
+		evaluated := executeVM(input)
+
+		if expected.Inspect() != evaluated.Inspect() {
+			t.Errorf("vm and evaluator disagree on %q. evaluator=%s, vm=%s", input, expected.Inspect(), evaluated.Inspect())
+		}
+	}
+
+	// the kinds of the errors the vm reports itself, try is only supported by the evaluator
+	kindInputs := []string{
+		"unknown(1)",
+		"5()",
+		"let f = fn(a, b) { a + b }; f(1)",
+	}
+
+	for _, input := range kindInputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		expected, _ := evaluator.Eval(program, object.NewEnvironment()).(*object.Error)
+		evaluated, ok := executeVM(input).(*object.Error)
+
+		if expected == nil || !ok || expected.ErrorKind() != evaluated.ErrorKind() {
+			t.Errorf("vm and evaluator disagree on the kind of the error of %q. evaluator=%+v, vm=%+v", input, expected, evaluated)
+		}
+	}
+}
+
+func TestLoadedBytecode(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let names = {"fib": fib};
+[names["fib"](15), len("abc")];`
+
+	data, err := compile(t, input).MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	bytecode, err := compiler.UnmarshalBytecode(data)
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	evaluated := run(New(bytecode))
+	if evaluated.Inspect() != "[610, 3]" {
+		t.Errorf("loaded bytecode has wrong result. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	input := `let check = fn(x) {
+  if (x > 2) {
+    x + true
+  } else {
+    check(x + 1)
+  }
+};
+
+check(0);`
+
+	vm := New(compile(t, input))
+	err := vm.Run()
+	testErrorObject(t, err, "type mismatch: INTEGER + BOOLEAN")
+
+	if vm.Line() != 3 {
+		t.Errorf("wrong line for runtime error. got=%d, want=3", vm.Line())
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	input := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20);"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			b.Fatal(err)
+		}
+		bytecode := c.Bytecode()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := New(bytecode).Run(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	c := compiler.New()
+	if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return c.Bytecode()
+}
+
+// run returns the result of the program, or the runtime error that stopped it
+func run(vm *VM) object.Object {
+	if err := vm.Run(); err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			return errObj
+		}
+		return &object.Error{Message: err.Error(), Err: err}
+	}
+
+	return vm.LastPoppedStackElem()
+}
+
+func executeVM(input string) object.Object {
+	c := compiler.New()
+	if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		return &object.Error{Message: err.Error(), Err: err}
+	}
+
+	return run(New(c.Bytecode()))
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int) bool {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	t.Helper()
+
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	t.Helper()
+
+	if obj != Null {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	return true
+}
+
+func testErrorObject(t *testing.T, obj interface{}, expected string) bool {
+	t.Helper()
+
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("no error object returned. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+
+	return true
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	t.Helper()
+
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+
+	return true
+}